@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// certificateReloadInterval is how often the certificate files are checked
+// for changes.
+const certificateReloadInterval = time.Minute
+
+var tlsCertificateNotAfterDesc = prometheus.NewDesc(
+	"atlas_exporter_tls_certificate_not_after",
+	"Expiry time (Unix timestamp) of the certificate served by the exporter",
+	nil,
+	nil,
+)
+
+// CertificateReloader serves the TLS certificate of the HTTP server from the
+// certificate chain and key files and swaps it when the files change, so
+// renewed certificates are used without a restart.
+type CertificateReloader struct {
+	certPath string
+	keyPath  string
+
+	mu          sync.RWMutex
+	certificate *tls.Certificate
+	certPEM     []byte
+	keyPEM      []byte
+
+	lastReloadSuccessful prometheus.Gauge
+}
+
+// NewCertificateReloader creates a CertificateReloader and loads the
+// certificate.
+func NewCertificateReloader(certPath, keyPath string) (*CertificateReloader, error) {
+	c := &CertificateReloader{
+		certPath: certPath,
+		keyPath:  keyPath,
+		lastReloadSuccessful: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "atlas_exporter_tls_certificate_last_reload_successful",
+			Help: "Whether the last TLS certificate reload attempt was successful (1 = success)",
+		}),
+	}
+	if err := c.Reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// certificatePaths returns the certificate chain and key files served by the
+// HTTP server, and false when TLS is disabled.
+func (c *Config) certificatePaths() (string, string, bool) {
+	switch {
+	case c.Web != nil && c.Web.TLSConfig != nil:
+		return c.Web.TLSConfig.CertFile, c.Web.TLSConfig.KeyFile, true
+	case c.TLSEnabled:
+		return c.TLSCertChainPath, c.TLSKeyPath, true
+	default:
+		return "", "", false
+	}
+}
+
+// Reload reads the certificate files and swaps the certificate when they
+// changed. On failure the previous certificate stays in use.
+func (c *CertificateReloader) Reload() error {
+	certPEM, keyPEM, err := c.read()
+	if err != nil {
+		c.lastReloadSuccessful.Set(0)
+		return err
+	}
+	c.mu.RLock()
+	unchanged := bytes.Equal(certPEM, c.certPEM) && bytes.Equal(keyPEM, c.keyPEM)
+	c.mu.RUnlock()
+	if unchanged {
+		c.lastReloadSuccessful.Set(1)
+		return nil
+	}
+	certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		c.lastReloadSuccessful.Set(0)
+		return fmt.Errorf("failed to load TLS certificate %s: %w", c.certPath, err)
+	}
+	c.mu.Lock()
+	previous := c.certificate
+	c.certificate, c.certPEM, c.keyPEM = &certificate, certPEM, keyPEM
+	c.mu.Unlock()
+	c.lastReloadSuccessful.Set(1)
+	if previous != nil {
+		logger.WithField("not_after", certificate.Leaf.NotAfter).Info("TLS certificate reloaded")
+	}
+	return nil
+}
+
+func (c *CertificateReloader) read() ([]byte, []byte, error) {
+	certPEM, err := os.ReadFile(c.certPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read TLS certificate: %w", err)
+	}
+	keyPEM, err := os.ReadFile(c.keyPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read TLS key: %w", err)
+	}
+	return certPEM, keyPEM, nil
+}
+
+// GetCertificate returns the current certificate. It is used as the
+// GetCertificate function of the server tls.Config.
+func (c *CertificateReloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.certificate == nil {
+		return nil, errors.New("no TLS certificate loaded")
+	}
+	return c.certificate, nil
+}
+
+// Run reloads the certificate every interval until ctx is cancelled.
+func (c *CertificateReloader) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.Reload(); err != nil {
+				logger.WithError(err).Error("Failed to reload TLS certificate")
+			}
+		}
+	}
+}
+
+func (c *CertificateReloader) Describe(ch chan<- *prometheus.Desc) {
+	ch <- tlsCertificateNotAfterDesc
+	c.lastReloadSuccessful.Describe(ch)
+}
+
+func (c *CertificateReloader) Collect(ch chan<- prometheus.Metric) {
+	c.mu.RLock()
+	notAfter := c.certificate.Leaf.NotAfter
+	c.mu.RUnlock()
+	ch <- prometheus.MustNewConstMetric(tlsCertificateNotAfterDesc, prometheus.GaugeValue, float64(notAfter.Unix()))
+	ch <- c.lastReloadSuccessful
+}
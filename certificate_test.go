@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCertificateReloader(t *testing.T) {
+	dir := t.TempDir()
+	firstExpiry := time.Now().Add(time.Hour)
+	certPath, keyPath, _ := writeTestCertificate(t, dir, "server", firstExpiry)
+	certificates, err := NewCertificateReloader(certPath, keyPath)
+	if err != nil {
+		t.Fatalf("NewCertificateReloader failed: %v", err)
+	}
+	expectMetrics := func(notAfter time.Time, successful int) {
+		t.Helper()
+		expected := fmt.Sprintf(`
+# HELP atlas_exporter_tls_certificate_last_reload_successful Whether the last TLS certificate reload attempt was successful (1 = success)
+# TYPE atlas_exporter_tls_certificate_last_reload_successful gauge
+atlas_exporter_tls_certificate_last_reload_successful %d
+# HELP atlas_exporter_tls_certificate_not_after Expiry time (Unix timestamp) of the certificate served by the exporter
+# TYPE atlas_exporter_tls_certificate_not_after gauge
+atlas_exporter_tls_certificate_not_after %d
+`, successful, notAfter.Unix())
+		if err := testutil.CollectAndCompare(certificates, strings.NewReader(expected)); err != nil {
+			t.Error(err)
+		}
+	}
+	expectMetrics(firstExpiry, 1)
+
+	secondExpiry := time.Now().Add(48 * time.Hour)
+	writeTestCertificate(t, dir, "server", secondExpiry)
+	if err = certificates.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	expectMetrics(secondExpiry, 1)
+
+	if err = os.WriteFile(certPath, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	if err = certificates.Reload(); err == nil {
+		t.Error("expected an invalid certificate to fail to reload")
+	}
+	certificate, err := certificates.GetCertificate(nil)
+	if err != nil || !certificate.Leaf.NotAfter.Equal(secondExpiry.Truncate(time.Second)) {
+		t.Errorf("expected the previous certificate to stay in use, got %v", err)
+	}
+	expectMetrics(secondExpiry, 0)
+}
+
+func TestServeReloadsCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath, _ := writeTestCertificate(t, dir, "server", time.Now().Add(time.Hour))
+	cfg := &Config{TLSEnabled: true, TLSCertChainPath: certPath, TLSKeyPath: keyPath}
+	address, certificates := startTestServer(t, cfg, http.NotFoundHandler())
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go certificates.Run(ctx, 10*time.Millisecond)
+
+	servedSerial := func() string {
+		t.Helper()
+		conn, err := tls.Dial("tcp", address, &tls.Config{InsecureSkipVerify: true}) // #nosec G402 -- self-signed test certificate
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		defer func() { _ = conn.Close() }()
+		return conn.ConnectionState().PeerCertificates[0].SerialNumber.String()
+	}
+	first := servedSerial()
+
+	_, _, renewed := writeTestCertificate(t, dir, "server", time.Now().Add(48*time.Hour))
+	leaf, err := x509.ParseCertificate(renewed.Certificate[0])
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for servedSerial() != leaf.SerialNumber.String() {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the renewed certificate to be served, still serving serial %s", first)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"fmt"
-	"os"
 	"runtime"
 	"strings"
 	"testing"
@@ -22,34 +21,3 @@ atlas_exporter_build_info{commit="unknown",date="unknown",go_version="%s",versio
 		t.Errorf("BuildInfoCollector failed: %v", err)
 	}
 }
-
-func TestCreditsCollector(t *testing.T) {
-	if os.Getenv("ATLAS_EXPORTER_API_TOKEN") == "" {
-		t.Skip("Skipping TestCreditsCollector because ATLAS_EXPORTER_API_TOKEN is not set")
-	}
-	collector := CreditsCollector(t.Context(), 10)
-	if err := testutil.CollectAndCompare(collector, strings.NewReader(`
-# HELP atlas_exporter_credits Current number of credits available in the Atlas account
-# TYPE atlas_exporter_credits gauge
-atlas_exporter_credits 1000
-`)); err != nil {
-		t.Errorf("CreditsCollector failed: %v", err)
-	}
-}
-
-func TestProbeLastConnectedCollector(t *testing.T) {
-	if os.Getenv("ATLAS_EXPORTER_API_TOKEN") == "" {
-		t.Skip("Skipping TestProbeLastConnectedCollector because ATLAS_EXPORTER_API_TOKEN is not set")
-	}
-	collector := ProbeLastConnectedCollector{
-		ctx:     t.Context(),
-		timeout: 10,
-	}
-	if err := testutil.CollectAndCompare(&collector, strings.NewReader(`
-# HELP atlas_exporter_probe_last_connected Last time the probe was connected
-# TYPE atlas_exporter_probe_last_connected gauge
-atlas_exporter_probe_last_connected{probe_id="12345"} 1700000000
-}`)); err != nil {
-		t.Errorf("ProbeLastConnectedCollector failed: %v", err)
-	}
-}
@@ -0,0 +1,559 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/exporter"
+	"github.com/Cyb3r-Jak3/common/v5"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the exporter configuration. It is built from the flag
+// defaults, then the configuration file, then any flag or environment
+// variable that was explicitly set.
+type Config struct {
+	ListenAddress    string                  `yaml:"listen_address"`
+	MetricsPath      string                  `yaml:"metrics_path"`
+	APIToken         string                  `yaml:"api_token"`
+	APITokenFile     string                  `yaml:"api_token_file"`
+	BaseURL          string                  `yaml:"base_url"`
+	Timeout          int                     `yaml:"timeout"`
+	TLSEnabled       bool                    `yaml:"tls_enabled"`
+	TLSCertChainPath string                  `yaml:"tls_cert_chain_path"`
+	TLSKeyPath       string                  `yaml:"tls_key_path"`
+	WebConfigFile    string                  `yaml:"web_config_file"`
+	LogLevel         string                  `yaml:"log_level"`
+	LogFormat        string                  `yaml:"log_format"`
+	RefreshInterval  time.Duration           `yaml:"refresh_interval"`
+	Collectors       CollectorsConfig        `yaml:"collectors"`
+	Accounts         []AccountConfig         `yaml:"accounts"`
+	Modules          map[string]ModuleConfig `yaml:"modules"`
+	RemoteWrite      RemoteWriteConfig       `yaml:"remote_write"`
+	OTLP             OTLPConfig              `yaml:"otlp"`
+	Sinks            []SinkConfig            `yaml:"sinks"`
+	State            StateConfig             `yaml:"state"`
+	Rules            RulesConfig             `yaml:"rules"`
+	Notifications    NotificationsConfig     `yaml:"notifications"`
+	// Web is loaded from WebConfigFile.
+	Web *WebConfig `yaml:"-"`
+}
+
+// ModuleConfig selects the results exported by the /atlas endpoint. Empty
+// Fields exports every field of the module type and an empty Account uses the
+// first account.
+type ModuleConfig struct {
+	Type    string   `yaml:"type"`
+	Fields  []string `yaml:"fields"`
+	Account string   `yaml:"account"`
+}
+
+// AccountConfig is an Atlas account monitored by the exporter. An empty
+// BaseURL uses the top level setting and Collectors overrides the top level
+// collectors block setting by setting. When APITokenFile is set, APIToken is
+// read from it.
+type AccountConfig struct {
+	Name         string            `yaml:"name"`
+	APIToken     string            `yaml:"api_token"`
+	APITokenFile string            `yaml:"api_token_file"`
+	BaseURL      string            `yaml:"base_url"`
+	Collectors   *CollectorsConfig `yaml:"collectors"`
+}
+
+// defaultAccount is the name of the account built from the top level
+// api_token when no accounts are configured.
+const defaultAccount = "default"
+
+type CollectorsConfig struct {
+	Credits             CollectorConfig            `yaml:"credits"`
+	CreditForecast      CollectorConfig            `yaml:"credit_forecast"`
+	APIKey              CollectorConfig            `yaml:"api_key"`
+	ProbeLastConnected  CollectorConfig            `yaml:"probe_last_connected"`
+	ProbeMeasurements   CollectorConfig            `yaml:"probe_measurements"`
+	MeasurementMetadata MeasurementMetadataConfig  `yaml:"measurement_metadata"`
+	SSLCert             MeasurementCollectorConfig `yaml:"sslcert"`
+	HTTP                MeasurementCollectorConfig `yaml:"http"`
+	NTP                 MeasurementCollectorConfig `yaml:"ntp"`
+}
+
+// CollectorConfig holds the settings shared by every collector. A zero
+// RefreshInterval uses the top level refresh_interval and a nil Enabled the
+// top level setting or the default state of the collector.
+type CollectorConfig struct {
+	Enabled         *bool         `yaml:"enabled"`
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+}
+
+type MeasurementCollectorConfig struct {
+	CollectorConfig `yaml:",inline"`
+	Measurements    []int `yaml:"measurements"`
+}
+
+// MeasurementMetadataConfig holds the settings of the measurement_metadata
+// collector. A nil Limit uses the top level setting or the default of the
+// collector, and 0 disables the collector.
+type MeasurementMetadataConfig struct {
+	CollectorConfig `yaml:",inline"`
+	Limit           *int `yaml:"limit"`
+}
+
+// byName returns the shared settings of every collector keyed by collector name.
+func (c *CollectorsConfig) byName() map[string]*CollectorConfig {
+	return map[string]*CollectorConfig{
+		"credits":              &c.Credits,
+		"credit_forecast":      &c.CreditForecast,
+		"api_key":              &c.APIKey,
+		"probe_last_connected": &c.ProbeLastConnected,
+		"probe_measurements":   &c.ProbeMeasurements,
+		"measurement_metadata": &c.MeasurementMetadata.CollectorConfig,
+		"sslcert":              &c.SSLCert.CollectorConfig,
+		"http":                 &c.HTTP.CollectorConfig,
+		"ntp":                  &c.NTP.CollectorConfig,
+	}
+}
+
+// interval returns the refresh interval of the named collector, or fallback
+// when it has none.
+func (c *CollectorsConfig) interval(name string, fallback time.Duration) time.Duration {
+	if collector, ok := c.byName()[name]; ok && collector.RefreshInterval > 0 {
+		return collector.RefreshInterval
+	}
+	return fallback
+}
+
+// dataSettings returns the settings of the named collector that change the
+// data it fetches, as opposed to how often it is refreshed.
+func (c *CollectorsConfig) dataSettings(name string) any {
+	switch name {
+	case "measurement_metadata":
+		if c.MeasurementMetadata.Limit == nil {
+			return nil
+		}
+		return *c.MeasurementMetadata.Limit
+	case "sslcert":
+		return c.SSLCert.Measurements
+	case "http":
+		return c.HTTP.Measurements
+	case "ntp":
+		return c.NTP.Measurements
+	}
+	return nil
+}
+
+// merge returns the settings of c overridden by every setting that is set in
+// override, so an account only lists what differs from the top level.
+func (c *CollectorsConfig) merge(override *CollectorsConfig) *CollectorsConfig {
+	merged := *c
+	collectors := merged.byName()
+	for name, setting := range override.byName() {
+		if setting.Enabled != nil {
+			collectors[name].Enabled = setting.Enabled
+		}
+		if setting.RefreshInterval != 0 {
+			collectors[name].RefreshInterval = setting.RefreshInterval
+		}
+	}
+	if override.MeasurementMetadata.Limit != nil {
+		merged.MeasurementMetadata.Limit = override.MeasurementMetadata.Limit
+	}
+	for _, measurements := range []struct {
+		merged   *[]int
+		override []int
+	}{
+		{&merged.SSLCert.Measurements, override.SSLCert.Measurements},
+		{&merged.HTTP.Measurements, override.HTTP.Measurements},
+		{&merged.NTP.Measurements, override.NTP.Measurements},
+	} {
+		if measurements.override != nil {
+			*measurements.merged = measurements.override
+		}
+	}
+	return &merged
+}
+
+// accounts returns the monitored accounts with the top level defaults applied.
+func (c *Config) accounts() []AccountConfig {
+	if len(c.Accounts) == 0 {
+		return []AccountConfig{{Name: defaultAccount, APIToken: c.APIToken, APITokenFile: c.APITokenFile, BaseURL: c.BaseURL, Collectors: &c.Collectors}}
+	}
+	accounts := make([]AccountConfig, len(c.Accounts))
+	for i, account := range c.Accounts {
+		if account.BaseURL == "" {
+			account.BaseURL = c.BaseURL
+		}
+		if account.Collectors == nil {
+			account.Collectors = &c.Collectors
+		} else {
+			account.Collectors = c.Collectors.merge(account.Collectors)
+		}
+		accounts[i] = account
+	}
+	return accounts
+}
+
+// LoadConfig builds the configuration from the command flags and the file set
+// in --config.file, reads the API token and web configuration files, then
+// validates it.
+func LoadConfig(c *cli.Command) (*Config, error) {
+	cfg, err := readConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	if err = cfg.readTokenFiles(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	if cfg.WebConfigFile != "" {
+		if cfg.Web, err = loadWebConfig(cfg.WebConfigFile); err != nil {
+			return nil, err
+		}
+	}
+	if err = cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+// readConfig builds the configuration from the command flags and the file
+// set in --config.file without reading other files or validating it.
+func readConfig(c *cli.Command) (*Config, error) {
+	cfg := &Config{}
+	if err := cfg.applyFlags(c, false); err != nil {
+		return nil, err
+	}
+	if path := c.String("config.file"); path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+		if err := cfg.applyFlags(c, true); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// envReference matches the environment variable references expanded in the
+// configuration file and the $$ escape of a literal $.
+var envReference = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces the ${VAR} references of content with the value of the
+// environment variable and $$ with $. Any other $ is kept as is, so values
+// such as webhook templates are not mangled. Referencing an unset variable is
+// an error.
+func expandEnv(content string) (string, error) {
+	var unset []string
+	expanded := envReference.ReplaceAllStringFunc(content, func(match string) string {
+		if match == "$$" {
+			return "$"
+		}
+		name := match[2 : len(match)-1]
+		value, ok := os.LookupEnv(name)
+		if !ok && !slices.Contains(unset, name) {
+			unset = append(unset, name)
+		}
+		return value
+	})
+	if len(unset) > 0 {
+		return "", fmt.Errorf("environment variables are not set: %s", strings.Join(unset, ", "))
+	}
+	return expanded, nil
+}
+
+// loadFile decodes the YAML file at path over the current values. Environment
+// variables written as ${VAR} are expanded first, $$ is a literal $.
+func (c *Config) loadFile(path string) error {
+	content, err := os.ReadFile(path) // #nosec G304 -- path is set by the operator
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	expanded, err := expandEnv(string(content))
+	if err != nil {
+		return fmt.Errorf("failed to expand config file %s: %w", path, err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewBufferString(expanded))
+	decoder.KnownFields(true)
+	if err = decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// applyFlags copies the flag values into the configuration. When onlySet is
+// true, only flags set on the command line or through environment variables
+// are copied so they override the configuration file.
+func (c *Config) applyFlags(cmd *cli.Command, onlySet bool) error {
+	use := func(name string) bool {
+		return !onlySet || cmd.IsSet(name)
+	}
+	if use("listen_address") {
+		c.ListenAddress = cmd.String("listen_address")
+	}
+	if use("metrics_path") {
+		c.MetricsPath = cmd.String("metrics_path")
+	}
+	if use("api_token") {
+		c.APIToken = strings.TrimSpace(cmd.String("api_token"))
+	}
+	if use("api_token_file") {
+		c.APITokenFile = cmd.String("api_token_file")
+	}
+	if use("base_url") {
+		c.BaseURL = cmd.String("base_url")
+	}
+	if use("timeout") {
+		c.Timeout = cmd.Int("timeout")
+	}
+	if use("tls_enabled") {
+		c.TLSEnabled = cmd.Bool("tls_enabled")
+	}
+	if use("tls_cert_chain_path") {
+		c.TLSCertChainPath = cmd.String("tls_cert_chain_path")
+	}
+	if use("tls_key_path") {
+		c.TLSKeyPath = cmd.String("tls_key_path")
+	}
+	if use("web_config_file") {
+		c.WebConfigFile = cmd.String("web_config_file")
+	}
+	if use("remote_write_url") {
+		c.RemoteWrite.URL = cmd.String("remote_write_url")
+	}
+	if use("remote_write_interval") {
+		c.RemoteWrite.Interval = cmd.Duration("remote_write_interval")
+	}
+	if use("otlp_endpoint") {
+		c.OTLP.Endpoint = cmd.String("otlp_endpoint")
+	}
+	if use("otlp_protocol") {
+		c.OTLP.Protocol = cmd.String("otlp_protocol")
+	}
+	if use("otlp_interval") {
+		c.OTLP.Interval = cmd.Duration("otlp_interval")
+	}
+	if use("state_path") {
+		c.State.Path = cmd.String("state_path")
+	}
+	if use("state_retention") {
+		c.State.Retention = cmd.Duration("state_retention")
+	}
+	if use("log_level") {
+		c.LogLevel = cmd.String("log_level")
+	}
+	if use("log_format") {
+		c.LogFormat = cmd.String("log_format")
+	}
+	if use("refresh_interval") {
+		c.RefreshInterval = cmd.Duration("refresh_interval")
+	}
+	if err := c.Collectors.applyFlags(cmd, use); err != nil {
+		return err
+	}
+	// Flags take precedence over the collectors block of every account too.
+	for i := range c.Accounts {
+		if c.Accounts[i].Collectors != nil {
+			if err := c.Accounts[i].Collectors.applyFlags(cmd, cmd.IsSet); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// applyFlags copies the collector flags accepted by use into the collector
+// settings.
+func (c *CollectorsConfig) applyFlags(cmd *cli.Command, use func(name string) bool) error {
+	if use("measurement_metadata_limit") {
+		limit := cmd.Int("measurement_metadata_limit")
+		c.MeasurementMetadata.Limit = &limit
+	}
+	if use("sslcert_measurements") {
+		c.SSLCert.Measurements = cmd.IntSlice("sslcert_measurements")
+	}
+	if use("http_measurements") {
+		c.HTTP.Measurements = cmd.IntSlice("http_measurements")
+	}
+	if use("ntp_measurements") {
+		c.NTP.Measurements = cmd.IntSlice("ntp_measurements")
+	}
+	collectors := c.byName()
+	for _, name := range exporter.CollectorNames() {
+		if flag := "collector." + name; use(flag) {
+			enabled := cmd.Bool(flag)
+			collectors[name].Enabled = &enabled
+		}
+	}
+	if use("collector_refresh_intervals") {
+		for name, value := range cmd.StringMap("collector_refresh_intervals") {
+			collector, ok := collectors[name]
+			if !ok {
+				return fmt.Errorf("collector_refresh_intervals: unknown collector %q", name)
+			}
+			interval, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("collector_refresh_intervals: invalid refresh interval for collector %s: %w", name, err)
+			}
+			collector.RefreshInterval = interval
+		}
+	}
+	return nil
+}
+
+// Validate checks the configuration and reports every problem found.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.APIToken == "" && len(c.Accounts) == 0 {
+		errs = append(errs, errors.New("api_token: API token is required. Set it with --api_token, --api_token_file, the ATLAS_EXPORTER_API_TOKEN environment variable or in the config file"))
+	}
+	if c.APIToken != "" && len(c.Accounts) > 0 {
+		errs = append(errs, errors.New("api_token, api_token_file: must not be set when accounts are configured, set the token of each account instead"))
+	}
+	if c.ListenAddress == "" {
+		errs = append(errs, errors.New("listen_address: must not be empty"))
+	}
+	if !strings.HasPrefix(c.MetricsPath, "/") {
+		errs = append(errs, fmt.Errorf("metrics_path: must start with '/', got %q", c.MetricsPath))
+	}
+	if c.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("timeout: must be greater than 0, got %d", c.Timeout))
+	}
+	if c.TLSEnabled && (c.TLSCertChainPath == "" || c.TLSKeyPath == "") {
+		errs = append(errs, errors.New("tls_cert_chain_path, tls_key_path: both must be set when tls_enabled is true"))
+	}
+	if c.TLSEnabled && c.Web != nil && c.Web.TLSConfig != nil {
+		errs = append(errs, errors.New("tls_enabled: must be false when web_config_file configures tls_server_config"))
+	}
+	if c.Web != nil {
+		errs = append(errs, c.Web.validate()...)
+	}
+	if _, err := logrus.ParseLevel(c.LogLevel); err != nil {
+		errs = append(errs, fmt.Errorf("log_level: %w", err))
+	}
+	if !common.StringSearch(c.LogFormat, []string{"text", "json"}) {
+		errs = append(errs, fmt.Errorf("log_format: must be 'text' or 'json', got %q", c.LogFormat))
+	}
+	if c.RefreshInterval <= 0 {
+		errs = append(errs, fmt.Errorf("refresh_interval: must be greater than 0, got %s", c.RefreshInterval))
+	}
+	errs = append(errs, c.Collectors.validate("collectors")...)
+	errs = append(errs, c.RemoteWrite.validate("remote_write")...)
+	errs = append(errs, c.OTLP.validate("otlp")...)
+	for i := range c.Sinks {
+		errs = append(errs, c.Sinks[i].validate(fmt.Sprintf("sinks[%d]", i))...)
+	}
+	errs = append(errs, c.State.validate("state")...)
+	errs = append(errs, c.Rules.validate("rules")...)
+	errs = append(errs, c.Notifications.validate("notifications")...)
+	seen := map[string]bool{}
+	for i, account := range c.Accounts {
+		prefix := fmt.Sprintf("accounts[%d]", i)
+		switch {
+		case account.Name == "":
+			errs = append(errs, fmt.Errorf("%s.name: must not be empty", prefix))
+		case seen[account.Name]:
+			errs = append(errs, fmt.Errorf("%s.name: duplicate account name %q", prefix, account.Name))
+		}
+		seen[account.Name] = true
+		if account.APIToken == "" {
+			errs = append(errs, fmt.Errorf("%s.api_token: API token is required", prefix))
+		}
+		if account.Collectors != nil {
+			errs = append(errs, account.Collectors.validate(prefix+".collectors")...)
+		}
+	}
+	moduleNames := make([]string, 0, len(c.Modules))
+	for name := range c.Modules {
+		moduleNames = append(moduleNames, name)
+	}
+	sort.Strings(moduleNames)
+	for _, name := range moduleNames {
+		module := c.Modules[name]
+		fields := exporter.MeasurementFields(module.Type)
+		if fields == nil {
+			errs = append(errs, fmt.Errorf("modules.%s.type: must be one of %s, got %q", name, strings.Join(exporter.MeasurementKinds(), ", "), module.Type))
+			continue
+		}
+		for i, field := range module.Fields {
+			if !slices.Contains(fields, field) {
+				errs = append(errs, fmt.Errorf("modules.%s.fields[%d]: unknown %s field %q", name, i, module.Type, field))
+			}
+		}
+		if module.Account != "" && !seen[module.Account] && !(len(c.Accounts) == 0 && module.Account == defaultAccount) {
+			errs = append(errs, fmt.Errorf("modules.%s.account: unknown account %q", name, module.Account))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// readTokenFiles sets the API token of the top level and of every account
+// with an api_token_file from the content of the file.
+func (c *Config) readTokenFiles() error {
+	var errs []error
+	read := func(prefix string, token *string, path string) {
+		if path == "" {
+			return
+		}
+		if *token != "" {
+			errs = append(errs, fmt.Errorf("%sapi_token, %sapi_token_file: only one can be set", prefix, prefix))
+			return
+		}
+		var err error
+		if *token, err = readTokenFile(path); err != nil {
+			errs = append(errs, fmt.Errorf("%sapi_token_file: %w", prefix, err))
+		}
+	}
+	read("", &c.APIToken, c.APITokenFile)
+	for i := range c.Accounts {
+		read(fmt.Sprintf("accounts[%d].", i), &c.Accounts[i].APIToken, c.Accounts[i].APITokenFile)
+	}
+	return errors.Join(errs...)
+}
+
+// validate checks the collector settings. Errors are prefixed with the key
+// path of the collectors block.
+func (c *CollectorsConfig) validate(prefix string) []error {
+	var errs []error
+	collectors := c.byName()
+	for _, name := range exporter.CollectorNames() {
+		if collector := collectors[name]; collector.RefreshInterval < 0 {
+			errs = append(errs, fmt.Errorf("%s.%s.refresh_interval: must not be negative, got %s", prefix, name, collector.RefreshInterval))
+		}
+	}
+	if limit := c.MeasurementMetadata.Limit; limit != nil && *limit < 0 {
+		errs = append(errs, fmt.Errorf("%s.measurement_metadata.limit: must not be negative, got %d", prefix, *limit))
+	}
+	for _, collector := range []struct {
+		name   string
+		config MeasurementCollectorConfig
+	}{
+		{"sslcert", c.SSLCert},
+		{"http", c.HTTP},
+		{"ntp", c.NTP},
+	} {
+		name := collector.name
+		for i, measurementID := range collector.config.Measurements {
+			if measurementID <= 0 {
+				errs = append(errs, fmt.Errorf("%s.%s.measurements[%d]: measurement ID must be greater than 0, got %d", prefix, name, i, measurementID))
+			}
+		}
+	}
+	return errs
+}
+
+// ConfigCheck validates the configuration without starting the exporter.
+func ConfigCheck(_ context.Context, c *cli.Command) error {
+	if _, err := LoadConfig(c); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(c.Root().Writer, "Configuration is valid")
+	return err
+}
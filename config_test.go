@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/urfave/cli/v3"
+)
+
+// loadTestConfig runs the app with args and returns the configuration Run would use.
+func loadTestConfig(t *testing.T, content string, args ...string) (*Config, error) {
+	t.Helper()
+	if content != "" {
+		path := filepath.Join(t.TempDir(), "config.yml")
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to write config file: %v", err)
+		}
+		args = append([]string{"--config.file", path}, args...)
+	}
+	var cfg *Config
+	var loadErr error
+	app := buildApp()
+	app.Action = func(_ context.Context, c *cli.Command) error {
+		cfg, loadErr = LoadConfig(c)
+		return nil
+	}
+	if err := app.Run(t.Context(), append([]string{"atlas_exporter"}, args...)); err != nil {
+		t.Fatalf("Failed to run app: %v", err)
+	}
+	return cfg, loadErr
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	t.Setenv("TEST_ATLAS_TOKEN", "file-token")
+	t.Setenv("ATLAS_EXPORTER_LOG_LEVEL", "debug")
+	cfg, err := loadTestConfig(t, `
+api_token: ${TEST_ATLAS_TOKEN}
+listen_address: ":9100"
+log_level: warn
+timeout: 30
+refresh_interval: 10m
+collectors:
+  credits:
+    refresh_interval: 1m
+  sslcert:
+    measurements: [1001, 1002]
+`, "--timeout", "5")
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.APIToken != "file-token" {
+		t.Errorf("expected api_token from the interpolated file, got %q", cfg.APIToken)
+	}
+	if cfg.ListenAddress != ":9100" {
+		t.Errorf("expected listen_address from the file, got %q", cfg.ListenAddress)
+	}
+	if cfg.MetricsPath != "/metrics" {
+		t.Errorf("expected default metrics_path, got %q", cfg.MetricsPath)
+	}
+	if cfg.Timeout != 5 {
+		t.Errorf("expected timeout from the flag, got %d", cfg.Timeout)
+	}
+	if cfg.LogLevel != "debug" {
+		t.Errorf("expected log_level from the environment, got %q", cfg.LogLevel)
+	}
+	credits, ntp := cfg.Collectors.interval("credits", cfg.RefreshInterval), cfg.Collectors.interval("ntp", cfg.RefreshInterval)
+	if credits != time.Minute || ntp != 10*time.Minute {
+		t.Errorf("unexpected refresh intervals %s and %s", credits, ntp)
+	}
+	if len(cfg.Collectors.SSLCert.Measurements) != 2 {
+		t.Errorf("expected 2 sslcert measurements, got %v", cfg.Collectors.SSLCert.Measurements)
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("TEST_ATLAS_TOKEN", "token")
+	t.Setenv("TEST_ATLAS_EMPTY", "")
+	expanded, err := expandEnv("a: ${TEST_ATLAS_TOKEN}\nb: '${TEST_ATLAS_EMPTY}'\nc: $TEST_ATLAS_TOKEN $$ $${TEST_ATLAS_TOKEN}\nd: '{{ $labels.account }}'\n")
+	if err != nil {
+		t.Fatalf("expandEnv failed: %v", err)
+	}
+	if expected := "a: token\nb: ''\nc: $TEST_ATLAS_TOKEN $ ${TEST_ATLAS_TOKEN}\nd: '{{ $labels.account }}'\n"; expanded != expected {
+		t.Errorf("expected %q, got %q", expected, expanded)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	t.Setenv("ATLAS_EXPORTER_API_TOKEN", "")
+	_ = os.Unsetenv("ATLAS_EXPORTER_API_TOKEN")
+	tests := []struct {
+		name     string
+		content  string
+		args     []string
+		expected string
+	}{
+		{"missing token", "", nil, "api_token: API token is required"},
+		{"unset environment variable", "api_token: ${TEST_ATLAS_UNSET_TOKEN}\n# ${TEST_ATLAS_UNSET_TOKEN}\n", nil, "environment variables are not set: TEST_ATLAS_UNSET_TOKEN"},
+		{"unknown field", "api_token: x\ncollectors:\n  bogus: {}\n", nil, "field bogus not found"},
+		{"invalid duration", "api_token: x\nrefresh_interval: soon\n", nil, "line 2"},
+		{"invalid measurement", "api_token: x\ncollectors:\n  ntp:\n    measurements: [0]\n", nil, "collectors.ntp.measurements[0]"},
+		{"unknown interval flag", "api_token: x\n", []string{"--collector_refresh_intervals", "bogus=1m"}, `unknown collector "bogus"`},
+		{"invalid log format", "api_token: x\nlog_format: xml\n", nil, "log_format: must be 'text' or 'json'"},
+		{"unknown module type", "api_token: x\nmodules:\n  tls:\n    type: dns\n", nil, `modules.tls.type: must be one of http, ntp, sslcert, got "dns"`},
+		{"unknown module field", "api_token: x\nmodules:\n  tls:\n    type: sslcert\n    fields: [rtt]\n", nil, `modules.tls.fields[0]: unknown sslcert field "rtt"`},
+		{"unknown module account", "api_token: x\nmodules:\n  tls:\n    type: sslcert\n    account: team-a\n", nil, `modules.tls.account: unknown account "team-a"`},
+		{"duplicate account", "accounts:\n  - name: a\n    api_token: x\n  - name: a\n    api_token: y\n", nil, `accounts[1].name: duplicate account name "a"`},
+		{"account missing token", "accounts:\n  - name: a\n", nil, "accounts[0].api_token: API token is required"},
+		{"top level token with accounts", "api_token: x\naccounts:\n  - name: a\n    api_token: y\n", nil, "api_token, api_token_file: must not be set when accounts are configured"},
+		{"invalid account measurement", "accounts:\n  - name: a\n    api_token: x\n    collectors:\n      http:\n        measurements: [-1]\n", nil, "accounts[0].collectors.http.measurements[0]"},
+		{"invalid remote write url", "api_token: x\n", []string{"--remote_write_url", "localhost:9090"}, `remote_write.url: must be an http or https URL, got "localhost:9090"`},
+		{"invalid remote write interval", "api_token: x\nremote_write:\n  url: http://localhost:9090/api/v1/write\n  interval: 0s\n", nil, "remote_write.interval: must be greater than 0"},
+		{"invalid otlp protocol", "api_token: x\n", []string{"--otlp_endpoint", "http://localhost:4317", "--otlp_protocol", "http/json"}, `otlp.protocol: must be 'grpc' or 'http/protobuf', got "http/json"`},
+		{"invalid otlp endpoint", "api_token: x\notlp:\n  endpoint: localhost:4317\n", nil, `otlp.endpoint: must be an http or https URL, got "localhost:4317"`},
+		{"unknown sink type", "api_token: x\nsinks:\n  - type: statsd\n    interval: 1m\n", nil, `sinks[0].type: must be 'influxdb' or 'graphite', got "statsd"`},
+		{"invalid graphite sink", "api_token: x\nsinks:\n  - type: graphite\n    address: graphite\n", nil, `sinks[0].address: must be host:port, got "graphite"`},
+		{"invalid sink interval", "api_token: x\nsinks:\n  - type: influxdb\n    url: http://influxdb:8086/write?db=atlas\n    interval: -1m\n", nil, "sinks[0].interval: must not be negative"},
+		{"negative state retention", "api_token: x\nstate:\n  retention: -1h\n", nil, "state.retention: must not be negative"},
+		{"negative namespace retention", "api_token: x\nstate:\n  namespace_retention:\n    credits: -1h\n", nil, "state.namespace_retention.credits: must not be negative"},
+		{"negative rules threshold", "api_token: x\nrules:\n  credits_below: -1\n", nil, "rules.credits_below: must not be negative"},
+		{"webhook without url", "api_token: x\nnotifications:\n  webhooks:\n    - name: chat\n", nil, `notifications.webhooks[0].url: must be an http or https URL, got ""`},
+		{"unknown webhook preset", "api_token: x\nnotifications:\n  webhooks:\n    - name: chat\n      url: https://example.com\n      preset: teams\n", nil, `notifications.webhooks[0].preset: must be one of alertmanager, discord, matrix, slack, got "teams"`},
+		{"invalid webhook template", "api_token: x\nnotifications:\n  webhooks:\n    - name: chat\n      url: https://example.com\n      template: '{{ .Text'\n", nil, "notifications.webhooks[0].template:"},
+		{"unknown webhook event", "api_token: x\nnotifications:\n  webhooks:\n    - name: chat\n      url: https://example.com\n      events: [probe_lost]\n", nil, `notifications.webhooks[0].events: must be one of probe_disconnected, probe_abandoned, credits_low, api_key_expiring, got "probe_lost"`},
+		{"duplicate webhook name", "api_token: x\nnotifications:\n  webhooks:\n    - name: chat\n      url: https://example.com\n    - name: chat\n      url: https://example.org\n", nil, `notifications.webhooks[1].name: duplicate webhook name "chat"`},
+		{"negative remote write retries", "api_token: x\nremote_write:\n  url: http://localhost:9090/api/v1/write\n  max_retries: -1\n", nil, "remote_write.max_retries: must not be negative, got -1"},
+		{"remote write auth", "api_token: x\nremote_write:\n  url: http://localhost:9090/api/v1/write\n  bearer_token: t\n  basic_auth:\n    username: u\n", nil, "remote_write.basic_auth, remote_write.bearer_token: only one can be set"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadTestConfig(t, tt.content, tt.args...)
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("expected error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestRemoteWriteMaxRetries(t *testing.T) {
+	cfg, err := loadTestConfig(t, "api_token: x\nremote_write:\n  url: http://localhost:9090/api/v1/write\n")
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.RemoteWrite.MaxRetries != nil {
+		t.Errorf("expected the default retries without max_retries, got %d", *cfg.RemoteWrite.MaxRetries)
+	}
+	cfg, err = loadTestConfig(t, "api_token: x\nremote_write:\n  url: http://localhost:9090/api/v1/write\n  max_retries: 0\n")
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.RemoteWrite.MaxRetries == nil || *cfg.RemoteWrite.MaxRetries != 0 {
+		t.Errorf("expected max_retries 0 to disable retries, got %v", cfg.RemoteWrite.MaxRetries)
+	}
+}
+
+func TestConfigAccounts(t *testing.T) {
+	cfg, err := loadTestConfig(t, "api_token: top-level\n", "--base_url", "https://example.com/api")
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if accounts := cfg.accounts(); len(accounts) != 1 || accounts[0].Name != defaultAccount || accounts[0].APIToken != "top-level" {
+		t.Errorf("expected a single default account with the top level token, got %+v", accounts)
+	}
+
+	cfg, err = loadTestConfig(t, `
+accounts:
+  - name: team-a
+    api_token: a
+    collectors:
+      measurement_metadata:
+        limit: 0
+  - name: team-b
+    api_token: b
+    base_url: https://atlas.example.org/api
+    collectors:
+      ntp:
+        measurements: [1001]
+      http:
+        refresh_interval: 1m
+collectors:
+  measurement_metadata:
+    limit: 50
+  ntp:
+    measurements: [2002]
+  http:
+    enabled: false
+    measurements: [3003]
+`, "--base_url", "https://example.com/api", "--sslcert_measurements", "4004")
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	accounts := cfg.accounts()
+	if len(accounts) != 2 {
+		t.Fatalf("expected 2 accounts, got %d", len(accounts))
+	}
+	if accounts[0].BaseURL != "https://example.com/api" || accounts[0].Collectors.NTP.Measurements[0] != 2002 {
+		t.Errorf("expected team-a to use the top level base_url and collectors, got %+v", accounts[0])
+	}
+	if accounts[1].BaseURL != "https://atlas.example.org/api" || accounts[1].Collectors.NTP.Measurements[0] != 1001 {
+		t.Errorf("expected team-b to use its own base_url and collectors, got %+v", accounts[1])
+	}
+	if http := accounts[1].Collectors.HTTP; http.Enabled == nil || *http.Enabled || http.RefreshInterval != time.Minute || http.Measurements[0] != 3003 {
+		t.Errorf("expected team-b to merge its http settings with the top level, got %+v", http)
+	}
+	if limit := accounts[0].Collectors.MeasurementMetadata.Limit; limit == nil || *limit != 0 {
+		t.Errorf("expected the limit of 0 of team-a to override the top level, got %v", limit)
+	}
+	if limit := accounts[1].Collectors.MeasurementMetadata.Limit; limit == nil || *limit != 50 {
+		t.Errorf("expected team-b to use the top level limit, got %v", limit)
+	}
+	for _, account := range accounts {
+		if got := account.Collectors.SSLCert.Measurements; len(got) != 1 || got[0] != 4004 {
+			t.Errorf("expected the sslcert_measurements flag to reach %s, got %v", account.Name, got)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/atlas"
+)
+
+// staleRefreshIntervals is the number of refresh intervals after which the
+// data of a collector is considered stale.
+const staleRefreshIntervals = 3
+
+// health is the readiness of the exporter and every dependency. It is
+// reported by /-/ready?format=json.
+type health struct {
+	Status   string          `json:"status"`
+	Accounts []accountHealth `json:"accounts"`
+}
+
+type accountHealth struct {
+	Name       string            `json:"name"`
+	Status     string            `json:"status"`
+	TokenValid bool              `json:"token_valid"`
+	Collectors []collectorHealth `json:"collectors"`
+}
+
+type collectorHealth struct {
+	Name        string     `json:"name"`
+	Status      string     `json:"status"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+const (
+	statusReady    = "ready"
+	statusNotReady = "not_ready"
+	statusOK       = "ok"
+	statusPending  = "pending"
+	statusStale    = "stale"
+	statusFailing  = "failing"
+)
+
+// health reports the exporter ready when every collector of every account
+// refreshed successfully within staleRefreshIntervals refresh intervals and no
+// API token was rejected. After a reload, collectors that could not keep their
+// previous data are pending without failing readiness for staleRefreshIntervals
+// refresh intervals, so a reload does not take the exporter out of service. It
+// only looks at the result of the background refreshes and never calls the
+// Atlas API.
+func (r *Reloader) health(now time.Time) health {
+	result := health{Status: statusReady, Accounts: []accountHealth{}}
+	state := r.current.Load()
+	for _, account := range state.accounts {
+		accountResult := accountHealth{Name: account.name, Status: statusOK, TokenValid: true, Collectors: []collectorHealth{}}
+		for _, status := range account.exporter.Status() {
+			collector := collectorHealth{Name: status.Name, Status: statusOK}
+			if !status.LastSuccess.IsZero() {
+				lastSuccess := status.LastSuccess
+				collector.LastSuccess = &lastSuccess
+			}
+			if status.Err != nil {
+				collector.LastError = status.Err.Error()
+				var apiErr *atlas.APIError
+				if errors.As(status.Err, &apiErr) && apiErr.Unauthorized() {
+					accountResult.TokenValid = false
+				}
+			}
+			switch {
+			case status.LastSuccess.IsZero() && status.Err == nil:
+				collector.Status = statusPending
+			case status.LastSuccess.IsZero():
+				collector.Status = statusFailing
+			case now.Sub(status.LastSuccess) > staleRefreshIntervals*status.Interval:
+				collector.Status = statusStale
+			}
+			reloading := collector.Status == statusPending && state.reloaded && now.Sub(state.loadedAt) <= staleRefreshIntervals*status.Interval
+			if collector.Status != statusOK && !reloading {
+				accountResult.Status = statusFailing
+			}
+			accountResult.Collectors = append(accountResult.Collectors, collector)
+		}
+		if !accountResult.TokenValid {
+			accountResult.Status = statusFailing
+		}
+		if accountResult.Status != statusOK {
+			result.Status = statusNotReady
+		}
+		result.Accounts = append(result.Accounts, accountResult)
+	}
+	return result
+}
+
+// ReadyHandler reports whether the exported data is fresh, with 503 when it
+// is not. The format=json query parameter returns the status of every account
+// and collector with their last error.
+func (r *Reloader) ReadyHandler(w http.ResponseWriter, req *http.Request) {
+	result := r.health(time.Now())
+	code := http.StatusOK
+	if result.Status != statusReady {
+		code = http.StatusServiceUnavailable
+	}
+	if req.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			logger.Errorf("Failed to write response: %v", err)
+		}
+		return
+	}
+	body := "OK"
+	if code != http.StatusOK {
+		var failing []string
+		for _, account := range result.Accounts {
+			for _, collector := range account.Collectors {
+				if collector.Status != statusOK {
+					failing = append(failing, fmt.Sprintf("%s/%s is %s", account.Name, collector.Name, collector.Status))
+				}
+			}
+			if !account.TokenValid {
+				failing = append(failing, fmt.Sprintf("%s API token was rejected", account.Name))
+			}
+		}
+		body = "Not ready: " + strings.Join(failing, ", ")
+	}
+	w.WriteHeader(code)
+	if _, err := w.Write([]byte(body)); err != nil {
+		logger.Errorf("Failed to write response: %v", err)
+	}
+}
+
+// HealthyHandler reports that the process is alive.
+func HealthyHandler(w http.ResponseWriter, _ *http.Request) {
+	if _, err := w.Write([]byte("OK")); err != nil {
+		logger.Errorf("Failed to write response: %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/urfave/cli/v3"
+)
+
+func TestReadyHandler(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/credits", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Key good-token" {
+			http.Error(w, `{"error":{"title":"Forbidden","detail":"Invalid key"}}`, http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"current_balance": 500}`))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte(`
+refresh_interval: 1h
+accounts:
+  - name: team-a
+    api_token: good-token
+  - name: team-b
+    api_token: revoked-token
+`), 0o600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	app := buildApp()
+	app.Action = func(ctx context.Context, c *cli.Command) error {
+		reloader := NewReloader(ctx, c)
+		if err := reloader.Reload(); err != nil {
+			t.Fatalf("Initial load failed: %v", err)
+		}
+		defer reloader.Stop()
+		_ = reloader.Refresh(ctx, "", "credits")
+
+		recorder := httptest.NewRecorder()
+		reloader.ReadyHandler(recorder, httptest.NewRequest(http.MethodGet, "/-/ready?format=json", nil))
+		if recorder.Code != http.StatusServiceUnavailable {
+			t.Errorf("expected status 503 with a revoked token, got %d", recorder.Code)
+		}
+		var result health
+		if err := json.NewDecoder(recorder.Body).Decode(&result); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if result.Status != statusNotReady || len(result.Accounts) != 2 {
+			t.Fatalf("expected 2 accounts and status not_ready, got %+v", result)
+		}
+		teamA, teamB := result.Accounts[0], result.Accounts[1]
+		if teamA.Status != statusOK || !teamA.TokenValid || teamA.Collectors[0].Name != "credits" || teamA.Collectors[0].LastSuccess == nil {
+			t.Errorf("expected team-a to be ok, got %+v", teamA)
+		}
+		if teamB.Status != statusFailing || teamB.TokenValid || teamB.Collectors[0].Status != statusFailing || !strings.Contains(teamB.Collectors[0].LastError, "Invalid key") {
+			t.Errorf("expected team-b to fail with a rejected token, got %+v", teamB)
+		}
+
+		recorder = httptest.NewRecorder()
+		reloader.ReadyHandler(recorder, httptest.NewRequest(http.MethodGet, "/-/ready", nil))
+		if body := recorder.Body.String(); !strings.Contains(body, "team-b API token was rejected") || strings.Contains(body, "team-a/credits") {
+			t.Errorf("unexpected readiness summary %q", body)
+		}
+
+		stale := reloader.health(time.Now().Add(4 * time.Hour))
+		if collector := stale.Accounts[0].Collectors[0]; collector.Status != statusStale {
+			t.Errorf("expected team-a credits to be stale after 4 refresh intervals, got %s", collector.Status)
+		}
+		return nil
+	}
+	args := []string{"atlas_exporter", "--config.file", path, "--base_url", server.URL}
+	for _, name := range []string{"probe_last_connected", "probe_measurements", "measurement_metadata"} {
+		args = append(args, "--no-collector."+name)
+	}
+	if err := app.Run(t.Context(), args); err != nil {
+		t.Fatalf("Failed to run app: %v", err)
+	}
+}
+
+func TestHealthyHandler(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	HealthyHandler(recorder, httptest.NewRequest(http.MethodGet, "/-/healthy", nil))
+	if recorder.Code != http.StatusOK || recorder.Body.String() != "OK" {
+		t.Errorf("expected 200 OK, got %d %q", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestReadyHandlerAfterReload(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/credits", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"current_balance": 500}`))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	path := filepath.Join(t.TempDir(), "config.yml")
+	writeConfig := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to write config file: %v", err)
+		}
+	}
+	writeConfig("api_token: good-token\nrefresh_interval: 1h\n")
+
+	app := buildApp()
+	app.Action = func(ctx context.Context, c *cli.Command) error {
+		reloader := NewReloader(ctx, c)
+		if err := reloader.Load(); err != nil {
+			t.Fatalf("Initial load failed: %v", err)
+		}
+		defer reloader.Stop()
+		if result := reloader.health(time.Now()); result.Status != statusNotReady {
+			t.Errorf("expected not_ready before the first refresh, got %+v", result)
+		}
+		if err := reloader.Refresh(ctx, "", "credits"); err != nil {
+			t.Fatalf("Refresh failed: %v", err)
+		}
+
+		writeConfig("api_token: good-token\nrefresh_interval: 1h\ncollectors:\n  ntp:\n    enabled: true\n    measurements: [1001]\n")
+		if err := reloader.Load(); err != nil {
+			t.Fatalf("Reload failed: %v", err)
+		}
+		result := reloader.health(time.Now())
+		if result.Status != statusReady {
+			t.Errorf("expected ready after a reload, got %+v", result)
+		}
+		for _, collector := range result.Accounts[0].Collectors {
+			if collector.Name == "ntp" && collector.Status != statusPending {
+				t.Errorf("expected the new ntp collector to be pending, got %s", collector.Status)
+			}
+		}
+		if result = reloader.health(time.Now().Add(4 * time.Hour)); result.Status != statusNotReady {
+			t.Errorf("expected not_ready once ntp has not refreshed for 4 refresh intervals, got %+v", result)
+		}
+		return nil
+	}
+	args := []string{"atlas_exporter", "--config.file", path, "--base_url", server.URL}
+	for _, name := range []string{"probe_last_connected", "probe_measurements", "measurement_metadata"} {
+		args = append(args, "--no-collector."+name)
+	}
+	if err := app.Run(t.Context(), args); err != nil {
+		t.Fatalf("Failed to run app: %v", err)
+	}
+}
@@ -3,16 +3,20 @@ package main
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"net/mail"
 	"os"
 	"os/signal"
 	"sort"
-	"strings"
 	"syscall"
 	"time"
 
 	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/atlas"
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/exporter"
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/otlp"
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/sink"
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/state"
 	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/version"
 	"github.com/Cyb3r-Jak3/common/v5"
 	"github.com/prometheus/client_golang/prometheus"
@@ -23,9 +27,8 @@ import (
 )
 
 var (
-	logger         = logrus.New()
-	AtlasAPIClient *atlas.API
-	versionString  = version.String()
+	logger        = logrus.New()
+	versionString = version.String()
 )
 
 func buildApp() *cli.Command {
@@ -41,7 +44,29 @@ func buildApp() *cli.Command {
 			},
 		},
 		Action: Run,
+		Commands: []*cli.Command{
+			{
+				Name:  "config",
+				Usage: "Work with the exporter configuration",
+				Commands: []*cli.Command{
+					{
+						Name:   "check",
+						Usage:  "Validate the configuration file and flags, then exit",
+						Action: ConfigCheck,
+					},
+				},
+			},
+			pushCommand(),
+			textfileCommand(),
+			rulesCommand(),
+		},
 		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "config.file",
+				Aliases: []string{"c"},
+				Usage:   "Path to a YAML configuration file. Flags and environment variables override values from the file",
+				Sources: cli.EnvVars("ATLAS_EXPORTER_CONFIG_FILE"),
+			},
 			&cli.StringFlag{
 				Name:    "listen_address",
 				Aliases: []string{"l"},
@@ -63,9 +88,14 @@ func buildApp() *cli.Command {
 				Value:   "",
 				Sources: cli.EnvVars("ATLAS_EXPORTER_API_TOKEN"),
 			},
+			&cli.StringFlag{
+				Name:    "api_token_file",
+				Usage:   "Path to a file containing the API token, for example a mounted secret. The file is read again when it changes",
+				Sources: cli.EnvVars("ATLAS_EXPORTER_API_TOKEN_FILE"),
+			},
 			&cli.IntFlag{
 				Name:    "timeout",
-				Usage:   "Timeout for API requests in seconds",
+				Usage:   "Timeout in seconds for each collector refresh against the Atlas API",
 				Value:   60,
 				Sources: cli.EnvVars("ATLAS_EXPORTER_TIMEOUT"),
 			},
@@ -90,6 +120,11 @@ func buildApp() *cli.Command {
 				Value:   "key.pem",
 				Sources: cli.EnvVars("ATLAS_EXPORTER_TLS_KEY_PATH"),
 			},
+			&cli.StringFlag{
+				Name:    "web_config_file",
+				Usage:   "Path to a web configuration file in the exporter-toolkit format enabling TLS, mutual TLS and basic authentication",
+				Sources: cli.EnvVars("ATLAS_EXPORTER_WEB_CONFIG_FILE"),
+			},
 			&cli.StringFlag{
 				Name:    "log_level",
 				Aliases: []string{"ll"},
@@ -110,6 +145,77 @@ func buildApp() *cli.Command {
 					return nil
 				},
 			},
+			&cli.IntSliceFlag{
+				Name:    "http_measurements",
+				Usage:   "IDs of http measurements to export the latest request results for",
+				Sources: cli.EnvVars("ATLAS_EXPORTER_HTTP_MEASUREMENTS"),
+			},
+			&cli.IntFlag{
+				Name:    "measurement_metadata_limit",
+				Usage:   "Maximum number of owned measurements to export metadata for, newest first. 0 disables the metadata collector",
+				Value:   100,
+				Sources: cli.EnvVars("ATLAS_EXPORTER_MEASUREMENT_METADATA_LIMIT"),
+			},
+			&cli.IntSliceFlag{
+				Name:    "ntp_measurements",
+				Usage:   "IDs of ntp measurements to export the latest server results for",
+				Sources: cli.EnvVars("ATLAS_EXPORTER_NTP_MEASUREMENTS"),
+			},
+			&cli.IntSliceFlag{
+				Name:    "sslcert_measurements",
+				Usage:   "IDs of sslcert measurements to export the latest certificate results for",
+				Sources: cli.EnvVars("ATLAS_EXPORTER_SSLCERT_MEASUREMENTS"),
+			},
+			&cli.DurationFlag{
+				Name:    "refresh_interval",
+				Usage:   "Default interval between background refreshes of the data of each collector",
+				Value:   5 * time.Minute,
+				Sources: cli.EnvVars("ATLAS_EXPORTER_REFRESH_INTERVAL"),
+			},
+			&cli.StringMapFlag{
+				Name:    "collector_refresh_intervals",
+				Usage:   "Refresh intervals overriding refresh_interval for individual collectors, e.g. credits=1m,probe_measurements=30m",
+				Sources: cli.EnvVars("ATLAS_EXPORTER_COLLECTOR_REFRESH_INTERVALS"),
+			},
+			&cli.StringFlag{
+				Name:    "otlp_endpoint",
+				Usage:   "URL of an OpenTelemetry collector to send the Atlas metrics to with OTLP. Empty disables OTLP",
+				Sources: cli.EnvVars("ATLAS_EXPORTER_OTLP_ENDPOINT"),
+			},
+			&cli.StringFlag{
+				Name:    "otlp_protocol",
+				Usage:   "OTLP transport protocol (grpc, http/protobuf)",
+				Value:   otlp.ProtocolGRPC,
+				Sources: cli.EnvVars("ATLAS_EXPORTER_OTLP_PROTOCOL"),
+			},
+			&cli.DurationFlag{
+				Name:    "otlp_interval",
+				Usage:   "Interval between OTLP exports",
+				Value:   time.Minute,
+				Sources: cli.EnvVars("ATLAS_EXPORTER_OTLP_INTERVAL"),
+			},
+			&cli.StringFlag{
+				Name:    "remote_write_url",
+				Usage:   "URL of a Prometheus remote write endpoint to send the Atlas metrics to. Empty disables remote write",
+				Sources: cli.EnvVars("ATLAS_EXPORTER_REMOTE_WRITE_URL"),
+			},
+			&cli.DurationFlag{
+				Name:    "remote_write_interval",
+				Usage:   "Interval between sends to the remote write endpoint",
+				Value:   time.Minute,
+				Sources: cli.EnvVars("ATLAS_EXPORTER_REMOTE_WRITE_INTERVAL"),
+			},
+			&cli.StringFlag{
+				Name:    "state_path",
+				Usage:   "Path to a file where the exporter keeps state such as the credit history across restarts. Empty keeps it in memory. Only one process can open the file, so push and textfile need a different path than a running exporter",
+				Sources: cli.EnvVars("ATLAS_EXPORTER_STATE_PATH"),
+			},
+			&cli.DurationFlag{
+				Name:    "state_retention",
+				Usage:   "How long records such as the credit history are kept in the state store. 0 keeps them forever",
+				Value:   30 * 24 * time.Hour,
+				Sources: cli.EnvVars("ATLAS_EXPORTER_STATE_RETENTION"),
+			},
 			&cli.StringFlag{
 				Name:    "base_url",
 				Usage:   "Base URL for the Atlas API. Useful for testing or custom deployments.",
@@ -120,47 +226,89 @@ func buildApp() *cli.Command {
 		},
 		EnableShellCompletion: true,
 	}
+	app.Flags = append(app.Flags, collectorFlags()...)
 	sort.Sort(cli.FlagsByName(app.Flags))
 	return app
 }
 
 func Run(ctx context.Context, c *cli.Command) error {
-	err := SetLogLevel(c)
-	if err != nil {
-		logger.Fatalf("Failed to set log level: %v", err)
-	}
-
-	apiToken := strings.TrimSpace(c.String("api_token"))
-	if apiToken == "" {
-		logger.Fatal("API token is required. Please set the API token using the --api_token flag or the ATLAS_API_TOKEN environment variable.")
-	}
-	apiClientOptions := []atlas.Option{
-		atlas.WithUserAgent("go-atlas-stats-exporter/" + version.Version),
-		atlas.WithAPIToken(apiToken),
-		atlas.WithBaseURL(c.String("base_url")),
-	}
-
-	if logger.Level >= logrus.DebugLevel {
-		apiClientOptions = append(apiClientOptions, atlas.WithDebug(true))
-	}
-	AtlasAPIClient, err = atlas.New(apiClientOptions...)
-	if err != nil {
-		logger.Fatalf("Failed to initialize Atlas API client: %v", err)
+	reloader := NewReloader(ctx, c)
+	if err := reloader.Reload(); err != nil {
+		return err
 	}
-	scrapeTimeout := c.Int("timeout")
+	defer reloader.Stop()
 	reg := prometheus.NewRegistry()
 	reg.MustRegister(
 		collectors.NewGoCollector(),
 		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
-		BuildInfoCollector(),
-		CreditsCollector(ctx, scrapeTimeout),
-		ProbeLastConnectedCollectorFactory(ctx, scrapeTimeout),
-		ProbeMeasurementsCollectorFactory(ctx, scrapeTimeout),
 	)
+	reg.MustRegister(atlasCollectors(reloader)...)
+	cfg := reloader.Config()
 	logger.Infof("Starting atlas exporter (Version: %s)", version.Version)
-	listenAddress := c.String("listen_address")
-	metricsPath := c.String("metrics_path")
-	tlsEnabled := c.Bool("tls_enabled")
+	listenAddress := cfg.ListenAddress
+	metricsPath := cfg.MetricsPath
+	var certificates *CertificateReloader
+	certPath, keyPath, tlsEnabled := cfg.certificatePaths()
+	if tlsEnabled {
+		var err error
+		if certificates, err = NewCertificateReloader(certPath, keyPath); err != nil {
+			return err
+		}
+		reg.MustRegister(certificates)
+		watchCtx, stopWatching := context.WithCancel(ctx)
+		defer stopWatching()
+		go certificates.Run(watchCtx, certificateReloadInterval)
+	}
+	// atlasRegistry holds only the Atlas metrics, which are sent to the
+	// remote write and OTLP endpoints.
+	atlasRegistry := prometheus.NewRegistry()
+	atlasRegistry.MustRegister(atlasCollectors(reloader)...)
+	outputCtx, stopOutputs := context.WithCancel(ctx)
+	defer stopOutputs()
+	store := reloader.Store()
+	if collector, ok := store.(prometheus.Collector); ok {
+		reg.MustRegister(collector)
+	}
+	go state.Maintain(outputCtx, store, cfg.State.compactionInterval(), logger.WithField("component", "state"))
+	if cfg.RemoteWrite.enabled() {
+		remoteWrite, err := cfg.RemoteWrite.client()
+		if err != nil {
+			return err
+		}
+		reg.MustRegister(remoteWrite)
+		logger.Infof("Sending metrics to %s every %s", cfg.RemoteWrite.URL, cfg.RemoteWrite.Interval)
+		go remoteWrite.Run(outputCtx, atlasRegistry, cfg.RemoteWrite.Interval)
+	}
+	if cfg.OTLP.enabled() {
+		otlpExporter, err := cfg.OTLP.exporter(outputCtx)
+		if err != nil {
+			return err
+		}
+		reg.MustRegister(otlpExporter)
+		logger.Infof("Exporting metrics to %s with OTLP (%s) every %s", cfg.OTLP.Endpoint, cfg.OTLP.Protocol, cfg.OTLP.Interval)
+		go otlpExporter.Run(outputCtx, atlasRegistry, cfg.OTLP.Interval)
+	}
+	if len(cfg.Sinks) > 0 {
+		runner := sink.NewRunner(atlasRegistry, logger)
+		reg.MustRegister(runner)
+		for _, sinkConfig := range cfg.Sinks {
+			s, err := sinkConfig.sink()
+			if err != nil {
+				return err
+			}
+			logger.Infof("Sending metrics to %s every %s", s.Name(), sinkConfig.interval())
+			go runner.Run(outputCtx, s, sinkConfig.interval())
+		}
+	}
+	if cfg.Notifications.enabled() {
+		notifier, err := cfg.Notifications.notifier(atlasRegistry, store, cfg.Rules)
+		if err != nil {
+			return err
+		}
+		reg.MustRegister(notifier)
+		logger.Infof("Sending notifications to %d webhooks every %s", len(cfg.Notifications.Webhooks), cfg.Notifications.interval())
+		go notifier.Run(outputCtx, cfg.Notifications.interval())
+	}
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
@@ -173,6 +321,8 @@ func Run(ctx context.Context, c *cli.Command) error {
 					<h2>Example</h2>
 					<p>Metrics for measurement configured in configuration file:</p>
 					<p><a href="` + metricsPath + `">` + r.Host + metricsPath + `</a></p>
+					<p>Metrics for a single measurement:</p>
+					<p><a href="/atlas?measurement=1001&amp;module=ntp">` + r.Host + `/atlas?measurement=1001&amp;module=ntp</a></p>
 					<h2>More information</h2>
 					<p><a href="https://github.com/Cyb3r-Jak3/atlas_exporter">github.com/Cyb3r-Jak3/atlas_exporter</a></p>
 					</body>
@@ -192,59 +342,82 @@ func Run(ctx context.Context, c *cli.Command) error {
 			return
 		}
 	})
-	http.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
-		_, rootDataErr := AtlasAPIClient.GetRootData(r.Context()) // Check if API is reachable
-		if rootDataErr != nil {
-			logger.Errorf("Failed to get root data: %v", rootDataErr)
-			w.WriteHeader(503)
-			_, handleErr := w.Write([]byte("Failed to get root data"))
-			if handleErr != nil {
-				logger.Errorf("Failed to write response: %v", handleErr)
-			}
-			return
-		}
-		w.WriteHeader(http.StatusOK)
-		_, handleErr := w.Write([]byte("OK"))
-		if handleErr != nil {
-			logger.Errorf("Failed to write response: %v", handleErr)
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			return
-		}
-	})
+	http.HandleFunc("/-/healthy", HealthyHandler)
+	http.HandleFunc("/-/ready", reloader.ReadyHandler)
+	// /healthz is kept for existing probes and reports readiness.
+	http.HandleFunc("/healthz", reloader.ReadyHandler)
+	http.HandleFunc("/-/refresh", reloader.RefreshHandler)
+	http.HandleFunc("/-/reload", reloader.ReloadHandler)
+	http.HandleFunc("/atlas", reloader.TargetHandler)
 	http.Handle(metricsPath, promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
 
 	logger.Infof("Listening for %s on %s (TLS: %v)", metricsPath, listenAddress, tlsEnabled)
+	listener, err := net.Listen("tcp", listenAddress)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", listenAddress, err)
+	}
 	httpServer := &http.Server{
 		Addr:              listenAddress,
+		Handler:           reloader.BasicAuth(http.DefaultServeMux),
 		ReadHeaderTimeout: 5 * time.Second,
 	}
-	// Channel to listen for errors from ListenAndServe
+	// Channel to listen for errors from serve
 	serverErr := make(chan error, 1)
 
 	// Start server in a goroutine
 	go func() {
-		if tlsEnabled {
-			serverErr <- httpServer.ListenAndServeTLS(
-				c.String("tls_cert_chain_path"),
-				c.String("tls_key_path"),
-			)
-		} else {
-			serverErr <- httpServer.ListenAndServe()
-		}
+		serverErr <- serve(listener, httpServer, cfg, certificates)
 	}()
 
-	// Listen for interrupt signal for graceful shutdown
+	// Listen for interrupt signal for graceful shutdown and SIGHUP to reload the configuration and certificate
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
-	select {
-	case <-quit:
-		logger.Info("Shutting down server...")
-		ShutdownContext, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-		return httpServer.Shutdown(ShutdownContext)
-	case ServerErr := <-serverErr:
-		return ServerErr
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for {
+		select {
+		case <-hup:
+			if err := reloader.Reload(); err != nil {
+				logger.WithError(err).Error("Failed to reload configuration")
+			}
+			if certificates != nil {
+				if err := certificates.Reload(); err != nil {
+					logger.WithError(err).Error("Failed to reload TLS certificate")
+				}
+			}
+		case <-quit:
+			logger.Info("Shutting down server...")
+			ShutdownContext, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			return httpServer.Shutdown(ShutdownContext)
+		case ServerErr := <-serverErr:
+			return ServerErr
+		}
+	}
+}
+
+// buildExporter creates the exporter refreshing the enabled collectors of an
+// account from the Atlas API and keeping their state in store.
+func buildExporter(cfg *Config, account AccountConfig, client *atlas.API, store state.Store) (*exporter.Exporter, error) {
+	settings := account.Collectors
+	opts := []exporter.Option{
+		exporter.WithCollectors(cfg.enabledCollectors(settings)...),
+		exporter.WithRefreshInterval(cfg.RefreshInterval),
+		exporter.WithTimeout(time.Duration(cfg.Timeout) * time.Second),
+		exporter.WithSSLCertMeasurements(settings.SSLCert.Measurements...),
+		exporter.WithHTTPMeasurements(settings.HTTP.Measurements...),
+		exporter.WithNTPMeasurements(settings.NTP.Measurements...),
+		exporter.WithStore(store),
+	}
+	if limit := settings.MeasurementMetadata.Limit; limit != nil {
+		opts = append(opts, exporter.WithMeasurementMetadataLimit(*limit))
+	}
+	for name, collector := range settings.byName() {
+		if collector.RefreshInterval > 0 {
+			opts = append(opts, exporter.WithCollectorRefreshInterval(name, collector.RefreshInterval))
+		}
 	}
+	return exporter.New(client, logger, opts...)
 }
 
 func main() {
@@ -256,8 +429,8 @@ func main() {
 	}
 }
 
-func SetLogLevel(c *cli.Command) error {
-	switch c.String("log_format") {
+func SetLogLevel(cfg *Config) error {
+	switch cfg.LogFormat {
 	case "json":
 		logger.SetFormatter(&logrus.JSONFormatter{
 			TimestampFormat: time.RFC3339,
@@ -273,7 +446,7 @@ func SetLogLevel(c *cli.Command) error {
 			FullTimestamp:   true,
 		})
 	}
-	logLevel := c.String("log_level")
+	logLevel := cfg.LogLevel
 	level, err := logrus.ParseLevel(logLevel)
 	if err != nil {
 		return fmt.Errorf("invalid log level: %s, error: %w", logLevel, err)
@@ -1 +1,38 @@
 package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/atlas"
+)
+
+// setupTestAPIClient creates an API client pointed at a test server for the duration of the test.
+func setupTestAPIClient(t *testing.T) (*http.ServeMux, *atlas.API) {
+	t.Helper()
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	client, err := atlas.New(
+		atlas.WithAPIToken("test-token"),
+		atlas.WithBaseURL(server.URL),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create test API client: %v", err)
+	}
+	return mux, client
+}
+
+func serveJSON(t *testing.T, mux *http.ServeMux, pattern string, body any) {
+	t.Helper()
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("Failed to marshal response for %s: %v", pattern, err)
+	}
+	mux.HandleFunc(pattern, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(encoded)
+	})
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/notify"
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/state"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// defaultNotificationInterval is the interval between evaluations of the
+// events when interval is not set.
+const defaultNotificationInterval = time.Minute
+
+// NotificationsConfig sends the events detected in the Atlas metrics to
+// webhooks every Interval, with the thresholds of the rules block.
+// Notifications are disabled without webhooks.
+type NotificationsConfig struct {
+	Interval time.Duration   `yaml:"interval"`
+	Webhooks []WebhookConfig `yaml:"webhooks"`
+}
+
+// WebhookConfig is a webhook receiving the events. The payload is rendered
+// with Template, or the Preset when it is empty. Empty Events sends every
+// kind of event, a zero RepeatInterval sends firing events once and a nil
+// SendResolved sends resolution messages.
+type WebhookConfig struct {
+	Name           string            `yaml:"name"`
+	URL            string            `yaml:"url"`
+	Preset         string            `yaml:"preset"`
+	Template       string            `yaml:"template"`
+	Headers        map[string]string `yaml:"headers"`
+	Events         []string          `yaml:"events"`
+	RepeatInterval time.Duration     `yaml:"repeat_interval"`
+	SendResolved   *bool             `yaml:"send_resolved"`
+	Timeout        time.Duration     `yaml:"timeout"`
+}
+
+// enabled reports whether any webhook is configured.
+func (c *NotificationsConfig) enabled() bool {
+	return len(c.Webhooks) > 0
+}
+
+// interval returns the interval between evaluations.
+func (c *NotificationsConfig) interval() time.Duration {
+	if c.Interval == 0 {
+		return defaultNotificationInterval
+	}
+	return c.Interval
+}
+
+// validate checks the notification settings. Errors are prefixed with the
+// key path of the notifications block.
+func (c *NotificationsConfig) validate(prefix string) []error {
+	var errs []error
+	if c.Interval < 0 {
+		errs = append(errs, fmt.Errorf("%s.interval: must not be negative, got %s", prefix, c.Interval))
+	}
+	names := map[string]bool{}
+	for i := range c.Webhooks {
+		webhook := &c.Webhooks[i]
+		if webhook.Name != "" && names[webhook.Name] {
+			errs = append(errs, fmt.Errorf("%s.webhooks[%d].name: duplicate webhook name %q", prefix, i, webhook.Name))
+		}
+		names[webhook.Name] = true
+		errs = append(errs, webhook.validate(fmt.Sprintf("%s.webhooks[%d]", prefix, i))...)
+	}
+	return errs
+}
+
+// validate checks the webhook settings. Errors are prefixed with the key path
+// of the webhook.
+func (c *WebhookConfig) validate(prefix string) []error {
+	var errs []error
+	if c.Name == "" {
+		errs = append(errs, fmt.Errorf("%s.name: must not be empty", prefix))
+	}
+	if parsed, err := url.Parse(c.URL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		errs = append(errs, fmt.Errorf("%s.url: must be an http or https URL, got %q", prefix, c.URL))
+	}
+	switch {
+	case c.Preset != "" && c.Template != "":
+		errs = append(errs, fmt.Errorf("%s.preset, %s.template: only one can be set", prefix, prefix))
+	case c.Preset != "" && !slices.Contains(notify.Presets(), c.Preset):
+		errs = append(errs, fmt.Errorf("%s.preset: must be one of %s, got %q", prefix, strings.Join(notify.Presets(), ", "), c.Preset))
+	case c.Template != "":
+		if _, err := notify.ParseTemplate(c.Template); err != nil {
+			errs = append(errs, fmt.Errorf("%s.template: %w", prefix, err))
+		}
+	}
+	for _, event := range c.Events {
+		if !slices.Contains(notify.Kinds(), event) {
+			errs = append(errs, fmt.Errorf("%s.events: must be one of %s, got %q", prefix, strings.Join(notify.Kinds(), ", "), event))
+		}
+	}
+	if c.RepeatInterval < 0 {
+		errs = append(errs, fmt.Errorf("%s.repeat_interval: must not be negative, got %s", prefix, c.RepeatInterval))
+	}
+	if c.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("%s.timeout: must not be negative, got %s", prefix, c.Timeout))
+	}
+	return errs
+}
+
+// webhook creates the webhook of the configuration.
+func (c *WebhookConfig) webhook() (*notify.Webhook, error) {
+	opts := []notify.WebhookOption{
+		notify.WithHeaders(c.Headers),
+		notify.WithKinds(c.Events...),
+		notify.WithRepeatInterval(c.RepeatInterval),
+	}
+	switch {
+	case c.Template != "":
+		opts = append(opts, notify.WithTemplate(c.Template))
+	case c.Preset != "":
+		opts = append(opts, notify.WithPreset(c.Preset))
+	}
+	if c.SendResolved != nil {
+		opts = append(opts, notify.WithSendResolved(*c.SendResolved))
+	}
+	if c.Timeout > 0 {
+		opts = append(opts, notify.WithTimeout(c.Timeout))
+	}
+	webhook, err := notify.NewWebhook(c.Name, c.URL, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook %s: %w", c.Name, err)
+	}
+	return webhook, nil
+}
+
+// notifier creates a notifier for the metrics of g with the thresholds of
+// rules, keeping the sent events in store.
+func (c *NotificationsConfig) notifier(g prometheus.Gatherer, store state.Store, rules RulesConfig) (*notify.Notifier, error) {
+	rules = rules.withDefaults()
+	opts := []notify.Option{notify.WithThresholds(notify.Thresholds{
+		ProbeDisconnectedFor: rules.ProbeDisconnectedFor,
+		CreditsBelow:         float64(rules.CreditsBelow),
+		KeyExpiresWithin:     rules.KeyExpiresWithin,
+	})}
+	for i := range c.Webhooks {
+		webhook, err := c.Webhooks[i].webhook()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, notify.WithWebhook(webhook))
+	}
+	notifier, err := notify.New(g, store, logger.WithField("component", "notify"), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create notifier: %w", err)
+	}
+	return notifier, nil
+}
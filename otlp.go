@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/otlp"
+)
+
+// OTLPConfig sends the Atlas metrics to an OpenTelemetry collector every
+// Interval. It is disabled when Endpoint is empty. A zero Timeout uses the
+// default of the OTLP exporter.
+type OTLPConfig struct {
+	Endpoint           string            `yaml:"endpoint"`
+	Protocol           string            `yaml:"protocol"`
+	Interval           time.Duration     `yaml:"interval"`
+	Timeout            time.Duration     `yaml:"timeout"`
+	Headers            map[string]string `yaml:"headers"`
+	ResourceAttributes map[string]string `yaml:"resource_attributes"`
+}
+
+// enabled reports whether metrics are sent to an OpenTelemetry collector.
+func (c *OTLPConfig) enabled() bool {
+	return c.Endpoint != ""
+}
+
+// validate checks the OTLP settings. Errors are prefixed with the key path of
+// the otlp block.
+func (c *OTLPConfig) validate(prefix string) []error {
+	if !c.enabled() {
+		return nil
+	}
+	var errs []error
+	if parsed, err := url.Parse(c.Endpoint); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		errs = append(errs, fmt.Errorf("%s.endpoint: must be an http or https URL, got %q", prefix, c.Endpoint))
+	}
+	if c.Protocol != otlp.ProtocolGRPC && c.Protocol != otlp.ProtocolHTTP {
+		errs = append(errs, fmt.Errorf("%s.protocol: must be '%s' or '%s', got %q", prefix, otlp.ProtocolGRPC, otlp.ProtocolHTTP, c.Protocol))
+	}
+	if c.Interval <= 0 {
+		errs = append(errs, fmt.Errorf("%s.interval: must be greater than 0, got %s", prefix, c.Interval))
+	}
+	if c.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("%s.timeout: must not be negative, got %s", prefix, c.Timeout))
+	}
+	return errs
+}
+
+// exporter creates the OTLP exporter of the configuration.
+func (c *OTLPConfig) exporter(ctx context.Context) (*otlp.Exporter, error) {
+	opts := []otlp.Option{
+		otlp.WithHeaders(c.Headers),
+		otlp.WithResourceAttributes(c.ResourceAttributes),
+		otlp.WithLogger(logger.WithField("component", "otlp")),
+	}
+	if c.Timeout > 0 {
+		opts = append(opts, otlp.WithTimeout(c.Timeout))
+	}
+	exporter, err := otlp.New(ctx, c.Protocol, c.Endpoint, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+	return exporter, nil
+}
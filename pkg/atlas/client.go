@@ -2,13 +2,17 @@ package atlas
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/http/httputil"
+	"net/url"
 	"regexp"
+	"strings"
+	"sync/atomic"
 
 	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/version"
 )
@@ -18,16 +22,42 @@ var (
 )
 
 // API holds the configuration for the current API client. A client should not
-// be modified concurrently.
+// be modified concurrently, except for its API token with SetAPIToken.
 type API struct {
+	// APIToken is the API token used to authenticate requests when it is set.
+	//
+	// Deprecated: Use WithAPIToken or SetAPIToken, which can rotate the token
+	// while requests are in flight, and Token to read it. The field is not
+	// filled by them, and takes precedence over them when it is set.
 	APIToken   string
+	apiToken   atomic.Pointer[string]
 	BaseURL    string
 	UserAgent  string
 	headers    http.Header
 	httpClient *http.Client
+	metrics    *Metrics
 	Debug      bool
 }
 
+// APIError is returned when the Atlas API responds with an error status code.
+type APIError struct {
+	StatusCode int
+	Title      string
+	Detail     string
+}
+
+func (e *APIError) Error() string {
+	if e.Detail != "" {
+		return fmt.Sprintf("atlas API returned status %d: %s", e.StatusCode, e.Detail)
+	}
+	return fmt.Sprintf("atlas API returned status %d", e.StatusCode)
+}
+
+// Unauthorized reports whether the API token was rejected.
+func (e *APIError) Unauthorized() bool {
+	return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+}
+
 func New(opts ...Option) (*API, error) {
 	api := &API{
 		BaseURL:    "https://atlas.ripe.net/api/v2",
@@ -42,9 +72,51 @@ func New(opts ...Option) (*API, error) {
 		}
 	}
 
+	if api.metrics != nil {
+		baseURL, err := url.Parse(api.BaseURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base URL: %w", err)
+		}
+		transport := api.httpClient.Transport
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+		// Copy the client so that instrumenting it does not modify a shared client.
+		instrumentedClient := *api.httpClient
+		instrumentedClient.Transport = &instrumentedTransport{
+			next:     transport,
+			metrics:  api.metrics,
+			basePath: baseURL.Path,
+		}
+		api.httpClient = &instrumentedClient
+	}
+
 	return api, nil
 }
 
+// Token returns the API token used to authenticate requests: the deprecated
+// APIToken field when it is set, otherwise the token of WithAPIToken or
+// SetAPIToken.
+func (api *API) Token() string {
+	if api.APIToken != "" {
+		return api.APIToken
+	}
+	if token := api.apiToken.Load(); token != nil {
+		return *token
+	}
+	return ""
+}
+
+// SetAPIToken replaces the API token. It is safe to call while requests are
+// in flight, which keep the token they started with.
+func (api *API) SetAPIToken(token string) error {
+	if token == "" {
+		return fmt.Errorf("API token cannot be empty")
+	}
+	api.apiToken.Store(&token)
+	return nil
+}
+
 // copyHeader copies all headers for `source` and sets them on `target`.
 // based on https://godoc.org/github.com/golang/gddo/httputil/header#Copy
 func copyHeader(target, source http.Header) {
@@ -72,8 +144,9 @@ func (api *API) request(ctx context.Context, method, uri string, reqBody io.Read
 	copyHeader(combinedHeaders, headers)
 	req.Header = combinedHeaders
 
-	if api.APIToken != "" {
-		req.Header.Set("Authorization", "Key "+api.APIToken)
+	apiToken := api.Token()
+	if apiToken != "" {
+		req.Header.Set("Authorization", "Key "+apiToken)
 	}
 
 	if api.UserAgent != "" {
@@ -91,7 +164,7 @@ func (api *API) request(ctx context.Context, method, uri string, reqBody io.Read
 		}
 
 		// Strip out any sensitive information from the request payload.
-		sensitiveKeys := []string{api.APIToken}
+		sensitiveKeys := []string{apiToken}
 		for _, key := range sensitiveKeys {
 			if key != "" {
 				valueRegex := regexp.MustCompile(fmt.Sprintf("(?m)%s", key))
@@ -104,7 +177,7 @@ func (api *API) request(ctx context.Context, method, uri string, reqBody io.Read
 	resp, err := api.httpClient.Do(req)
 
 	if err != nil {
-		return nil, fmt.Errorf("HTTP request failed: %w", err)
+		return nil, fmt.Errorf("HTTP request failed: %w", redactURLError(err, apiToken))
 	}
 	defer resp.Body.Close()
 
@@ -121,9 +194,35 @@ func (api *API) request(ctx context.Context, method, uri string, reqBody io.Read
 		return nil, fmt.Errorf("could not read response body: %w", err)
 	}
 
+	if resp.StatusCode >= http.StatusBadRequest {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		var errorResponse struct {
+			Error struct {
+				Title  string `json:"title"`
+				Detail string `json:"detail"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(respBody, &errorResponse) == nil {
+			apiErr.Title = errorResponse.Error.Title
+			apiErr.Detail = errorResponse.Error.Detail
+		}
+		return nil, apiErr
+	}
+
 	return &APIResponseInfo{
 		Body:       respBody,
 		StatusCode: resp.StatusCode,
 		Headers:    resp.Header,
 	}, nil
 }
+
+// redactURLError removes the API token from the URL of err, in case a caller
+// puts it in the path, such as /keys/<token>/ for the details of a key.
+func redactURLError(err error, apiToken string) error {
+	var urlErr *url.Error
+	if apiToken == "" || !errors.As(err, &urlErr) {
+		return err
+	}
+	redacted := strings.NewReplacer(apiToken, "[redacted]", url.PathEscape(apiToken), "[redacted]").Replace(urlErr.URL)
+	return &url.Error{Op: urlErr.Op, URL: redacted, Err: urlErr.Err}
+}
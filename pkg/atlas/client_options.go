@@ -12,11 +12,7 @@ type Option func(*API) error
 // WithAPIToken sets the API token for authentication with the Atlas API.
 func WithAPIToken(token string) Option {
 	return func(api *API) error {
-		if token == "" {
-			return fmt.Errorf("API token cannot be empty")
-		}
-		api.APIToken = token
-		return nil
+		return api.SetAPIToken(token)
 	}
 }
 
@@ -64,6 +60,14 @@ func WithDebug(debug bool) Option {
 	}
 }
 
+// WithMetrics records the requests made by the client in m.
+func WithMetrics(m *Metrics) Option {
+	return func(api *API) error {
+		api.metrics = m
+		return nil
+	}
+}
+
 // WithTimeout allows you to set a custom timeout for the HTTP client.
 func WithTimeout(timeout int) Option {
 	return func(api *API) error {
@@ -1,8 +1,10 @@
 package atlas
 
 import (
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 )
@@ -51,3 +53,103 @@ func Test_ClientOptions(t *testing.T) {
 func teardown() {
 	server.Close()
 }
+
+func Test_SetAPIToken(t *testing.T) {
+	setup()
+	defer teardown()
+
+	var mu sync.Mutex
+	seen := map[string]int{}
+	mux.HandleFunc("/root/", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen[r.Header.Get("Authorization")]++
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	})
+
+	if err := client.SetAPIToken(""); err == nil {
+		t.Error("expected an empty token to be rejected")
+	}
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Go(func() {
+			if _, err := client.GetRootData(t.Context()); err != nil {
+				t.Errorf("GetRootData failed: %v", err)
+			}
+		})
+		if i == 10 {
+			if err := client.SetAPIToken("rotated-token"); err != nil {
+				t.Errorf("SetAPIToken failed: %v", err)
+			}
+		}
+	}
+	wg.Wait()
+	if client.Token() != "rotated-token" {
+		t.Errorf("expected the rotated token, got %q", client.Token())
+	}
+	if _, err := client.GetRootData(t.Context()); err != nil {
+		t.Fatalf("GetRootData failed: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for header := range seen {
+		if header != "Key test-token" && header != "Key rotated-token" {
+			t.Errorf("unexpected Authorization header %q", header)
+		}
+	}
+	if seen["Key rotated-token"] == 0 {
+		t.Error("expected requests with the rotated token")
+	}
+}
+
+func Test_DeprecatedAPIToken(t *testing.T) {
+	setup()
+	defer teardown()
+
+	var header string
+	mux.HandleFunc("/root/", func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	})
+
+	legacy, err := New(WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if _, err = legacy.GetRootData(t.Context()); !errors.Is(err, ErrMissingToken) {
+		t.Fatalf("expected ErrMissingToken, got %v", err)
+	}
+	legacy.APIToken = "legacy-token"
+	if legacy.Token() != "legacy-token" {
+		t.Errorf("expected the field token, got %q", legacy.Token())
+	}
+	if _, err = legacy.GetRootData(t.Context()); err != nil {
+		t.Fatalf("GetRootData failed: %v", err)
+	}
+	if header != "Key legacy-token" {
+		t.Errorf("expected the field token to authenticate, got %q", header)
+	}
+}
+
+func Test_requestAPIError(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/credits", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"error": {"status": 500, "title": "Internal Server Error"}}`))
+	})
+	// Responses with a status of 400 or more are errors rather than bodies
+	// to decode.
+	_, err := client.GetCredits(t.Context())
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError || apiErr.Unauthorized() {
+		t.Fatalf("expected an APIError with status 500, got %v", err)
+	}
+	if apiErr.Title != "Internal Server Error" {
+		t.Errorf("unexpected error title %q", apiErr.Title)
+	}
+}
@@ -24,7 +24,7 @@ type CreditAPIResponse struct {
 }
 
 func (api *API) GetCredits(ctx context.Context) (*CreditAPIResponse, error) {
-	if api.APIToken == "" {
+	if api.Token() == "" {
 		return nil, ErrMissingToken
 	}
 	resp, err := api.request(ctx, "GET", "/credits", nil, nil)
@@ -0,0 +1,31 @@
+package atlas
+
+import "context"
+
+// HTTPRequestResult is the outcome of a single HTTP request made by a probe.
+type HTTPRequestResult struct {
+	AddressFamily   int     `json:"af"`
+	DstAddr         string  `json:"dst_addr"`
+	Method          string  `json:"method"`
+	StatusCode      int     `json:"res"`
+	ResponseTime    float64 `json:"rt"`
+	TimeToFirstByte float64 `json:"ttfb,omitempty"`
+	HeaderSize      int     `json:"hsize"`
+	BodySize        int     `json:"bsize"`
+	Version         string  `json:"ver"`
+	Error           string  `json:"err,omitempty"`
+}
+
+// HTTPResult is a single probe result of an http measurement.
+type HTTPResult struct {
+	MeasurementID int                 `json:"msm_id"`
+	ProbeID       int                 `json:"prb_id"`
+	Timestamp     int                 `json:"timestamp"`
+	URI           string              `json:"uri"`
+	Results       []HTTPRequestResult `json:"result"`
+}
+
+// GetLatestHTTPResults returns the latest result of every probe of the http measurement.
+func (api *API) GetLatestHTTPResults(ctx context.Context, measurementID int) ([]HTTPResult, error) {
+	return getLatestResults[HTTPResult](ctx, api, measurementID)
+}
@@ -0,0 +1,58 @@
+package atlas
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestAPI_GetLatestHTTPResults(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/measurements/1002/latest/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			t.Errorf("Expected GET request, got %s", r.Method)
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, err := w.Write([]byte(`[
+			{
+				"fw": 5080,
+				"msm_id": 1002,
+				"prb_id": 1,
+				"timestamp": 1752371567,
+				"type": "http",
+				"uri": "https://example.com/",
+				"result": [
+					{"af": 4, "bsize": 1256, "dst_addr": "93.184.216.34", "hsize": 312, "method": "GET", "res": 200, "rt": 120.5, "ttfb": 80.25, "ver": "1.1"},
+					{"af": 6, "dst_addr": "2606:2800:220:1:248:1893:25c8:1946", "method": "GET", "err": "connect: Network is unreachable"}
+				]
+			}
+		]`))
+		if err != nil {
+			return
+		}
+	})
+	results, err := client.GetLatestHTTPResults(context.Background(), 1002)
+	if err != nil {
+		t.Fatalf("GetLatestHTTPResults failed: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 result, got %d", len(results))
+	}
+	if results[0].URI != "https://example.com/" {
+		t.Errorf("Expected URI 'https://example.com/', got '%s'", results[0].URI)
+	}
+	if len(results[0].Results) != 2 {
+		t.Fatalf("Expected 2 request results, got %d", len(results[0].Results))
+	}
+	if results[0].Results[0].StatusCode != 200 || results[0].Results[0].TimeToFirstByte != 80.25 {
+		t.Errorf("Unexpected request result %+v", results[0].Results[0])
+	}
+	if results[0].Results[1].Error == "" {
+		t.Errorf("Expected an error for the second request result")
+	}
+}
@@ -0,0 +1,60 @@
+package atlas
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/Cyb3r-Jak3/common/v5"
+)
+
+// APIKey is the description of an API key. ValidTo is zero when the key does
+// not expire.
+type APIKey struct {
+	UUID      string               `json:"uuid"`
+	Label     string               `json:"label"`
+	Enabled   bool                 `json:"enabled"`
+	IsActive  bool                 `json:"is_active"`
+	ValidFrom common.ResilientTime `json:"valid_from"`
+	ValidTo   common.ResilientTime `json:"valid_to"`
+	CreatedAt common.ResilientTime `json:"created_at"`
+}
+
+// apiKeysResponse is a page of the API keys of the account.
+type apiKeysResponse struct {
+	Count   int      `json:"count"`
+	Next    string   `json:"next"`
+	Results []APIKey `json:"results"`
+}
+
+// GetAPIKey returns the description of the API key used by the client. The
+// key is looked up in the list of the keys of the account rather than by its
+// UUID, which is the token itself and would end up in the path of the request
+// and in the logs of proxies. The key must be allowed to list the keys.
+func (api *API) GetAPIKey(ctx context.Context) (*APIKey, error) {
+	apiToken := api.Token()
+	if apiToken == "" {
+		return nil, ErrMissingToken
+	}
+	page := 1
+	for {
+		resp, err := api.request(ctx, "GET", fmt.Sprintf("/keys/?page=%d", page), nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get API keys: %w", err)
+		}
+		var keysResponse apiKeysResponse
+		if err = json.Unmarshal(resp.Body, &keysResponse); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal API keys response: %w", err)
+		}
+		for i := range keysResponse.Results {
+			if keysResponse.Results[i].UUID == apiToken {
+				return &keysResponse.Results[i], nil
+			}
+		}
+		if keysResponse.Count == 0 || keysResponse.Next == "" {
+			return nil, errors.New("the API key is not in the keys of the account")
+		}
+		page++
+	}
+}
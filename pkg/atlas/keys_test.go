@@ -0,0 +1,81 @@
+package atlas
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPI_GetAPIKey(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/keys/", func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "Key test-token" {
+			t.Errorf("Expected the key to authenticate, got %q", auth)
+		}
+		if strings.Contains(r.URL.String(), "test-token") {
+			t.Errorf("Expected the key to stay out of the URL, got %s", r.URL)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("page") == "1" {
+			_, _ = w.Write([]byte(`{
+				"count": 2,
+				"next": "https://atlas.ripe.net/api/v2/keys/?page=2",
+				"results": [{"uuid": "other-token", "label": "other", "enabled": true, "is_active": false}]
+			}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{
+			"count": 2,
+			"next": null,
+			"results": [{
+				"uuid": "test-token",
+				"label": "exporter",
+				"enabled": true,
+				"is_active": true,
+				"valid_from": "2025-01-01T00:00:00Z",
+				"valid_to": "2026-01-01T00:00:00Z",
+				"created_at": "2025-01-01T00:00:00Z"
+			}]
+		}`))
+	})
+	key, err := client.GetAPIKey(context.Background())
+	if err != nil {
+		t.Fatalf("GetAPIKey failed: %v", err)
+	}
+	if !key.IsActive || key.Label != "exporter" {
+		t.Errorf("Unexpected key %+v", key)
+	}
+	if expected := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC); !key.ValidTo.Equal(expected) {
+		t.Errorf("Expected valid_to %s, got %s", expected, key.ValidTo)
+	}
+}
+
+func TestAPI_GetAPIKeyNotListed(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/keys/", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"count": 1, "next": null, "results": [{"uuid": "other-token"}]}`))
+	})
+	if _, err := client.GetAPIKey(context.Background()); err == nil || !strings.Contains(err.Error(), "not in the keys") {
+		t.Errorf("Expected the key to be reported missing, got %v", err)
+	}
+}
+
+func TestRedactURLError(t *testing.T) {
+	err := redactURLError(&url.Error{Op: "Get", URL: "https://example.com/keys/secret-token/", Err: errors.New("connection refused")}, "secret-token")
+	if strings.Contains(err.Error(), "secret-token") || !strings.Contains(err.Error(), "/keys/[redacted]/") {
+		t.Errorf("Expected the token to be redacted, got %v", err)
+	}
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		t.Errorf("Expected a *url.Error, got %T", err)
+	}
+}
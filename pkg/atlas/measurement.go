@@ -0,0 +1,84 @@
+package atlas
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Cyb3r-Jak3/common/v5"
+)
+
+type MeasurementStatus struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type Measurement struct {
+	ID                     int                  `json:"id"`
+	Type                   string               `json:"type"`
+	Description            string               `json:"description"`
+	Target                 string               `json:"target"`
+	AddressFamily          int                  `json:"af"`
+	Status                 MeasurementStatus    `json:"status"`
+	StartTime              common.ResilientTime `json:"start_time"`
+	StopTime               common.ResilientTime `json:"stop_time"`
+	Interval               int                  `json:"interval"`
+	OneOff                 bool                 `json:"is_oneoff"`
+	ProbesRequested        int                  `json:"probes_requested"`
+	ProbesScheduled        int                  `json:"probes_scheduled"`
+	ParticipantCount       int                  `json:"participant_count"`
+	CreditsPerResult       int                  `json:"credits_per_result"`
+	EstimatedResultsPerDay int                  `json:"estimated_results_per_day"`
+}
+
+type MeasurementAPIResponse struct {
+	Count   int           `json:"count"`
+	Next    string        `json:"next"`
+	Results []Measurement `json:"results"`
+}
+
+// EstimatedDailyCredits is the number of credits the measurement is expected to spend per day.
+func (m *Measurement) EstimatedDailyCredits() int {
+	return m.CreditsPerResult * m.EstimatedResultsPerDay
+}
+
+// GetMyMeasurements returns every measurement owned by the account of the API token.
+func (api *API) GetMyMeasurements(ctx context.Context) ([]Measurement, error) {
+	if api.Token() == "" {
+		return nil, ErrMissingToken
+	}
+	var measurements []Measurement
+	page := 1
+	for {
+		resp, err := api.request(ctx, "GET", fmt.Sprintf("/measurements/my?page=%d", page), nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("API request failed: %w", err)
+		}
+		var measurementResponse MeasurementAPIResponse
+		if unmarshalErr := json.Unmarshal(resp.Body, &measurementResponse); unmarshalErr != nil {
+			return nil, fmt.Errorf("failed to unmarshal measurements response: %w", unmarshalErr)
+		}
+		measurements = append(measurements, measurementResponse.Results...)
+		if measurementResponse.Count == 0 || measurementResponse.Next == "" {
+			break
+		}
+		page++
+	}
+	return measurements, nil
+}
+
+// GetMeasurement returns the measurement with the given ID.
+func (api *API) GetMeasurement(ctx context.Context, measurementID int) (*Measurement, error) {
+	if api.Token() == "" {
+		return nil, ErrMissingToken
+	}
+	resp, err := api.request(ctx, "GET", fmt.Sprintf("/measurements/%d/", measurementID), nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get measurement %d: %w", measurementID, err)
+	}
+	var measurement Measurement
+	if err = json.Unmarshal(resp.Body, &measurement); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal measurement %d: %w", measurementID, err)
+	}
+	return &measurement, nil
+}
@@ -0,0 +1,95 @@
+package atlas
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestAPI_GetMyMeasurements(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/measurements/my", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			t.Errorf("Expected GET request, got %s", r.Method)
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		var err error
+		if r.URL.Query().Get("page") == "1" {
+			_, err = w.Write([]byte(`{
+				"count": 2,
+				"next": "https://atlas.ripe.net/api/v2/measurements/my?page=2",
+				"results": [
+					{
+						"id": 1001,
+						"type": "sslcert",
+						"description": "SSL certificate of example.com",
+						"target": "example.com",
+						"af": 4,
+						"status": {"id": 2, "name": "Ongoing", "when": null},
+						"start_time": 1752244648,
+						"stop_time": null,
+						"interval": 900,
+						"is_oneoff": false,
+						"probes_requested": 10,
+						"probes_scheduled": 10,
+						"participant_count": 9,
+						"credits_per_result": 10,
+						"estimated_results_per_day": 960
+					}
+				]
+			}`))
+		} else {
+			_, err = w.Write([]byte(`{
+				"count": 2,
+				"next": null,
+				"results": [
+					{"id": 1002, "type": "http", "status": {"id": 4, "name": "Stopped"}, "start_time": 1752244648, "stop_time": 1752331048}
+				]
+			}`))
+		}
+		if err != nil {
+			return
+		}
+	})
+	measurements, err := client.GetMyMeasurements(context.Background())
+	if err != nil {
+		t.Fatalf("GetMyMeasurements failed: %v", err)
+	}
+	if len(measurements) != 2 {
+		t.Fatalf("Expected 2 measurements, got %d", len(measurements))
+	}
+	if measurements[0].Status.Name != "Ongoing" {
+		t.Errorf("Expected status 'Ongoing', got '%s'", measurements[0].Status.Name)
+	}
+	if measurements[0].EstimatedDailyCredits() != 9600 {
+		t.Errorf("Expected 9600 estimated daily credits, got %d", measurements[0].EstimatedDailyCredits())
+	}
+	if measurements[1].StopTime.IsZero() {
+		t.Errorf("Expected stop time to be set for measurement %d", measurements[1].ID)
+	}
+}
+
+func TestAPI_GetMeasurement(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/measurements/1001/", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id": 1001, "type": "ntp", "target": "ntp.example.com", "status": {"id": 2, "name": "Ongoing"}, "start_time": 1752244648}`))
+	})
+	measurement, err := client.GetMeasurement(context.Background(), 1001)
+	if err != nil {
+		t.Fatalf("GetMeasurement failed: %v", err)
+	}
+	if measurement.ID != 1001 || measurement.Type != "ntp" || measurement.Target != "ntp.example.com" {
+		t.Errorf("Unexpected measurement %+v", measurement)
+	}
+	if _, err = client.GetMeasurement(context.Background(), 404); err == nil {
+		t.Error("Expected an error for an unknown measurement")
+	}
+}
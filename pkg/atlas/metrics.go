@@ -0,0 +1,154 @@
+package atlas
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	idSegment = regexp.MustCompile(`/[0-9]+(/|$)`)
+	// keyPath matches the API key in the path of the key details, whatever
+	// its format, so the key never becomes a label value.
+	keyPath = regexp.MustCompile(`^/keys/[^/]+`)
+)
+
+// Metrics records the requests made by API clients created with WithMetrics.
+// It is a prometheus.Collector and must be registered by the caller.
+type Metrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+}
+
+// NewMetrics creates the API request metrics. Metric names are prefixed with namespace.
+func NewMetrics(namespace string) *Metrics {
+	return &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "api_requests_total",
+			Help:      "Number of requests made to the Atlas API by endpoint and status code",
+		}, []string{"endpoint", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "api_request_duration_seconds",
+			Help:      "Latency of requests made to the Atlas API until their response body is read, by endpoint and status code",
+			Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+		}, []string{"endpoint", "code"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "api_errors_total",
+			Help:      "Number of failed requests to the Atlas API by endpoint and error class",
+		}, []string{"endpoint", "class"}),
+	}
+}
+
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.requests.Describe(ch)
+	m.duration.Describe(ch)
+	m.errors.Describe(ch)
+}
+
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.requests.Collect(ch)
+	m.duration.Collect(ch)
+	m.errors.Collect(ch)
+}
+
+// instrumentedTransport records every round trip in Metrics.
+type instrumentedTransport struct {
+	next     http.RoundTripper
+	metrics  *Metrics
+	basePath string
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := endpointTemplate(strings.TrimPrefix(req.URL.Path, t.basePath))
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		t.metrics.errors.WithLabelValues(endpoint, transportErrorClass(err)).Inc()
+		return nil, err
+	}
+	code := strconv.Itoa(resp.StatusCode)
+	t.metrics.requests.WithLabelValues(endpoint, code).Inc()
+	duration := t.metrics.duration.WithLabelValues(endpoint, code)
+	resp.Body = &timedBody{ReadCloser: resp.Body, observe: func() {
+		duration.Observe(time.Since(start).Seconds())
+	}}
+	if class := statusErrorClass(resp.StatusCode); class != "" {
+		t.metrics.errors.WithLabelValues(endpoint, class).Inc()
+	}
+	return resp, nil
+}
+
+// timedBody calls observe once, when the body is read to the end or closed,
+// so the latency of large pages includes the transfer of the body.
+type timedBody struct {
+	io.ReadCloser
+	once    sync.Once
+	observe func()
+}
+
+func (b *timedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		b.once.Do(b.observe)
+	}
+	return n, err
+}
+
+func (b *timedBody) Close() error {
+	b.once.Do(b.observe)
+	return b.ReadCloser.Close()
+}
+
+// endpointTemplate replaces IDs in the path so that the endpoint label has a bounded cardinality.
+func endpointTemplate(path string) string {
+	path = keyPath.ReplaceAllString(path, "/keys/{key}")
+	for idSegment.MatchString(path) {
+		path = idSegment.ReplaceAllString(path, "/{id}$1")
+	}
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+func transportErrorClass(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	default:
+		return "network"
+	}
+}
+
+func statusErrorClass(statusCode int) string {
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return "unauthorized"
+	case statusCode == http.StatusTooManyRequests:
+		return "rate_limited"
+	case statusCode >= 500:
+		return "server_error"
+	case statusCode >= 400:
+		return "client_error"
+	default:
+		return ""
+	}
+}
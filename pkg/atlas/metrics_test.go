@@ -0,0 +1,108 @@
+package atlas
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func Test_endpointTemplate(t *testing.T) {
+	tests := map[string]string{
+		"":                              "/",
+		"/credits":                      "/credits",
+		"/probes/12345/measurements":    "/probes/{id}/measurements",
+		"/measurements/1001/latest/":    "/measurements/{id}/latest/",
+		"/measurements/1001/latest/1/2": "/measurements/{id}/latest/{id}/{id}",
+		"/keys/0b2a4f8c-6d1e-4f3a-9c7b-5e8d2a1f4c6b/": "/keys/{key}/",
+		"/keys/not-a-uuid/":                           "/keys/{key}/",
+		"/keys/12345/":                                "/keys/{key}/",
+		"/keys/":                                      "/keys/",
+	}
+	for path, expected := range tests {
+		if result := endpointTemplate(path); result != expected {
+			t.Errorf("endpointTemplate(%q): expected %q, got %q", path, expected, result)
+		}
+	}
+}
+
+func TestAPI_WithMetrics(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	mux.HandleFunc("/api/v2/measurements/1001/latest/", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[]`))
+	})
+	mux.HandleFunc("/api/v2/credits", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"error": {"status": 403, "code": 104, "title": "Forbidden", "detail": "The provided API key does not exist"}}`))
+	})
+
+	metrics := NewMetrics("test")
+	instrumentedClient, err := New(
+		WithAPIToken("test-token"),
+		WithBaseURL(server.URL+"/api/v2"),
+		WithMetrics(metrics),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create test API client: %v", err)
+	}
+	if _, err = instrumentedClient.GetLatestSSLCertResults(context.Background(), 1001); err != nil {
+		t.Fatalf("GetLatestSSLCertResults failed: %v", err)
+	}
+	_, err = instrumentedClient.GetCredits(context.Background())
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || !apiErr.Unauthorized() {
+		t.Fatalf("expected an unauthorized APIError, got %v", err)
+	}
+	if apiErr.Detail != "The provided API key does not exist" {
+		t.Errorf("unexpected error detail %q", apiErr.Detail)
+	}
+
+	if value := testutil.ToFloat64(metrics.requests.WithLabelValues("/measurements/{id}/latest/", "200")); value != 1 {
+		t.Errorf("expected 1 request to /measurements/{id}/latest/, got %f", value)
+	}
+	if value := testutil.ToFloat64(metrics.errors.WithLabelValues("/credits", "unauthorized")); value != 1 {
+		t.Errorf("expected 1 unauthorized error for /credits, got %f", value)
+	}
+	if http.DefaultClient.Transport != nil {
+		t.Errorf("WithMetrics modified http.DefaultClient")
+	}
+}
+
+func TestAPI_WithMetricsTimesBody(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	mux.HandleFunc("/credits", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(100 * time.Millisecond)
+		_, _ = w.Write([]byte(`{"current_balance": 500}`))
+	})
+
+	metrics := NewMetrics("test")
+	instrumentedClient, err := New(WithAPIToken("test-token"), WithBaseURL(server.URL), WithMetrics(metrics))
+	if err != nil {
+		t.Fatalf("Failed to create test API client: %v", err)
+	}
+	if _, err = instrumentedClient.GetCredits(context.Background()); err != nil {
+		t.Fatalf("GetCredits failed: %v", err)
+	}
+	var metric dto.Metric
+	if err = metrics.duration.WithLabelValues("/credits", "200").(prometheus.Metric).Write(&metric); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if histogram := metric.GetHistogram(); histogram.GetSampleCount() != 1 || histogram.GetSampleSum() < 0.1 {
+		t.Errorf("expected one observation including the body transfer, got %d with a sum of %v", histogram.GetSampleCount(), histogram.GetSampleSum())
+	}
+}
@@ -0,0 +1,43 @@
+package atlas
+
+import "context"
+
+// NTPReply is a single reply received by the probe. Offset and RoundTripTime
+// are zero for packets that timed out.
+type NTPReply struct {
+	Offset        float64 `json:"offset"`
+	RoundTripTime float64 `json:"rtt"`
+	Timeout       string  `json:"x,omitempty"`
+}
+
+// NTPResult is a single probe result of an ntp measurement.
+type NTPResult struct {
+	MeasurementID  int        `json:"msm_id"`
+	ProbeID        int        `json:"prb_id"`
+	Timestamp      int        `json:"timestamp"`
+	AddressFamily  int        `json:"af"`
+	DstName        string     `json:"dst_name"`
+	DstAddr        string     `json:"dst_addr"`
+	Stratum        int        `json:"stratum"`
+	Poll           float64    `json:"poll"`
+	ReferenceID    string     `json:"ref-id"`
+	RootDelay      float64    `json:"root-delay"`
+	RootDispersion float64    `json:"root-dispersion"`
+	Replies        []NTPReply `json:"result"`
+}
+
+// SuccessfulReplies returns the replies that did not time out.
+func (r *NTPResult) SuccessfulReplies() []NTPReply {
+	var replies []NTPReply
+	for _, reply := range r.Replies {
+		if reply.Timeout == "" {
+			replies = append(replies, reply)
+		}
+	}
+	return replies
+}
+
+// GetLatestNTPResults returns the latest result of every probe of the ntp measurement.
+func (api *API) GetLatestNTPResults(ctx context.Context, measurementID int) ([]NTPResult, error) {
+	return getLatestResults[NTPResult](ctx, api, measurementID)
+}
@@ -0,0 +1,62 @@
+package atlas
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestAPI_GetLatestNTPResults(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/measurements/1003/latest/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			t.Errorf("Expected GET request, got %s", r.Method)
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, err := w.Write([]byte(`[
+			{
+				"af": 4,
+				"dst_addr": "192.0.2.123",
+				"dst_name": "ntp.example.com",
+				"msm_id": 1003,
+				"poll": 8,
+				"prb_id": 1,
+				"ref-id": "GPS",
+				"result": [
+					{"final-ts": 3961502367.8, "offset": 0.0012, "origin-ts": 3961502367.7, "receive-ts": 3961502367.75, "rtt": 0.021, "transmit-ts": 3961502367.76},
+					{"x": "*"}
+				],
+				"root-delay": 0,
+				"root-dispersion": 0.0001,
+				"stratum": 1,
+				"timestamp": 1752371567,
+				"type": "ntp"
+			}
+		]`))
+		if err != nil {
+			return
+		}
+	})
+	results, err := client.GetLatestNTPResults(context.Background(), 1003)
+	if err != nil {
+		t.Fatalf("GetLatestNTPResults failed: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 result, got %d", len(results))
+	}
+	if results[0].ReferenceID != "GPS" || results[0].Stratum != 1 {
+		t.Errorf("Unexpected result %+v", results[0])
+	}
+	replies := results[0].SuccessfulReplies()
+	if len(replies) != 1 {
+		t.Fatalf("Expected 1 successful reply, got %d", len(replies))
+	}
+	if replies[0].Offset != 0.0012 {
+		t.Errorf("Expected offset 0.0012, got %f", replies[0].Offset)
+	}
+}
@@ -76,7 +76,7 @@ func (p *ProbeInfo) LastConnectedTime() time.Time {
 }
 
 func (api *API) GetMyProbes(ctx context.Context) ([]ProbeInfo, error) {
-	if api.APIToken == "" {
+	if api.Token() == "" {
 		return nil, ErrMissingToken
 	}
 	var probes []ProbeInfo
@@ -100,7 +100,7 @@ func (api *API) GetMyProbes(ctx context.Context) ([]ProbeInfo, error) {
 }
 
 func (api *API) GetMyProbesMeasurements(ctx context.Context) ([]ProbeInfoMeasurement, error) {
-	if api.APIToken == "" {
+	if api.Token() == "" {
 		return nil, ErrMissingToken
 	}
 	myProbes, err := api.GetMyProbes(ctx)
@@ -0,0 +1,24 @@
+package atlas
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// getLatestResults fetches the most recent result of every probe participating
+// in the measurement and decodes them into T.
+func getLatestResults[T any](ctx context.Context, api *API, measurementID int) ([]T, error) {
+	if api.Token() == "" {
+		return nil, ErrMissingToken
+	}
+	resp, err := api.request(ctx, "GET", fmt.Sprintf("/measurements/%d/latest/?format=json", measurementID), nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest results for measurement %d: %w", measurementID, err)
+	}
+	var results []T
+	if err = json.Unmarshal(resp.Body, &results); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal latest results for measurement %d: %w", measurementID, err)
+	}
+	return results, nil
+}
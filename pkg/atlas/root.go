@@ -14,7 +14,7 @@ type RootAPIResponse struct {
 }
 
 func (api *API) GetRootData(ctx context.Context) (*RootAPIResponse, error) {
-	if api.APIToken == "" {
+	if api.Token() == "" {
 		return nil, ErrMissingToken
 	}
 	resp, err := api.request(ctx, "GET", "/root/?format=json", nil, nil)
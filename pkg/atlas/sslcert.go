@@ -0,0 +1,63 @@
+package atlas
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNoCertificates = errors.New("result does not contain any certificates")
+)
+
+// SSLCertAlert is the TLS alert received by the probe. The misspelt JSON key
+// matches the RIPE Atlas result format.
+type SSLCertAlert struct {
+	Level       int `json:"level"`
+	Description int `json:"decription"`
+}
+
+// SSLCertResult is a single probe result of an sslcert measurement.
+type SSLCertResult struct {
+	MeasurementID int           `json:"msm_id"`
+	ProbeID       int           `json:"prb_id"`
+	Timestamp     int           `json:"timestamp"`
+	AddressFamily int           `json:"af"`
+	DstName       string        `json:"dst_name"`
+	DstAddr       string        `json:"dst_addr"`
+	Method        string        `json:"method"`
+	Version       string        `json:"ver"`
+	ResponseTime  float64       `json:"rt"`
+	TimeToConnect float64       `json:"ttc"`
+	Certificates  []string      `json:"cert"`
+	Alert         *SSLCertAlert `json:"alert,omitempty"`
+	Error         string        `json:"err,omitempty"`
+}
+
+// ParseCertificates decodes the PEM encoded certificate chain served to the
+// probe. The leaf certificate is the first element.
+func (r *SSLCertResult) ParseCertificates() ([]*x509.Certificate, error) {
+	if len(r.Certificates) == 0 {
+		return nil, ErrNoCertificates
+	}
+	certificates := make([]*x509.Certificate, 0, len(r.Certificates))
+	for i, encoded := range r.Certificates {
+		block, _ := pem.Decode([]byte(encoded))
+		if block == nil || block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("certificate %d is not a PEM encoded certificate", i)
+		}
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate %d: %w", i, err)
+		}
+		certificates = append(certificates, certificate)
+	}
+	return certificates, nil
+}
+
+// GetLatestSSLCertResults returns the latest result of every probe of the sslcert measurement.
+func (api *API) GetLatestSSLCertResults(ctx context.Context, measurementID int) ([]SSLCertResult, error) {
+	return getLatestResults[SSLCertResult](ctx, api, measurementID)
+}
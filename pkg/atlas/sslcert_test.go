@@ -0,0 +1,106 @@
+package atlas
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func testCertificatePEM(t *testing.T, commonName string, notAfter time.Time) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		Issuer:       pkix.Name{CommonName: commonName},
+		DNSNames:     []string{commonName},
+		NotBefore:    notAfter.Add(-24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestAPI_GetLatestSSLCertResults(t *testing.T) {
+	setup()
+	defer teardown()
+
+	notAfter := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	certificate := testCertificatePEM(t, "example.com", notAfter)
+	mux.HandleFunc("/measurements/1001/latest/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			t.Errorf("Expected GET request, got %s", r.Method)
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := json.Marshal([]map[string]any{
+			{
+				"msm_id":    1001,
+				"prb_id":    1,
+				"timestamp": 1752371567,
+				"af":        4,
+				"dst_name":  "example.com",
+				"dst_addr":  "93.184.216.34",
+				"method":    "TLS",
+				"ver":       "1.3",
+				"rt":        42.5,
+				"ttc":       10.1,
+				"cert":      []string{certificate},
+			},
+		})
+		if err != nil {
+			t.Fatalf("Failed to marshal results: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, err = w.Write(body)
+		if err != nil {
+			return
+		}
+	})
+	results, err := client.GetLatestSSLCertResults(context.Background(), 1001)
+	if err != nil {
+		t.Fatalf("GetLatestSSLCertResults failed: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 result, got %d", len(results))
+	}
+	if results[0].ProbeID != 1 {
+		t.Errorf("Expected probe ID 1, got %d", results[0].ProbeID)
+	}
+	if results[0].ResponseTime != 42.5 {
+		t.Errorf("Expected response time 42.5, got %f", results[0].ResponseTime)
+	}
+	certificates, err := results[0].ParseCertificates()
+	if err != nil {
+		t.Fatalf("ParseCertificates failed: %v", err)
+	}
+	if len(certificates) != 1 {
+		t.Fatalf("Expected 1 certificate, got %d", len(certificates))
+	}
+	if !certificates[0].NotAfter.Equal(notAfter) {
+		t.Errorf("Expected notAfter %s, got %s", notAfter, certificates[0].NotAfter)
+	}
+}
+
+func TestSSLCertResult_ParseCertificatesEmpty(t *testing.T) {
+	result := SSLCertResult{Error: "connect: timeout"}
+	if _, err := result.ParseCertificates(); err != ErrNoCertificates {
+		t.Fatalf("expected ErrNoCertificates, got %v", err)
+	}
+}
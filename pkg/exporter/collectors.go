@@ -0,0 +1,346 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/state"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	creditsDesc = prometheus.NewDesc(
+		"atlas_exporter_credits",
+		"Current number of credits available in the Atlas account",
+		nil,
+		nil,
+	)
+	probeLastConnectedDesc = prometheus.NewDesc(
+		"atlas_exporter_probe_last_connected",
+		"Last connected time (Unix timestamp) for each probe",
+		[]string{"probe_id", "country_code", "description"},
+		nil,
+	)
+	probeStatusDesc = prometheus.NewDesc(
+		"atlas_exporter_probe_status",
+		"Current status of each probe, such as Connected, Disconnected or Abandoned",
+		[]string{"probe_id", "status"},
+		nil,
+	)
+	probeMeasurementsDesc = prometheus.NewDesc(
+		"atlas_exporter_probe_measurements",
+		"Measurements for each probe",
+		[]string{"probe_id", "type", "status"},
+		nil,
+	)
+	apiKeyActiveDesc = prometheus.NewDesc(
+		"atlas_exporter_api_key_active",
+		"Whether the API key used by the exporter is active (1 = active)",
+		nil,
+		nil,
+	)
+	apiKeyExpiryDesc = prometheus.NewDesc(
+		"atlas_exporter_api_key_expiry_timestamp_seconds",
+		"Time (Unix timestamp) the API key used by the exporter expires, absent when it does not expire",
+		nil,
+		nil,
+	)
+)
+
+func init() {
+	registerCollector("credits", true, "credits available in the account", func(e *Exporter) Fetcher {
+		collector := &CreditsCollector{client: e.client, logger: e.logger}
+		if e.isEnabled("credit_forecast") {
+			collector.history = e.store
+		}
+		return collector
+	})
+	registerCollector("credit_forecast", true, "burn rates and projected exhaustion of the credits, from the balance history recorded by the credits collector", func(e *Exporter) Fetcher {
+		if !e.isEnabled("credits") {
+			e.logger.Info("Disabling the credit_forecast collector, which needs the credits collector")
+			return nil
+		}
+		return CreditForecastCollectorFactory(e.logger, e.store)
+	})
+	registerCollector("probe_last_connected", true, "last connection time and status of the probes owned by the account", func(e *Exporter) Fetcher {
+		return ProbeLastConnectedCollectorFactory(e.client, e.logger)
+	})
+	registerCollector("probe_measurements", true, "measurements of the probes owned by the account, one API request per probe", func(e *Exporter) Fetcher {
+		return ProbeMeasurementsCollectorFactory(e.client, e.logger)
+	})
+	registerCollector("measurement_metadata", true, "metadata of the measurements owned by the account", func(e *Exporter) Fetcher {
+		if e.measurementMetadataLimit <= 0 {
+			return nil
+		}
+		return MeasurementMetadataCollectorFactory(e.client, e.logger, e.measurementMetadataLimit)
+	})
+	registerCollector("api_key", false, "validity of the API key, which must be allowed to list the keys of the account", func(e *Exporter) Fetcher {
+		return APIKeyCollectorFactory(e.client, e.logger)
+	})
+}
+
+// CreditsCollector exports the credit balance. When history is set, the
+// balance is also recorded there for the CreditForecastCollector.
+type CreditsCollector struct {
+	client  Client
+	logger  logrus.FieldLogger
+	history state.Store
+}
+
+func (c *CreditsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- creditsDesc
+}
+
+func (c *CreditsCollector) Fetch(ctx context.Context) ([]prometheus.Metric, error) {
+	c.logger.Debug("Collecting credits")
+	resp, err := c.client.GetCredits(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil {
+		return nil, errors.New("received nil response from GetCredits")
+	}
+	if c.history != nil {
+		if err = recordBalance(c.history, time.Now(), resp.CurrentBalance); err != nil {
+			return nil, err
+		}
+	}
+	return []prometheus.Metric{
+		prometheus.MustNewConstMetric(creditsDesc, prometheus.GaugeValue, float64(resp.CurrentBalance)),
+	}, nil
+}
+
+func CreditsCollectorFactory(client Client, logger logrus.FieldLogger) Fetcher {
+	return &CreditsCollector{client: client, logger: logger}
+}
+
+type ProbeLastConnectedCollector struct {
+	client Client
+	logger logrus.FieldLogger
+}
+
+func (c *ProbeLastConnectedCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- probeLastConnectedDesc
+	ch <- probeStatusDesc
+}
+
+func (c *ProbeLastConnectedCollector) Fetch(ctx context.Context) ([]prometheus.Metric, error) {
+	c.logger.Debug("Collecting last connected time (Unix timestamp) for each probe")
+	resp, err := c.client.GetMyProbes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get probe last connected: %w", err)
+	}
+	metrics := make([]prometheus.Metric, 0, 2*len(resp))
+	for _, probe := range resp {
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			probeLastConnectedDesc,
+			prometheus.GaugeValue,
+			float64(probe.LastConnected),
+			fmt.Sprintf("%d", probe.ID),
+			probe.CountryCode,
+			probe.Description,
+		))
+		if probe.Status.Name != "" {
+			metrics = append(metrics, prometheus.MustNewConstMetric(
+				probeStatusDesc,
+				prometheus.GaugeValue,
+				1,
+				fmt.Sprintf("%d", probe.ID),
+				probe.Status.Name,
+			))
+		}
+	}
+	return metrics, nil
+}
+
+func ProbeLastConnectedCollectorFactory(client Client, logger logrus.FieldLogger) Fetcher {
+	return &ProbeLastConnectedCollector{client: client, logger: logger}
+}
+
+type ProbeMeasurementsCollector struct {
+	client Client
+	logger logrus.FieldLogger
+}
+
+func (c *ProbeMeasurementsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- probeMeasurementsDesc
+}
+
+func (c *ProbeMeasurementsCollector) Fetch(ctx context.Context) ([]prometheus.Metric, error) {
+	c.logger.Debug("Collecting measurements for each probe")
+	resp, err := c.client.GetMyProbesMeasurements(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get probe measurements: %w", err)
+	}
+	matrix := make(map[int]map[string]map[string]int)
+	for _, measurement := range resp {
+		probeID := measurement.ProbeID
+		typ := measurement.Type
+		status := measurement.Status
+
+		if _, ok := matrix[probeID]; !ok {
+			matrix[probeID] = make(map[string]map[string]int)
+		}
+		if _, ok := matrix[probeID][typ]; !ok {
+			matrix[probeID][typ] = make(map[string]int)
+		}
+		matrix[probeID][typ][status]++
+	}
+	var metrics []prometheus.Metric
+	for probeID, probeMeasurements := range matrix {
+		for typ, statuses := range probeMeasurements {
+			for status, count := range statuses {
+				metrics = append(metrics, prometheus.MustNewConstMetric(
+					probeMeasurementsDesc,
+					prometheus.GaugeValue,
+					float64(count),
+					fmt.Sprintf("%d", probeID),
+					typ,
+					status,
+				))
+			}
+		}
+	}
+	return metrics, nil
+}
+
+func ProbeMeasurementsCollectorFactory(client Client, logger logrus.FieldLogger) Fetcher {
+	return &ProbeMeasurementsCollector{client: client, logger: logger}
+}
+
+var (
+	measurementLabels     = []string{"measurement_id", "type", "target", "description"}
+	measurementStatusDesc = prometheus.NewDesc(
+		"atlas_exporter_measurement_status",
+		"Current status of each measurement owned by the account",
+		append(measurementLabels, "status"),
+		nil,
+	)
+	measurementStartTimeDesc = prometheus.NewDesc(
+		"atlas_exporter_measurement_start_time",
+		"Start time (Unix timestamp) of each measurement owned by the account",
+		measurementLabels,
+		nil,
+	)
+	measurementStopTimeDesc = prometheus.NewDesc(
+		"atlas_exporter_measurement_stop_time",
+		"Stop time (Unix timestamp) of each measurement owned by the account",
+		measurementLabels,
+		nil,
+	)
+	measurementIntervalDesc = prometheus.NewDesc(
+		"atlas_exporter_measurement_interval_seconds",
+		"Interval between results of each measurement owned by the account",
+		measurementLabels,
+		nil,
+	)
+	measurementProbesRequestedDesc = prometheus.NewDesc(
+		"atlas_exporter_measurement_probes_requested",
+		"Number of probes requested for each measurement owned by the account",
+		measurementLabels,
+		nil,
+	)
+	measurementProbesParticipatingDesc = prometheus.NewDesc(
+		"atlas_exporter_measurement_probes_participating",
+		"Number of probes participating in each measurement owned by the account",
+		measurementLabels,
+		nil,
+	)
+	measurementDailyCreditsDesc = prometheus.NewDesc(
+		"atlas_exporter_measurement_estimated_daily_credits",
+		"Estimated number of credits spent per day by each measurement owned by the account",
+		measurementLabels,
+		nil,
+	)
+)
+
+type MeasurementMetadataCollector struct {
+	client Client
+	logger logrus.FieldLogger
+	limit  int
+}
+
+func (c *MeasurementMetadataCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- measurementStatusDesc
+	ch <- measurementStartTimeDesc
+	ch <- measurementStopTimeDesc
+	ch <- measurementIntervalDesc
+	ch <- measurementProbesRequestedDesc
+	ch <- measurementProbesParticipatingDesc
+	ch <- measurementDailyCreditsDesc
+}
+
+func (c *MeasurementMetadataCollector) Fetch(ctx context.Context) ([]prometheus.Metric, error) {
+	c.logger.Debug("Collecting metadata for each measurement")
+	resp, err := c.client.GetMyMeasurements(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get measurements: %w", err)
+	}
+	// Keep the newest measurements when the account owns more than the limit.
+	sort.Slice(resp, func(i, j int) bool { return resp[i].ID > resp[j].ID })
+	if len(resp) > c.limit {
+		c.logger.Warnf("Account owns %d measurements, only exporting metadata for the newest %d", len(resp), c.limit)
+		resp = resp[:c.limit]
+	}
+	metrics := make([]prometheus.Metric, 0, 7*len(resp))
+	for _, measurement := range resp {
+		labels := []string{
+			fmt.Sprintf("%d", measurement.ID),
+			measurement.Type,
+			measurement.Target,
+			measurement.Description,
+		}
+		metrics = append(metrics, prometheus.MustNewConstMetric(measurementStatusDesc, prometheus.GaugeValue, 1, append(labels, measurement.Status.Name)...))
+		metrics = append(metrics, prometheus.MustNewConstMetric(measurementStartTimeDesc, prometheus.GaugeValue, float64(measurement.StartTime.Unix()), labels...))
+		if !measurement.StopTime.IsZero() {
+			metrics = append(metrics, prometheus.MustNewConstMetric(measurementStopTimeDesc, prometheus.GaugeValue, float64(measurement.StopTime.Unix()), labels...))
+		}
+		metrics = append(metrics, prometheus.MustNewConstMetric(measurementIntervalDesc, prometheus.GaugeValue, float64(measurement.Interval), labels...))
+		metrics = append(metrics, prometheus.MustNewConstMetric(measurementProbesRequestedDesc, prometheus.GaugeValue, float64(measurement.ProbesRequested), labels...))
+		metrics = append(metrics, prometheus.MustNewConstMetric(measurementProbesParticipatingDesc, prometheus.GaugeValue, float64(measurement.ParticipantCount), labels...))
+		metrics = append(metrics, prometheus.MustNewConstMetric(measurementDailyCreditsDesc, prometheus.GaugeValue, float64(measurement.EstimatedDailyCredits()), labels...))
+	}
+	return metrics, nil
+}
+
+func MeasurementMetadataCollectorFactory(client Client, logger logrus.FieldLogger, limit int) Fetcher {
+	return &MeasurementMetadataCollector{client: client, logger: logger, limit: limit}
+}
+
+type APIKeyCollector struct {
+	client Client
+	logger logrus.FieldLogger
+}
+
+func (c *APIKeyCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- apiKeyActiveDesc
+	ch <- apiKeyExpiryDesc
+}
+
+func (c *APIKeyCollector) Fetch(ctx context.Context) ([]prometheus.Metric, error) {
+	c.logger.Debug("Collecting API key validity")
+	key, err := c.client.GetAPIKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, errors.New("received nil response from GetAPIKey")
+	}
+	active := 0.0
+	if key.IsActive {
+		active = 1
+	}
+	metrics := []prometheus.Metric{prometheus.MustNewConstMetric(apiKeyActiveDesc, prometheus.GaugeValue, active)}
+	if !key.ValidTo.IsZero() {
+		metrics = append(metrics, prometheus.MustNewConstMetric(apiKeyExpiryDesc, prometheus.GaugeValue, float64(key.ValidTo.Unix())))
+	}
+	return metrics, nil
+}
+
+func APIKeyCollectorFactory(client Client, logger logrus.FieldLogger) Fetcher {
+	return &APIKeyCollector{client: client, logger: logger}
+}
@@ -0,0 +1,142 @@
+package exporter
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/atlas"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// liveAPIClient creates an API client for the token in ATLAS_EXPORTER_API_TOKEN.
+func liveAPIClient(t *testing.T) *atlas.API {
+	t.Helper()
+	client, err := atlas.New(atlas.WithAPIToken(os.Getenv("ATLAS_EXPORTER_API_TOKEN")))
+	if err != nil {
+		t.Fatalf("Failed to create API client: %v", err)
+	}
+	return client
+}
+
+func TestCreditsCollector(t *testing.T) {
+	if os.Getenv("ATLAS_EXPORTER_API_TOKEN") == "" {
+		t.Skip("Skipping TestCreditsCollector because ATLAS_EXPORTER_API_TOKEN is not set")
+	}
+	collector := refreshedCollector(t, "credits", CreditsCollectorFactory(liveAPIClient(t), testLogger()))
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(`
+# HELP atlas_exporter_credits Current number of credits available in the Atlas account
+# TYPE atlas_exporter_credits gauge
+atlas_exporter_credits 1000
+`)); err != nil {
+		t.Errorf("CreditsCollector failed: %v", err)
+	}
+}
+
+func TestAPIKeyCollector(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      map[string]any
+		expected string
+	}{
+		{
+			"expiring",
+			map[string]any{"uuid": "key", "is_active": true, "valid_to": "2026-01-01T00:00:00Z"},
+			`
+# HELP atlas_exporter_api_key_active Whether the API key used by the exporter is active (1 = active)
+# TYPE atlas_exporter_api_key_active gauge
+atlas_exporter_api_key_active 1
+# HELP atlas_exporter_api_key_expiry_timestamp_seconds Time (Unix timestamp) the API key used by the exporter expires, absent when it does not expire
+# TYPE atlas_exporter_api_key_expiry_timestamp_seconds gauge
+atlas_exporter_api_key_expiry_timestamp_seconds 1.7672256e+09
+`,
+		},
+		{
+			"without expiry",
+			map[string]any{"uuid": "key", "is_active": false, "valid_to": nil},
+			`
+# HELP atlas_exporter_api_key_active Whether the API key used by the exporter is active (1 = active)
+# TYPE atlas_exporter_api_key_active gauge
+atlas_exporter_api_key_active 0
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := decode[atlas.APIKey](t, tt.key)
+			collector := refreshedCollector(t, "api_key", APIKeyCollectorFactory(&fakeClient{apiKey: &key}, testLogger()))
+			if err := testutil.CollectAndCompare(collector, strings.NewReader(tt.expected)); err != nil {
+				t.Errorf("APIKeyCollector failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestProbeLastConnectedCollector(t *testing.T) {
+	if os.Getenv("ATLAS_EXPORTER_API_TOKEN") == "" {
+		t.Skip("Skipping TestProbeLastConnectedCollector because ATLAS_EXPORTER_API_TOKEN is not set")
+	}
+	collector := refreshedCollector(t, "probe_last_connected", ProbeLastConnectedCollectorFactory(liveAPIClient(t), testLogger()))
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(`
+# HELP atlas_exporter_probe_last_connected Last time the probe was connected
+# TYPE atlas_exporter_probe_last_connected gauge
+atlas_exporter_probe_last_connected{probe_id="12345"} 1700000000
+}`)); err != nil {
+		t.Errorf("ProbeLastConnectedCollector failed: %v", err)
+	}
+}
+
+func TestProbeStatus(t *testing.T) {
+	client := &fakeClient{probes: decode[[]atlas.ProbeInfo](t, []map[string]any{
+		{"id": 1, "last_connected": 1700000000, "status": map[string]any{"id": 1, "name": "Connected"}},
+		{"id": 2, "last_connected": 1600000000, "status": map[string]any{"id": 3, "name": "Abandoned"}},
+		{"id": 3, "last_connected": 0},
+	})}
+	collector := refreshedCollector(t, "probe_last_connected", ProbeLastConnectedCollectorFactory(client, testLogger()))
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(`
+# HELP atlas_exporter_probe_status Current status of each probe, such as Connected, Disconnected or Abandoned
+# TYPE atlas_exporter_probe_status gauge
+atlas_exporter_probe_status{probe_id="1",status="Connected"} 1
+atlas_exporter_probe_status{probe_id="2",status="Abandoned"} 1
+`), "atlas_exporter_probe_status"); err != nil {
+		t.Errorf("ProbeLastConnectedCollector failed: %v", err)
+	}
+}
+
+func TestMeasurementMetadataCollector(t *testing.T) {
+	client := &fakeClient{measurements: decode[[]atlas.Measurement](t, []map[string]any{
+		{
+			"id": 1001, "type": "sslcert", "target": "example.com", "description": "Old measurement",
+			"status": map[string]any{"id": 4, "name": "Stopped"}, "start_time": 1700000000, "stop_time": 1700086400,
+		},
+		{
+			"id": 1002, "type": "ping", "target": "example.com", "description": "Ping example.com",
+			"status": map[string]any{"id": 2, "name": "Ongoing"}, "start_time": 1752244648, "stop_time": nil,
+			"interval": 240, "probes_requested": 10, "participant_count": 9, "credits_per_result": 3, "estimated_results_per_day": 3600,
+		},
+	})}
+
+	collector := refreshedCollector(t, "measurement_metadata", MeasurementMetadataCollectorFactory(client, testLogger(), 1))
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(`
+# HELP atlas_exporter_measurement_estimated_daily_credits Estimated number of credits spent per day by each measurement owned by the account
+# TYPE atlas_exporter_measurement_estimated_daily_credits gauge
+atlas_exporter_measurement_estimated_daily_credits{description="Ping example.com",measurement_id="1002",target="example.com",type="ping"} 10800
+# HELP atlas_exporter_measurement_interval_seconds Interval between results of each measurement owned by the account
+# TYPE atlas_exporter_measurement_interval_seconds gauge
+atlas_exporter_measurement_interval_seconds{description="Ping example.com",measurement_id="1002",target="example.com",type="ping"} 240
+# HELP atlas_exporter_measurement_probes_participating Number of probes participating in each measurement owned by the account
+# TYPE atlas_exporter_measurement_probes_participating gauge
+atlas_exporter_measurement_probes_participating{description="Ping example.com",measurement_id="1002",target="example.com",type="ping"} 9
+# HELP atlas_exporter_measurement_probes_requested Number of probes requested for each measurement owned by the account
+# TYPE atlas_exporter_measurement_probes_requested gauge
+atlas_exporter_measurement_probes_requested{description="Ping example.com",measurement_id="1002",target="example.com",type="ping"} 10
+# HELP atlas_exporter_measurement_start_time Start time (Unix timestamp) of each measurement owned by the account
+# TYPE atlas_exporter_measurement_start_time gauge
+atlas_exporter_measurement_start_time{description="Ping example.com",measurement_id="1002",target="example.com",type="ping"} 1.752244648e+09
+# HELP atlas_exporter_measurement_status Current status of each measurement owned by the account
+# TYPE atlas_exporter_measurement_status gauge
+atlas_exporter_measurement_status{description="Ping example.com",measurement_id="1002",status="Ongoing",target="example.com",type="ping"} 1
+`)); err != nil {
+		t.Errorf("MeasurementMetadataCollector failed: %v", err)
+	}
+}
@@ -0,0 +1,196 @@
+// Package exporter exposes RIPE Atlas data as Prometheus metrics. The data is
+// refreshed from the Atlas API in the background and scrapes are served from
+// the last snapshot of each collector.
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/atlas"
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/state"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+)
+
+// Client is the part of the Atlas API used by the collectors.
+type Client interface {
+	GetCredits(ctx context.Context) (*atlas.CreditAPIResponse, error)
+	GetMyProbes(ctx context.Context) ([]atlas.ProbeInfo, error)
+	GetMyProbesMeasurements(ctx context.Context) ([]atlas.ProbeInfoMeasurement, error)
+	GetMyMeasurements(ctx context.Context) ([]atlas.Measurement, error)
+	GetMeasurement(ctx context.Context, measurementID int) (*atlas.Measurement, error)
+	GetLatestSSLCertResults(ctx context.Context, measurementID int) ([]atlas.SSLCertResult, error)
+	GetLatestHTTPResults(ctx context.Context, measurementID int) ([]atlas.HTTPResult, error)
+	GetLatestNTPResults(ctx context.Context, measurementID int) ([]atlas.NTPResult, error)
+	GetAPIKey(ctx context.Context) (*atlas.APIKey, error)
+}
+
+var _ Client = (*atlas.API)(nil)
+
+// Exporter refreshes the enabled collectors and exposes their metrics. It is a
+// prometheus.Collector.
+type Exporter struct {
+	client                   Client
+	logger                   logrus.FieldLogger
+	enabled                  []string
+	refreshInterval          time.Duration
+	refreshIntervals         map[string]time.Duration
+	timeout                  time.Duration
+	measurements             map[string][]int
+	measurementMetadataLimit int
+	store                    state.Store
+	collectors               []*cachedCollector
+}
+
+// New creates an Exporter for client. Without options every collector that
+// is enabled by default is refreshed every 5 minutes and the state of the
+// collectors is kept in memory. A nil logger uses the logrus standard logger.
+func New(client Client, logger logrus.FieldLogger, opts ...Option) (*Exporter, error) {
+	if logger == nil {
+		logger = logrus.StandardLogger()
+	}
+	e := &Exporter{
+		client:                   client,
+		logger:                   logger,
+		refreshInterval:          5 * time.Minute,
+		refreshIntervals:         map[string]time.Duration{},
+		timeout:                  time.Minute,
+		measurements:             map[string][]int{},
+		measurementMetadataLimit: 100,
+	}
+	for _, opt := range opts {
+		if err := opt(e); err != nil {
+			return nil, err
+		}
+	}
+	if e.store == nil {
+		e.store = state.NewMemory(state.Retention{})
+	}
+	for _, collector := range collectorRegistry {
+		if !e.isEnabled(collector.Name) {
+			continue
+		}
+		fetcher := collector.factory(e)
+		if fetcher == nil {
+			continue
+		}
+		interval := e.refreshInterval
+		if override := e.refreshIntervals[collector.Name]; override > 0 {
+			interval = override
+		}
+		e.collectors = append(e.collectors, newCachedCollector(collector.Name, fetcher, interval, e.timeout, e.logger))
+	}
+	return e, nil
+}
+
+// isEnabled reports whether the named collector is selected by WithCollectors,
+// or enabled by default without it.
+func (e *Exporter) isEnabled(name string) bool {
+	if e.enabled != nil {
+		return slices.Contains(e.enabled, name)
+	}
+	for _, collector := range collectorRegistry {
+		if collector.Name == name {
+			return collector.DefaultEnabled
+		}
+	}
+	return false
+}
+
+// Collectors returns the collector reporting the refresh status followed by
+// the collector of every enabled collector.
+func (e *Exporter) Collectors() []prometheus.Collector {
+	collectors := []prometheus.Collector{statusCollector(e.collectors)}
+	for _, c := range e.collectors {
+		collectors = append(collectors, c)
+	}
+	return collectors
+}
+
+func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range e.Collectors() {
+		c.Describe(ch)
+	}
+}
+
+func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range e.Collectors() {
+		c.Collect(ch)
+	}
+}
+
+// Handler returns an http.Handler serving the metrics of the Exporter.
+func (e *Exporter) Handler() http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(e)
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// Start launches a refresh loop for every collector until ctx is cancelled.
+// Collectors that were refreshed within their interval, with Refresh or
+// Inherit, are not refreshed again until the interval has passed.
+func (e *Exporter) Start(ctx context.Context) {
+	for _, c := range e.collectors {
+		e.logger.Debugf("Refreshing %s collector every %s", c.name, c.interval)
+		go c.run(ctx)
+	}
+}
+
+// Inherit starts the named collectors from the last snapshot of the same
+// collectors of previous, so their metrics and refresh status survive a
+// configuration reload until their first refresh. It must be called before
+// Start.
+func (e *Exporter) Inherit(previous *Exporter, names ...string) {
+	for _, c := range e.collectors {
+		if !slices.Contains(names, c.name) {
+			continue
+		}
+		for _, p := range previous.collectors {
+			if p.name == c.name {
+				c.current.Store(p.current.Load())
+			}
+		}
+	}
+}
+
+// Status returns the refresh status of every enabled collector.
+func (e *Exporter) Status() []CollectorStatus {
+	statuses := make([]CollectorStatus, len(e.collectors))
+	for i, c := range e.collectors {
+		current := c.current.Load()
+		statuses[i] = CollectorStatus{
+			Name:        c.name,
+			Interval:    c.interval,
+			LastSuccess: current.lastSuccess,
+			Duration:    current.duration,
+			Err:         current.err,
+		}
+	}
+	return statuses
+}
+
+// Refresh immediately refreshes the named collector, or every collector when
+// name is empty.
+func (e *Exporter) Refresh(ctx context.Context, name string) error {
+	var errs []error
+	found := false
+	for _, c := range e.collectors {
+		if name != "" && c.name != name {
+			continue
+		}
+		found = true
+		if err := c.Refresh(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", c.name, err))
+		}
+	}
+	if !found {
+		return fmt.Errorf("%w: %s", ErrUnknownCollector, name)
+	}
+	return errors.Join(errs...)
+}
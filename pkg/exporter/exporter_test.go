@@ -0,0 +1,293 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/atlas"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+)
+
+var errNotFound = errors.New("not found")
+
+// fakeClient serves canned responses instead of calling the Atlas API.
+type fakeClient struct {
+	credits           *atlas.CreditAPIResponse
+	probes            []atlas.ProbeInfo
+	probeMeasurements []atlas.ProbeInfoMeasurement
+	measurements      []atlas.Measurement
+	sslcert           map[int][]atlas.SSLCertResult
+	http              map[int][]atlas.HTTPResult
+	ntp               map[int][]atlas.NTPResult
+	apiKey            *atlas.APIKey
+	err               error
+}
+
+func (f *fakeClient) GetCredits(_ context.Context) (*atlas.CreditAPIResponse, error) {
+	return f.credits, f.err
+}
+
+func (f *fakeClient) GetMyProbes(_ context.Context) ([]atlas.ProbeInfo, error) {
+	return f.probes, f.err
+}
+
+func (f *fakeClient) GetMyProbesMeasurements(_ context.Context) ([]atlas.ProbeInfoMeasurement, error) {
+	return f.probeMeasurements, f.err
+}
+
+func (f *fakeClient) GetMyMeasurements(_ context.Context) ([]atlas.Measurement, error) {
+	return f.measurements, f.err
+}
+
+func (f *fakeClient) GetMeasurement(_ context.Context, measurementID int) (*atlas.Measurement, error) {
+	for i := range f.measurements {
+		if f.measurements[i].ID == measurementID {
+			return &f.measurements[i], nil
+		}
+	}
+	return nil, fmt.Errorf("measurement %d: %w", measurementID, errNotFound)
+}
+
+func (f *fakeClient) GetLatestSSLCertResults(_ context.Context, measurementID int) ([]atlas.SSLCertResult, error) {
+	return latest(f.sslcert, measurementID)
+}
+
+func (f *fakeClient) GetLatestHTTPResults(_ context.Context, measurementID int) ([]atlas.HTTPResult, error) {
+	return latest(f.http, measurementID)
+}
+
+func (f *fakeClient) GetLatestNTPResults(_ context.Context, measurementID int) ([]atlas.NTPResult, error) {
+	return latest(f.ntp, measurementID)
+}
+
+func (f *fakeClient) GetAPIKey(_ context.Context) (*atlas.APIKey, error) {
+	return f.apiKey, f.err
+}
+
+func latest[T any](results map[int][]T, measurementID int) ([]T, error) {
+	if result, ok := results[measurementID]; ok {
+		return result, nil
+	}
+	return nil, fmt.Errorf("measurement %d: %w", measurementID, errNotFound)
+}
+
+// decode converts a fixture written as it is returned by the Atlas API into T.
+func decode[T any](t *testing.T, fixture any) T {
+	t.Helper()
+	var decoded T
+	encoded, err := json.Marshal(fixture)
+	if err != nil {
+		t.Fatalf("Failed to marshal fixture: %v", err)
+	}
+	if err = json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal fixture: %v", err)
+	}
+	return decoded
+}
+
+func testLogger() logrus.FieldLogger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func TestNewOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		expected string
+	}{
+		{"unknown collector", []Option{WithCollectors("credits", "bogus")}, "unknown collector: bogus"},
+		{"unknown refresh interval collector", []Option{WithCollectorRefreshInterval("bogus", time.Minute)}, "unknown collector: bogus"},
+		{"invalid refresh interval", []Option{WithRefreshInterval(0)}, "refresh interval must be greater than 0"},
+		{"invalid timeout", []Option{WithTimeout(-time.Second)}, "timeout must be greater than 0"},
+		{"invalid measurement", []Option{WithNTPMeasurements(1, -1)}, "ntp measurement ID must be greater than 0"},
+		{"invalid metadata limit", []Option{WithMeasurementMetadataLimit(-1)}, "measurement metadata limit must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(&fakeClient{}, testLogger(), tt.opts...)
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("expected error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestExporterCollectors(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		expected string
+	}{
+		{"defaults", nil, "credits,credit_forecast,probe_last_connected,probe_measurements,measurement_metadata,sslcert,http,ntp"},
+		{"selected", []Option{WithCollectors("ntp", "credits")}, "credits,ntp"},
+		{"metadata limit", []Option{WithMeasurementMetadataLimit(0)}, "credits,credit_forecast,probe_last_connected,probe_measurements,sslcert,http,ntp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := New(&fakeClient{}, testLogger(), tt.opts...)
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+			names := make([]string, len(e.collectors))
+			for i, c := range e.collectors {
+				names[i] = c.name
+			}
+			if got := strings.Join(names, ","); got != tt.expected {
+				t.Errorf("expected collectors %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestExporterCollectorEnabled(t *testing.T) {
+	e, err := New(&fakeClient{}, testLogger(), WithCollectors("credits", "ntp"))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err = testutil.CollectAndCompare(e, strings.NewReader(`
+# HELP atlas_exporter_collector_enabled Whether each collector is enabled (1 = enabled)
+# TYPE atlas_exporter_collector_enabled gauge
+atlas_exporter_collector_enabled{collector="api_key"} 0
+atlas_exporter_collector_enabled{collector="credit_forecast"} 0
+atlas_exporter_collector_enabled{collector="credits"} 1
+atlas_exporter_collector_enabled{collector="http"} 0
+atlas_exporter_collector_enabled{collector="measurement_metadata"} 0
+atlas_exporter_collector_enabled{collector="ntp"} 1
+atlas_exporter_collector_enabled{collector="probe_last_connected"} 0
+atlas_exporter_collector_enabled{collector="probe_measurements"} 0
+atlas_exporter_collector_enabled{collector="sslcert"} 0
+`), "atlas_exporter_collector_enabled"); err != nil {
+		t.Errorf("unexpected collector enabled metrics: %v", err)
+	}
+}
+
+func TestExporterHandler(t *testing.T) {
+	client := &fakeClient{credits: &atlas.CreditAPIResponse{CurrentBalance: 1234}}
+	e, err := New(client, testLogger(), WithCollectors("credits"))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err = e.Refresh(t.Context(), ""); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if err = e.Refresh(t.Context(), "ntp"); !errors.Is(err, ErrUnknownCollector) {
+		t.Errorf("expected refresh of a disabled collector to fail with ErrUnknownCollector, got %v", err)
+	}
+
+	recorder := httptest.NewRecorder()
+	e.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := recorder.Body.String()
+	for _, expected := range []string{
+		"atlas_exporter_credits 1234",
+		`atlas_exporter_collector_success{collector="credits"} 1`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected response to contain %q, got:\n%s", expected, body)
+		}
+	}
+}
+
+func TestExporterStart(t *testing.T) {
+	client := &fakeClient{err: errors.New("atlas is down")}
+	e, err := New(client, testLogger(), WithCollectors("credits"), WithRefreshInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	e.Start(ctx)
+	deadline := time.Now().Add(5 * time.Second)
+	for e.collectors[0].current.Load().err == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("collector was not refreshed after Start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestExporterStatus(t *testing.T) {
+	client := &fakeClient{err: errors.New("atlas is down")}
+	e, err := New(client, testLogger(), WithCollectors("credits", "probe_last_connected"), WithCollectorRefreshInterval("credits", time.Minute))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err = e.Refresh(t.Context(), "credits"); err == nil {
+		t.Fatal("expected the refresh to fail")
+	}
+	statuses := e.Status()
+	if len(statuses) != 2 {
+		t.Fatalf("expected the status of 2 collectors, got %d", len(statuses))
+	}
+	if credits := statuses[0]; credits.Name != "credits" || credits.Interval != time.Minute || credits.Err == nil || !credits.LastSuccess.IsZero() {
+		t.Errorf("expected a failed credits refresh, got %+v", credits)
+	}
+	if probes := statuses[1]; probes.Name != "probe_last_connected" || probes.Err != nil || !probes.LastSuccess.IsZero() {
+		t.Errorf("expected probe_last_connected not to be refreshed yet, got %+v", probes)
+	}
+}
+
+func TestExporterInherit(t *testing.T) {
+	previous, err := New(&fakeClient{credits: &atlas.CreditAPIResponse{CurrentBalance: 500}}, testLogger(), WithCollectors("credits", "probe_last_connected"))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err = previous.Refresh(t.Context(), ""); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	e, err := New(&fakeClient{err: errors.New("atlas is down")}, testLogger(), WithCollectors("credits", "probe_last_connected"))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	e.Inherit(previous, "credits")
+	expected := `
+# HELP atlas_exporter_credits Current number of credits available in the Atlas account
+# TYPE atlas_exporter_credits gauge
+atlas_exporter_credits 500
+`
+	statuses := e.Status()
+	if credits := statuses[0]; credits.LastSuccess.IsZero() || credits.Err != nil {
+		t.Errorf("expected credits to inherit the previous refresh, got %+v", credits)
+	}
+	if probes := statuses[1]; !probes.LastSuccess.IsZero() {
+		t.Errorf("expected probe_last_connected not to be inherited, got %+v", probes)
+	}
+	if err = testutil.CollectAndCompare(e, strings.NewReader(expected), "atlas_exporter_credits"); err != nil {
+		t.Errorf("expected the inherited credits metric: %v", err)
+	}
+	if err = e.Refresh(t.Context(), "credits"); err == nil {
+		t.Fatal("expected the refresh to fail")
+	}
+	if err = testutil.CollectAndCompare(e, strings.NewReader(expected), "atlas_exporter_credits"); err != nil {
+		t.Errorf("expected a failed refresh to keep the inherited metric: %v", err)
+	}
+}
+
+func TestExporterDuplicateMeasurements(t *testing.T) {
+	client := &fakeClient{ntp: map[int][]atlas.NTPResult{}}
+	client.ntp[1003] = decode[[]atlas.NTPResult](t, []map[string]any{
+		{"msm_id": 1003, "prb_id": 1, "dst_name": "ntp.example.com", "result": []map[string]any{{"offset": 0.002, "rtt": 0.02}}},
+	})
+	e, err := New(client, testLogger(), WithCollectors("ntp"), WithNTPMeasurements(1003, 1003))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err = e.Refresh(t.Context(), ""); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	recorder := httptest.NewRecorder()
+	e.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if recorder.Code != http.StatusOK || strings.Count(recorder.Body.String(), "atlas_exporter_ntp_offset_seconds{") != 1 {
+		t.Errorf("expected a single series per measurement, got %d:\n%s", recorder.Code, recorder.Body.String())
+	}
+}
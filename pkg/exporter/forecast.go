@@ -0,0 +1,242 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/state"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// creditsNamespace and balanceSeries hold the balance history in the store.
+	creditsNamespace = "credits"
+	balanceSeries    = "balance"
+
+	// forecastHistory is the history used by the projections.
+	forecastHistory = 7 * 24 * time.Hour
+	// minLinearHistory is the history needed before a linear projection.
+	minLinearHistory = time.Hour
+	// maxForecastHorizon is how far ahead the seasonal projection looks.
+	maxForecastHorizon = 5 * 365 * 24 * time.Hour
+)
+
+// burnRateWindows are the windows of the burn rates, by label value.
+var burnRateWindows = []struct {
+	label  string
+	window time.Duration
+}{
+	{"1h", time.Hour},
+	{"24h", 24 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+}
+
+var (
+	creditsBurnRateDesc = prometheus.NewDesc(
+		"atlas_exporter_credits_burn_rate_per_hour",
+		"Credits spent per hour over each window, net of income, from the balance history",
+		[]string{"window"},
+		nil,
+	)
+	creditsExhaustionDesc = prometheus.NewDesc(
+		"atlas_exporter_credits_projected_exhaustion_timestamp_seconds",
+		"Time (Unix timestamp) the credits are projected to run out by each method",
+		[]string{"method"},
+		nil,
+	)
+	creditsRunwayDesc = prometheus.NewDesc(
+		"atlas_exporter_credits_runway_days",
+		"Days until the credits are projected to run out by each method",
+		[]string{"method"},
+		nil,
+	)
+)
+
+// creditSample is a balance recorded in the history.
+type creditSample struct {
+	time    time.Time
+	balance float64
+}
+
+// CreditForecastCollector forecasts when the credits run out from the balance
+// history that the CreditsCollector records in the state store. It does not
+// call the Atlas API itself.
+type CreditForecastCollector struct {
+	logger logrus.FieldLogger
+	store  state.Store
+	now    func() time.Time
+}
+
+func (c *CreditForecastCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- creditsBurnRateDesc
+	ch <- creditsExhaustionDesc
+	ch <- creditsRunwayDesc
+}
+
+// recordBalance appends balance at t to the history of store.
+func recordBalance(store state.Store, t time.Time, balance int) error {
+	if err := store.Append(creditsNamespace, balanceSeries, t, strconv.AppendInt(nil, int64(balance), 10)); err != nil {
+		return fmt.Errorf("failed to record credit balance: %w", err)
+	}
+	return nil
+}
+
+func (c *CreditForecastCollector) Fetch(_ context.Context) ([]prometheus.Metric, error) {
+	c.logger.Debug("Forecasting credits")
+	now := c.now()
+	// The history starts a day before the longest window so the burn rate of
+	// the window has a sample from before it.
+	records, err := c.store.Range(creditsNamespace, balanceSeries, now.Add(-forecastHistory-24*time.Hour), now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credit history: %w", err)
+	}
+	samples := make([]creditSample, 0, len(records))
+	for _, record := range records {
+		balance, parseErr := strconv.ParseFloat(string(record.Value), 64)
+		if parseErr != nil {
+			c.logger.WithError(parseErr).Warnf("Ignoring invalid credit balance recorded at %s", record.Time)
+			continue
+		}
+		samples = append(samples, creditSample{time: record.Time, balance: balance})
+	}
+	if len(samples) == 0 {
+		// The credits collector has not recorded a balance yet.
+		return nil, nil
+	}
+
+	var metrics []prometheus.Metric
+	for _, w := range burnRateWindows {
+		if rate, ok := burnRate(samples, now, w.window); ok {
+			metrics = append(metrics, prometheus.MustNewConstMetric(creditsBurnRateDesc, prometheus.GaugeValue, rate*3600, w.label))
+		}
+	}
+	history := since(samples, now.Add(-forecastHistory))
+	balance := samples[len(samples)-1].balance
+	for _, projection := range []struct {
+		method  string
+		project func([]creditSample, time.Time, float64) (time.Time, bool)
+	}{
+		{"linear", linearExhaustion},
+		{"seasonal", seasonalExhaustion},
+	} {
+		exhaustion, ok := projection.project(history, now, balance)
+		if !ok {
+			continue
+		}
+		metrics = append(metrics,
+			prometheus.MustNewConstMetric(creditsExhaustionDesc, prometheus.GaugeValue, float64(exhaustion.UnixNano())/1e9, projection.method),
+			prometheus.MustNewConstMetric(creditsRunwayDesc, prometheus.GaugeValue, exhaustion.Sub(now).Hours()/24, projection.method),
+		)
+	}
+	return metrics, nil
+}
+
+func CreditForecastCollectorFactory(logger logrus.FieldLogger, store state.Store) Fetcher {
+	return &CreditForecastCollector{logger: logger, store: store, now: time.Now}
+}
+
+// since returns the samples from from onwards.
+func since(samples []creditSample, from time.Time) []creditSample {
+	for i, sample := range samples {
+		if !sample.time.Before(from) {
+			return samples[i:]
+		}
+	}
+	return nil
+}
+
+// burnRate returns the credits spent per second over window before now, from
+// the last sample before the window to the latest one. It is false until the
+// history covers the window.
+func burnRate(samples []creditSample, now time.Time, window time.Duration) (float64, bool) {
+	if len(samples) < 2 {
+		return 0, false
+	}
+	start := -1
+	for i, sample := range samples {
+		if sample.time.After(now.Add(-window)) {
+			break
+		}
+		start = i
+	}
+	if start < 0 || start == len(samples)-1 {
+		return 0, false
+	}
+	first, last := samples[start], samples[len(samples)-1]
+	return (first.balance - last.balance) / last.time.Sub(first.time).Seconds(), true
+}
+
+// linearExhaustion fits a line through the samples with least squares and
+// returns when balance reaches 0 at its slope. It is false when the balance
+// is not decreasing or the history is shorter than minLinearHistory.
+func linearExhaustion(samples []creditSample, now time.Time, balance float64) (time.Time, bool) {
+	if len(samples) < 2 || samples[len(samples)-1].time.Sub(samples[0].time) < minLinearHistory {
+		return time.Time{}, false
+	}
+	var sumX, sumY, sumXY, sumXX float64
+	for _, sample := range samples {
+		x := sample.time.Sub(now).Seconds()
+		sumX += x
+		sumY += sample.balance
+		sumXY += x * sample.balance
+		sumXX += x * x
+	}
+	n := float64(len(samples))
+	slope := (n*sumXY - sumX*sumY) / (n*sumXX - sumX*sumX)
+	if math.IsNaN(slope) || slope >= 0 {
+		return time.Time{}, false
+	}
+	if balance <= 0 {
+		return now, true
+	}
+	return now.Add(time.Duration(balance / -slope * float64(time.Second))), true
+}
+
+// seasonalExhaustion averages the burn rate of every hour of the day (UTC) in
+// the samples, then replays the daily profile from balance until it reaches 0.
+// It is false until every hour of the day is covered by the samples, or when
+// the credits last longer than maxForecastHorizon.
+func seasonalExhaustion(samples []creditSample, now time.Time, balance float64) (time.Time, bool) {
+	var burned, covered [24]float64
+	for i := 1; i < len(samples); i++ {
+		from, to := samples[i-1], samples[i]
+		rate := (from.balance - to.balance) / to.time.Sub(from.time).Seconds()
+		for t := from.time; t.Before(to.time); {
+			end := t.Truncate(time.Hour).Add(time.Hour)
+			if end.After(to.time) {
+				end = to.time
+			}
+			hour := t.UTC().Hour()
+			seconds := end.Sub(t).Seconds()
+			burned[hour] += rate * seconds
+			covered[hour] += seconds
+			t = end
+		}
+	}
+	var rates [24]float64
+	for hour := range rates {
+		if covered[hour] == 0 {
+			return time.Time{}, false
+		}
+		rates[hour] = burned[hour] / covered[hour]
+	}
+	if balance <= 0 {
+		return now, true
+	}
+	remaining := balance
+	for t := now; t.Before(now.Add(maxForecastHorizon)); {
+		end := t.Truncate(time.Hour).Add(time.Hour)
+		rate := rates[t.UTC().Hour()]
+		spent := rate * end.Sub(t).Seconds()
+		if rate > 0 && spent >= remaining {
+			return t.Add(time.Duration(remaining / rate * float64(time.Second))), true
+		}
+		remaining -= spent
+		t = end
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,197 @@
+package exporter
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/atlas"
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/state"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// forecastNow is midnight UTC.
+var forecastNow = time.Date(2026, time.March, 2, 0, 0, 0, 0, time.UTC)
+
+// collectValues returns the value of every metric of c by metric name and
+// label values.
+func collectValues(t *testing.T, c prometheus.Collector) map[string]float64 {
+	t.Helper()
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(c)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+	values := map[string]float64{}
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			key := family.GetName()
+			for _, label := range metric.GetLabel() {
+				key += "/" + label.GetValue()
+			}
+			values[key] = metricValue(metric)
+		}
+	}
+	return values
+}
+
+func metricValue(metric *dto.Metric) float64 {
+	if metric.GetGauge() != nil {
+		return metric.GetGauge().GetValue()
+	}
+	return metric.GetCounter().GetValue()
+}
+
+func TestCreditForecastCollector(t *testing.T) {
+	// spendDuringDay spends 30 credits per hour from 08:00 to 16:00 UTC.
+	spendDuringDay := func(at time.Time) float64 {
+		if hour := at.UTC().Hour(); hour >= 8 && hour < 16 {
+			return 30
+		}
+		return 0
+	}
+	tests := []struct {
+		name string
+		// history is how long the balance was recorded every 5 minutes.
+		history time.Duration
+		// spent returns the credits spent per hour at a time.
+		spent   func(at time.Time) float64
+		balance int
+		// expected is the value of every exported metric, NaN when it is
+		// exported with any value.
+		expected map[string]float64
+	}{
+		{
+			name:    "constant spending",
+			history: 8 * 24 * time.Hour,
+			spent:   func(time.Time) float64 { return 10 },
+			balance: 1000,
+			expected: map[string]float64{
+				"atlas_exporter_credits_burn_rate_per_hour/1h":                           10,
+				"atlas_exporter_credits_burn_rate_per_hour/24h":                          10,
+				"atlas_exporter_credits_burn_rate_per_hour/7d":                           10,
+				"atlas_exporter_credits_projected_exhaustion_timestamp_seconds/linear":   float64(forecastNow.Add(100 * time.Hour).Unix()),
+				"atlas_exporter_credits_projected_exhaustion_timestamp_seconds/seasonal": float64(forecastNow.Add(100 * time.Hour).Unix()),
+				"atlas_exporter_credits_runway_days/linear":                              100.0 / 24,
+				"atlas_exporter_credits_runway_days/seasonal":                            100.0 / 24,
+			},
+		},
+		{
+			name:    "daily pattern",
+			history: 8 * 24 * time.Hour,
+			spent:   spendDuringDay,
+			balance: 300,
+			expected: map[string]float64{
+				"atlas_exporter_credits_burn_rate_per_hour/1h":  0,
+				"atlas_exporter_credits_burn_rate_per_hour/24h": 10,
+				"atlas_exporter_credits_burn_rate_per_hour/7d":  10,
+				// The least squares fit of the daily steps is only close to 10
+				// per hour. The seasonal projection spends 240 credits
+				// tomorrow and the last 60 by 10:00 the day after.
+				"atlas_exporter_credits_projected_exhaustion_timestamp_seconds/linear":   math.NaN(),
+				"atlas_exporter_credits_projected_exhaustion_timestamp_seconds/seasonal": float64(forecastNow.Add(34 * time.Hour).Unix()),
+				"atlas_exporter_credits_runway_days/linear":                              math.NaN(),
+				"atlas_exporter_credits_runway_days/seasonal":                            34.0 / 24,
+			},
+		},
+		{
+			name:    "income exceeds spending",
+			history: 2 * 24 * time.Hour,
+			spent:   func(time.Time) float64 { return -5 },
+			balance: 1000,
+			expected: map[string]float64{
+				"atlas_exporter_credits_burn_rate_per_hour/1h":  -5,
+				"atlas_exporter_credits_burn_rate_per_hour/24h": -5,
+			},
+		},
+		{
+			name:     "short history",
+			history:  30 * time.Minute,
+			spent:    func(time.Time) float64 { return 10 },
+			balance:  1000,
+			expected: map[string]float64{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := state.NewMemory(state.Retention{})
+			// Replay the history backwards from the current balance, as
+			// recorded by the credits collector.
+			if err := recordBalance(store, forecastNow, tt.balance); err != nil {
+				t.Fatalf("recordBalance failed: %v", err)
+			}
+			balance := float64(tt.balance)
+			for at := forecastNow.Add(-5 * time.Minute); !at.Before(forecastNow.Add(-tt.history)); at = at.Add(-5 * time.Minute) {
+				balance += tt.spent(at) / 12
+				if err := store.Append("credits", "balance", at, strconv.AppendFloat(nil, balance, 'f', -1, 64)); err != nil {
+					t.Fatalf("Append failed: %v", err)
+				}
+			}
+			fetcher := &CreditForecastCollector{logger: testLogger(), store: store, now: func() time.Time { return forecastNow }}
+
+			values := collectValues(t, refreshedCollector(t, "credit_forecast", fetcher))
+			for key, expected := range tt.expected {
+				got, ok := values[key]
+				if !ok {
+					t.Errorf("expected %s to be exported", key)
+					continue
+				}
+				if !math.IsNaN(expected) && math.Abs(got-expected) > 1e-6 {
+					t.Errorf("expected %s to be %v, got %v", key, expected, got)
+				}
+			}
+			for key := range values {
+				if _, ok := tt.expected[key]; !ok {
+					t.Errorf("unexpected metric %s = %v", key, values[key])
+				}
+			}
+			records, err := store.Range("credits", "balance", forecastNow, forecastNow)
+			if err != nil || len(records) != 1 || string(records[0].Value) != strconv.Itoa(tt.balance) {
+				t.Errorf("expected the current balance to be recorded, got %v, %v", records, err)
+			}
+		})
+	}
+}
+
+func TestCreditForecastSharesCredits(t *testing.T) {
+	store := state.NewMemory(state.Retention{})
+	client := &countingClient{fakeClient: fakeClient{credits: &atlas.CreditAPIResponse{CurrentBalance: 1000}}}
+	e, err := New(client, testLogger(), WithCollectors("credits", "credit_forecast"), WithStore(store))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err = e.Refresh(t.Context(), ""); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if calls := client.creditCalls.Load(); calls != 1 {
+		t.Errorf("expected the credits to be fetched once, got %d calls", calls)
+	}
+	records, err := store.Range("credits", "balance", time.Time{}, time.Now())
+	if err != nil || len(records) != 1 || string(records[0].Value) != "1000" {
+		t.Errorf("expected the credits collector to record the balance, got %v, %v", records, err)
+	}
+
+	e, err = New(client, testLogger(), WithCollectors("credit_forecast"), WithStore(store))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if len(e.collectors) != 0 {
+		t.Errorf("expected credit_forecast to be disabled without the credits collector, got %d collectors", len(e.collectors))
+	}
+}
+
+// countingClient counts the calls to GetCredits.
+type countingClient struct {
+	fakeClient
+	creditCalls atomic.Int32
+}
+
+func (c *countingClient) GetCredits(ctx context.Context) (*atlas.CreditAPIResponse, error) {
+	c.creditCalls.Add(1)
+	return c.fakeClient.GetCredits(ctx)
+}
@@ -0,0 +1,338 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/atlas"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	sslCertLabels       = []string{"measurement_id", "probe_id", "target"}
+	sslCertNotAfterDesc = prometheus.NewDesc(
+		"atlas_exporter_sslcert_not_after",
+		"Expiry time (Unix timestamp) of the leaf certificate served to the probe",
+		sslCertLabels,
+		nil,
+	)
+	sslCertInfoDesc = prometheus.NewDesc(
+		"atlas_exporter_sslcert_info",
+		"Issuer, subject and TLS version of the leaf certificate served to the probe",
+		append(sslCertLabels, "issuer", "subject", "tls_version"),
+		nil,
+	)
+	sslCertHostnameMatchDesc = prometheus.NewDesc(
+		"atlas_exporter_sslcert_hostname_match",
+		"Whether the subject or SANs of the leaf certificate match the target (1 = match)",
+		sslCertLabels,
+		nil,
+	)
+	sslCertChainLengthDesc = prometheus.NewDesc(
+		"atlas_exporter_sslcert_chain_length",
+		"Number of certificates in the chain served to the probe",
+		sslCertLabels,
+		nil,
+	)
+	sslCertHandshakeDesc = prometheus.NewDesc(
+		"atlas_exporter_sslcert_handshake_seconds",
+		"Time taken by the probe to complete the TLS handshake after connecting in seconds",
+		sslCertLabels,
+		nil,
+	)
+	sslCertSuccessDesc = measurementSuccessDesc("sslcert")
+)
+
+// measurementSuccessDesc describes whether the latest results of each
+// measurement of a kind were fetched.
+func measurementSuccessDesc(kind string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		fmt.Sprintf("atlas_exporter_%s_success", kind),
+		fmt.Sprintf("Whether the latest results of the %s measurement were fetched (1 = success)", kind),
+		[]string{"measurement_id"},
+		nil,
+	)
+}
+
+func init() {
+	registerCollector("sslcert", true, "latest results of the sslcert measurements", func(e *Exporter) Fetcher {
+		return SSLCertCollectorFactory(e.client, e.logger, e.measurements["sslcert"])
+	})
+	registerCollector("http", true, "latest results of the http measurements", func(e *Exporter) Fetcher {
+		return HTTPCollectorFactory(e.client, e.logger, e.measurements["http"])
+	})
+	registerCollector("ntp", true, "latest results of the ntp measurements", func(e *Exporter) Fetcher {
+		return NTPCollectorFactory(e.client, e.logger, e.measurements["ntp"])
+	})
+}
+
+type SSLCertCollector struct {
+	client       Client
+	logger       logrus.FieldLogger
+	measurements []int
+}
+
+func (c *SSLCertCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- sslCertNotAfterDesc
+	ch <- sslCertInfoDesc
+	ch <- sslCertHostnameMatchDesc
+	ch <- sslCertChainLengthDesc
+	ch <- sslCertHandshakeDesc
+	ch <- sslCertSuccessDesc
+}
+
+func (c *SSLCertCollector) Fetch(ctx context.Context) ([]prometheus.Metric, error) {
+	return fetchMeasurements(ctx, c.logger, "sslcert", sslCertSuccessDesc, c.measurements, c.fetchMeasurement)
+}
+
+func (c *SSLCertCollector) fetchMeasurement(ctx context.Context, measurementID int) ([]prometheus.Metric, error) {
+	results, err := c.client.GetLatestSSLCertResults(ctx, measurementID)
+	if err != nil {
+		return nil, err
+	}
+	var metrics []prometheus.Metric
+	for _, result := range results {
+		target := result.DstName
+		if target == "" {
+			target = result.DstAddr
+		}
+		labels := []string{fmt.Sprintf("%d", measurementID), fmt.Sprintf("%d", result.ProbeID), target}
+		certificates, parseErr := result.ParseCertificates()
+		if parseErr != nil {
+			c.logger.WithError(parseErr).Debugf("Skipping sslcert result of probe %d for measurement %d", result.ProbeID, measurementID)
+			continue
+		}
+		leaf := certificates[0]
+		hostnameMatch := 0.0
+		if leaf.VerifyHostname(target) == nil {
+			hostnameMatch = 1
+		}
+		metrics = append(metrics, prometheus.MustNewConstMetric(sslCertNotAfterDesc, prometheus.GaugeValue, float64(leaf.NotAfter.Unix()), labels...))
+		metrics = append(metrics, prometheus.MustNewConstMetric(sslCertInfoDesc, prometheus.GaugeValue, 1, append(labels, leaf.Issuer.String(), leaf.Subject.String(), result.Version)...))
+		metrics = append(metrics, prometheus.MustNewConstMetric(sslCertHostnameMatchDesc, prometheus.GaugeValue, hostnameMatch, labels...))
+		metrics = append(metrics, prometheus.MustNewConstMetric(sslCertChainLengthDesc, prometheus.GaugeValue, float64(len(certificates)), labels...))
+		metrics = append(metrics, prometheus.MustNewConstMetric(sslCertHandshakeDesc, prometheus.GaugeValue, (result.ResponseTime-result.TimeToConnect)/1000, labels...))
+	}
+	return metrics, nil
+}
+
+func SSLCertCollectorFactory(client Client, logger logrus.FieldLogger, measurements []int) Fetcher {
+	return &SSLCertCollector{client: client, logger: logger, measurements: measurements}
+}
+
+var (
+	httpLabels         = []string{"measurement_id", "probe_id", "target", "af"}
+	httpStatusCodeDesc = prometheus.NewDesc(
+		"atlas_exporter_http_status_code",
+		"HTTP status code returned to the probe",
+		httpLabels,
+		nil,
+	)
+	httpTimeToFirstByteDesc = prometheus.NewDesc(
+		"atlas_exporter_http_time_to_first_byte_seconds",
+		"Time until the first byte of the response was received by the probe in seconds",
+		httpLabels,
+		nil,
+	)
+	httpTotalTimeDesc = prometheus.NewDesc(
+		"atlas_exporter_http_total_seconds",
+		"Total time taken by the probe to complete the request in seconds",
+		httpLabels,
+		nil,
+	)
+	httpHeaderSizeDesc = prometheus.NewDesc(
+		"atlas_exporter_http_header_bytes",
+		"Size of the response headers received by the probe in bytes",
+		httpLabels,
+		nil,
+	)
+	httpBodySizeDesc = prometheus.NewDesc(
+		"atlas_exporter_http_body_bytes",
+		"Size of the response body received by the probe in bytes",
+		httpLabels,
+		nil,
+	)
+	httpErrorDesc = prometheus.NewDesc(
+		"atlas_exporter_http_error",
+		"Whether the request made by the probe failed (1 = error)",
+		httpLabels,
+		nil,
+	)
+	httpSuccessDesc = measurementSuccessDesc("http")
+)
+
+type HTTPCollector struct {
+	client       Client
+	logger       logrus.FieldLogger
+	measurements []int
+}
+
+func (c *HTTPCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- httpStatusCodeDesc
+	ch <- httpTimeToFirstByteDesc
+	ch <- httpTotalTimeDesc
+	ch <- httpHeaderSizeDesc
+	ch <- httpBodySizeDesc
+	ch <- httpErrorDesc
+	ch <- httpSuccessDesc
+}
+
+func (c *HTTPCollector) Fetch(ctx context.Context) ([]prometheus.Metric, error) {
+	return fetchMeasurements(ctx, c.logger, "http", httpSuccessDesc, c.measurements, c.fetchMeasurement)
+}
+
+func (c *HTTPCollector) fetchMeasurement(ctx context.Context, measurementID int) ([]prometheus.Metric, error) {
+	results, err := c.client.GetLatestHTTPResults(ctx, measurementID)
+	if err != nil {
+		return nil, err
+	}
+	var metrics []prometheus.Metric
+	for _, result := range results {
+		// A probe makes one request per address family, keep the last of each.
+		requests := make(map[int]atlas.HTTPRequestResult)
+		for _, request := range result.Results {
+			requests[request.AddressFamily] = request
+		}
+		for af, request := range requests {
+			labels := []string{
+				fmt.Sprintf("%d", measurementID),
+				fmt.Sprintf("%d", result.ProbeID),
+				result.URI,
+				fmt.Sprintf("%d", af),
+			}
+			if request.Error != "" {
+				metrics = append(metrics, prometheus.MustNewConstMetric(httpErrorDesc, prometheus.GaugeValue, 1, labels...))
+				continue
+			}
+			metrics = append(metrics, prometheus.MustNewConstMetric(httpErrorDesc, prometheus.GaugeValue, 0, labels...))
+			metrics = append(metrics, prometheus.MustNewConstMetric(httpStatusCodeDesc, prometheus.GaugeValue, float64(request.StatusCode), labels...))
+			metrics = append(metrics, prometheus.MustNewConstMetric(httpTotalTimeDesc, prometheus.GaugeValue, request.ResponseTime/1000, labels...))
+			metrics = append(metrics, prometheus.MustNewConstMetric(httpHeaderSizeDesc, prometheus.GaugeValue, float64(request.HeaderSize), labels...))
+			metrics = append(metrics, prometheus.MustNewConstMetric(httpBodySizeDesc, prometheus.GaugeValue, float64(request.BodySize), labels...))
+			if request.TimeToFirstByte > 0 {
+				metrics = append(metrics, prometheus.MustNewConstMetric(httpTimeToFirstByteDesc, prometheus.GaugeValue, request.TimeToFirstByte/1000, labels...))
+			}
+		}
+	}
+	return metrics, nil
+}
+
+func HTTPCollectorFactory(client Client, logger logrus.FieldLogger, measurements []int) Fetcher {
+	return &HTTPCollector{client: client, logger: logger, measurements: measurements}
+}
+
+var (
+	ntpLabels     = []string{"measurement_id", "probe_id", "target"}
+	ntpOffsetDesc = prometheus.NewDesc(
+		"atlas_exporter_ntp_offset_seconds",
+		"Mean clock offset between the probe and the NTP server in seconds",
+		ntpLabels,
+		nil,
+	)
+	ntpRoundTripDesc = prometheus.NewDesc(
+		"atlas_exporter_ntp_rtt_seconds",
+		"Mean round-trip delay between the probe and the NTP server in seconds",
+		ntpLabels,
+		nil,
+	)
+	ntpStratumDesc = prometheus.NewDesc(
+		"atlas_exporter_ntp_stratum",
+		"Stratum reported by the NTP server",
+		ntpLabels,
+		nil,
+	)
+	ntpPollDesc = prometheus.NewDesc(
+		"atlas_exporter_ntp_poll_seconds",
+		"Poll interval reported by the NTP server in seconds",
+		ntpLabels,
+		nil,
+	)
+	ntpReferenceDesc = prometheus.NewDesc(
+		"atlas_exporter_ntp_reference_info",
+		"Reference ID reported by the NTP server",
+		append(ntpLabels, "ref_id"),
+		nil,
+	)
+	ntpSuccessDesc = measurementSuccessDesc("ntp")
+)
+
+type NTPCollector struct {
+	client       Client
+	logger       logrus.FieldLogger
+	measurements []int
+}
+
+func (c *NTPCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- ntpOffsetDesc
+	ch <- ntpRoundTripDesc
+	ch <- ntpStratumDesc
+	ch <- ntpPollDesc
+	ch <- ntpReferenceDesc
+	ch <- ntpSuccessDesc
+}
+
+func (c *NTPCollector) Fetch(ctx context.Context) ([]prometheus.Metric, error) {
+	return fetchMeasurements(ctx, c.logger, "ntp", ntpSuccessDesc, c.measurements, c.fetchMeasurement)
+}
+
+func (c *NTPCollector) fetchMeasurement(ctx context.Context, measurementID int) ([]prometheus.Metric, error) {
+	results, err := c.client.GetLatestNTPResults(ctx, measurementID)
+	if err != nil {
+		return nil, err
+	}
+	var metrics []prometheus.Metric
+	for _, result := range results {
+		replies := result.SuccessfulReplies()
+		if len(replies) == 0 {
+			c.logger.Debugf("Skipping ntp result of probe %d for measurement %d without replies", result.ProbeID, measurementID)
+			continue
+		}
+		target := result.DstName
+		if target == "" {
+			target = result.DstAddr
+		}
+		labels := []string{fmt.Sprintf("%d", measurementID), fmt.Sprintf("%d", result.ProbeID), target}
+		var offset, roundTrip float64
+		for _, reply := range replies {
+			offset += reply.Offset
+			roundTrip += reply.RoundTripTime
+		}
+		metrics = append(metrics, prometheus.MustNewConstMetric(ntpOffsetDesc, prometheus.GaugeValue, offset/float64(len(replies)), labels...))
+		metrics = append(metrics, prometheus.MustNewConstMetric(ntpRoundTripDesc, prometheus.GaugeValue, roundTrip/float64(len(replies)), labels...))
+		metrics = append(metrics, prometheus.MustNewConstMetric(ntpStratumDesc, prometheus.GaugeValue, float64(result.Stratum), labels...))
+		metrics = append(metrics, prometheus.MustNewConstMetric(ntpPollDesc, prometheus.GaugeValue, result.Poll, labels...))
+		metrics = append(metrics, prometheus.MustNewConstMetric(ntpReferenceDesc, prometheus.GaugeValue, 1, append(labels, result.ReferenceID)...))
+	}
+	return metrics, nil
+}
+
+func NTPCollectorFactory(client Client, logger logrus.FieldLogger, measurements []int) Fetcher {
+	return &NTPCollector{client: client, logger: logger, measurements: measurements}
+}
+
+// fetchMeasurements fetches the metrics of every measurement, along with a
+// successDesc metric telling whether each one was fetched. A measurement that
+// fails is logged and reported as failed, an error is only returned when all
+// fail.
+func fetchMeasurements(ctx context.Context, logger logrus.FieldLogger, kind string, successDesc *prometheus.Desc, measurements []int, fetch func(context.Context, int) ([]prometheus.Metric, error)) ([]prometheus.Metric, error) {
+	var metrics []prometheus.Metric
+	var errs []error
+	for _, measurementID := range measurements {
+		logger.Debugf("Collecting %s results for measurement %d", kind, measurementID)
+		measurementMetrics, err := fetch(ctx, measurementID)
+		success := 1.0
+		if err != nil {
+			logger.WithError(err).Errorf("Failed to get %s results for measurement %d", kind, measurementID)
+			errs = append(errs, err)
+			success = 0
+		}
+		metrics = append(metrics, measurementMetrics...)
+		metrics = append(metrics, prometheus.MustNewConstMetric(successDesc, prometheus.GaugeValue, success, fmt.Sprintf("%d", measurementID)))
+	}
+	if len(errs) > 0 && len(errs) == len(measurements) {
+		return nil, errors.Join(errs...)
+	}
+	return metrics, nil
+}
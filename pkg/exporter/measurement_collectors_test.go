@@ -0,0 +1,160 @@
+package exporter
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/atlas"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func testCertificatePEM(t *testing.T, commonName string, notAfter time.Time) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{commonName},
+		NotBefore:    notAfter.Add(-24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestSSLCertCollector(t *testing.T) {
+	certificate := testCertificatePEM(t, "example.com", time.Unix(1900000000, 0))
+	client := &fakeClient{sslcert: map[int][]atlas.SSLCertResult{}}
+	client.sslcert[1001] = decode[[]atlas.SSLCertResult](t, []map[string]any{
+		{"msm_id": 1001, "prb_id": 1, "dst_name": "example.com", "ver": "1.3", "rt": 42.0, "ttc": 12.0, "cert": []string{certificate}},
+		{"msm_id": 1001, "prb_id": 2, "dst_name": "www.example.org", "ver": "1.2", "rt": 100.0, "cert": []string{certificate, certificate}},
+		{"msm_id": 1001, "prb_id": 3, "dst_name": "example.com", "err": "connect: timeout"},
+	})
+
+	collector := refreshedCollector(t, "sslcert", SSLCertCollectorFactory(client, testLogger(), []int{1001}))
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(`
+# HELP atlas_exporter_sslcert_chain_length Number of certificates in the chain served to the probe
+# TYPE atlas_exporter_sslcert_chain_length gauge
+atlas_exporter_sslcert_chain_length{measurement_id="1001",probe_id="1",target="example.com"} 1
+atlas_exporter_sslcert_chain_length{measurement_id="1001",probe_id="2",target="www.example.org"} 2
+# HELP atlas_exporter_sslcert_handshake_seconds Time taken by the probe to complete the TLS handshake after connecting in seconds
+# TYPE atlas_exporter_sslcert_handshake_seconds gauge
+atlas_exporter_sslcert_handshake_seconds{measurement_id="1001",probe_id="1",target="example.com"} 0.03
+atlas_exporter_sslcert_handshake_seconds{measurement_id="1001",probe_id="2",target="www.example.org"} 0.1
+# HELP atlas_exporter_sslcert_hostname_match Whether the subject or SANs of the leaf certificate match the target (1 = match)
+# TYPE atlas_exporter_sslcert_hostname_match gauge
+atlas_exporter_sslcert_hostname_match{measurement_id="1001",probe_id="1",target="example.com"} 1
+atlas_exporter_sslcert_hostname_match{measurement_id="1001",probe_id="2",target="www.example.org"} 0
+# HELP atlas_exporter_sslcert_info Issuer, subject and TLS version of the leaf certificate served to the probe
+# TYPE atlas_exporter_sslcert_info gauge
+atlas_exporter_sslcert_info{issuer="CN=example.com",measurement_id="1001",probe_id="1",subject="CN=example.com",target="example.com",tls_version="1.3"} 1
+atlas_exporter_sslcert_info{issuer="CN=example.com",measurement_id="1001",probe_id="2",subject="CN=example.com",target="www.example.org",tls_version="1.2"} 1
+# HELP atlas_exporter_sslcert_not_after Expiry time (Unix timestamp) of the leaf certificate served to the probe
+# TYPE atlas_exporter_sslcert_not_after gauge
+atlas_exporter_sslcert_not_after{measurement_id="1001",probe_id="1",target="example.com"} 1.9e+09
+atlas_exporter_sslcert_not_after{measurement_id="1001",probe_id="2",target="www.example.org"} 1.9e+09
+# HELP atlas_exporter_sslcert_success Whether the latest results of the sslcert measurement were fetched (1 = success)
+# TYPE atlas_exporter_sslcert_success gauge
+atlas_exporter_sslcert_success{measurement_id="1001"} 1
+`)); err != nil {
+		t.Errorf("SSLCertCollector failed: %v", err)
+	}
+}
+
+func TestHTTPCollector(t *testing.T) {
+	client := &fakeClient{http: map[int][]atlas.HTTPResult{}}
+	client.http[1002] = decode[[]atlas.HTTPResult](t, []map[string]any{
+		{
+			"msm_id": 1002, "prb_id": 1, "uri": "https://example.com/",
+			"result": []map[string]any{
+				{"af": 4, "res": 200, "rt": 120.0, "ttfb": 80.0, "hsize": 312, "bsize": 1256},
+				{"af": 6, "err": "connect: Network is unreachable"},
+			},
+		},
+	})
+
+	collector := refreshedCollector(t, "http", HTTPCollectorFactory(client, testLogger(), []int{1002}))
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(`
+# HELP atlas_exporter_http_body_bytes Size of the response body received by the probe in bytes
+# TYPE atlas_exporter_http_body_bytes gauge
+atlas_exporter_http_body_bytes{af="4",measurement_id="1002",probe_id="1",target="https://example.com/"} 1256
+# HELP atlas_exporter_http_error Whether the request made by the probe failed (1 = error)
+# TYPE atlas_exporter_http_error gauge
+atlas_exporter_http_error{af="4",measurement_id="1002",probe_id="1",target="https://example.com/"} 0
+atlas_exporter_http_error{af="6",measurement_id="1002",probe_id="1",target="https://example.com/"} 1
+# HELP atlas_exporter_http_header_bytes Size of the response headers received by the probe in bytes
+# TYPE atlas_exporter_http_header_bytes gauge
+atlas_exporter_http_header_bytes{af="4",measurement_id="1002",probe_id="1",target="https://example.com/"} 312
+# HELP atlas_exporter_http_status_code HTTP status code returned to the probe
+# TYPE atlas_exporter_http_status_code gauge
+atlas_exporter_http_status_code{af="4",measurement_id="1002",probe_id="1",target="https://example.com/"} 200
+# HELP atlas_exporter_http_time_to_first_byte_seconds Time until the first byte of the response was received by the probe in seconds
+# TYPE atlas_exporter_http_time_to_first_byte_seconds gauge
+atlas_exporter_http_time_to_first_byte_seconds{af="4",measurement_id="1002",probe_id="1",target="https://example.com/"} 0.08
+# HELP atlas_exporter_http_total_seconds Total time taken by the probe to complete the request in seconds
+# TYPE atlas_exporter_http_total_seconds gauge
+atlas_exporter_http_total_seconds{af="4",measurement_id="1002",probe_id="1",target="https://example.com/"} 0.12
+# HELP atlas_exporter_http_success Whether the latest results of the http measurement were fetched (1 = success)
+# TYPE atlas_exporter_http_success gauge
+atlas_exporter_http_success{measurement_id="1002"} 1
+`)); err != nil {
+		t.Errorf("HTTPCollector failed: %v", err)
+	}
+}
+
+func TestNTPCollector(t *testing.T) {
+	client := &fakeClient{ntp: map[int][]atlas.NTPResult{}}
+	client.ntp[1003] = decode[[]atlas.NTPResult](t, []map[string]any{
+		{
+			"msm_id": 1003, "prb_id": 1, "dst_name": "ntp.example.com", "stratum": 2, "poll": 64, "ref-id": "192.0.2.1",
+			"result": []map[string]any{
+				{"offset": 0.002, "rtt": 0.02},
+				{"offset": 0.004, "rtt": 0.04},
+				{"x": "*"},
+			},
+		},
+		{
+			"msm_id": 1003, "prb_id": 2, "dst_name": "ntp.example.com",
+			"result": []map[string]any{{"x": "*"}},
+		},
+	})
+
+	collector := refreshedCollector(t, "ntp", NTPCollectorFactory(client, testLogger(), []int{1003, 404}))
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(`
+# HELP atlas_exporter_ntp_offset_seconds Mean clock offset between the probe and the NTP server in seconds
+# TYPE atlas_exporter_ntp_offset_seconds gauge
+atlas_exporter_ntp_offset_seconds{measurement_id="1003",probe_id="1",target="ntp.example.com"} 0.003
+# HELP atlas_exporter_ntp_poll_seconds Poll interval reported by the NTP server in seconds
+# TYPE atlas_exporter_ntp_poll_seconds gauge
+atlas_exporter_ntp_poll_seconds{measurement_id="1003",probe_id="1",target="ntp.example.com"} 64
+# HELP atlas_exporter_ntp_reference_info Reference ID reported by the NTP server
+# TYPE atlas_exporter_ntp_reference_info gauge
+atlas_exporter_ntp_reference_info{measurement_id="1003",probe_id="1",ref_id="192.0.2.1",target="ntp.example.com"} 1
+# HELP atlas_exporter_ntp_rtt_seconds Mean round-trip delay between the probe and the NTP server in seconds
+# TYPE atlas_exporter_ntp_rtt_seconds gauge
+atlas_exporter_ntp_rtt_seconds{measurement_id="1003",probe_id="1",target="ntp.example.com"} 0.03
+# HELP atlas_exporter_ntp_stratum Stratum reported by the NTP server
+# TYPE atlas_exporter_ntp_stratum gauge
+atlas_exporter_ntp_stratum{measurement_id="1003",probe_id="1",target="ntp.example.com"} 2
+# HELP atlas_exporter_ntp_success Whether the latest results of the ntp measurement were fetched (1 = success)
+# TYPE atlas_exporter_ntp_success gauge
+atlas_exporter_ntp_success{measurement_id="1003"} 1
+atlas_exporter_ntp_success{measurement_id="404"} 0
+`)); err != nil {
+		t.Errorf("NTPCollector failed: %v", err)
+	}
+}
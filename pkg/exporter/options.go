@@ -0,0 +1,119 @@
+package exporter
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/state"
+)
+
+// Option is a functional option for configuring the Exporter.
+type Option func(*Exporter) error
+
+// WithCollectors enables only the named collectors.
+func WithCollectors(names ...string) Option {
+	return func(e *Exporter) error {
+		for _, name := range names {
+			if !slices.Contains(CollectorNames(), name) {
+				return fmt.Errorf("%w: %s", ErrUnknownCollector, name)
+			}
+		}
+		e.enabled = append([]string{}, names...)
+		return nil
+	}
+}
+
+// WithRefreshInterval sets the interval between refreshes of every collector.
+func WithRefreshInterval(interval time.Duration) Option {
+	return func(e *Exporter) error {
+		if interval <= 0 {
+			return fmt.Errorf("refresh interval must be greater than 0, got %s", interval)
+		}
+		e.refreshInterval = interval
+		return nil
+	}
+}
+
+// WithCollectorRefreshInterval overrides the refresh interval of the named collector.
+func WithCollectorRefreshInterval(name string, interval time.Duration) Option {
+	return func(e *Exporter) error {
+		if !slices.Contains(CollectorNames(), name) {
+			return fmt.Errorf("%w: %s", ErrUnknownCollector, name)
+		}
+		if interval < 0 {
+			return fmt.Errorf("refresh interval of collector %s must not be negative, got %s", name, interval)
+		}
+		e.refreshIntervals[name] = interval
+		return nil
+	}
+}
+
+// WithTimeout sets the timeout of each refresh and of each TargetCollector fetch.
+func WithTimeout(timeout time.Duration) Option {
+	return func(e *Exporter) error {
+		if timeout <= 0 {
+			return fmt.Errorf("timeout must be greater than 0, got %s", timeout)
+		}
+		e.timeout = timeout
+		return nil
+	}
+}
+
+// WithSSLCertMeasurements sets the sslcert measurements exported by the sslcert collector.
+func WithSSLCertMeasurements(measurementIDs ...int) Option {
+	return withMeasurements("sslcert", measurementIDs)
+}
+
+// WithHTTPMeasurements sets the http measurements exported by the http collector.
+func WithHTTPMeasurements(measurementIDs ...int) Option {
+	return withMeasurements("http", measurementIDs)
+}
+
+// WithNTPMeasurements sets the ntp measurements exported by the ntp collector.
+func WithNTPMeasurements(measurementIDs ...int) Option {
+	return withMeasurements("ntp", measurementIDs)
+}
+
+// withMeasurements sets the measurements of kind, without duplicates as
+// their metrics would collide.
+func withMeasurements(kind string, measurementIDs []int) Option {
+	return func(e *Exporter) error {
+		var unique []int
+		for _, measurementID := range measurementIDs {
+			if measurementID <= 0 {
+				return fmt.Errorf("%s measurement ID must be greater than 0, got %d", kind, measurementID)
+			}
+			if !slices.Contains(unique, measurementID) {
+				unique = append(unique, measurementID)
+			}
+		}
+		e.measurements[kind] = unique
+		return nil
+	}
+}
+
+// WithMeasurementMetadataLimit sets the maximum number of owned measurements
+// exported by the measurement_metadata collector. 0 disables the collector.
+func WithMeasurementMetadataLimit(limit int) Option {
+	return func(e *Exporter) error {
+		if limit < 0 {
+			return fmt.Errorf("measurement metadata limit must not be negative, got %d", limit)
+		}
+		e.measurementMetadataLimit = limit
+		return nil
+	}
+}
+
+// WithStore sets the store where collectors keep state across restarts, such
+// as the credit history. The store is not closed by the Exporter.
+func WithStore(store state.Store) Option {
+	return func(e *Exporter) error {
+		if store == nil {
+			return errors.New("state store must not be nil")
+		}
+		e.store = store
+		return nil
+	}
+}
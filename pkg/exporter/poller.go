@@ -0,0 +1,172 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrUnknownCollector = errors.New("unknown collector")
+
+	lastRefreshDesc = prometheus.NewDesc(
+		"atlas_exporter_last_refresh_timestamp_seconds",
+		"Time (Unix timestamp) of the last successful refresh of each collector",
+		[]string{"collector"},
+		nil,
+	)
+	collectorDurationDesc = prometheus.NewDesc(
+		"atlas_exporter_collector_duration_seconds",
+		"Duration of the last refresh of each collector in seconds",
+		[]string{"collector"},
+		nil,
+	)
+	collectorSuccessDesc = prometheus.NewDesc(
+		"atlas_exporter_collector_success",
+		"Whether the last refresh of each collector succeeded (1 = success)",
+		[]string{"collector"},
+		nil,
+	)
+)
+
+// Fetcher is implemented by collectors whose metrics come from the Atlas API.
+// Fetch is only called by the background refresh, never during a scrape.
+type Fetcher interface {
+	Describe(ch chan<- *prometheus.Desc)
+	Fetch(ctx context.Context) ([]prometheus.Metric, error)
+}
+
+// snapshot is the immutable result of a refresh. A failed refresh keeps the
+// metrics of the previous snapshot.
+type snapshot struct {
+	metrics     []prometheus.Metric
+	lastSuccess time.Time
+	duration    time.Duration
+	err         error
+}
+
+// CollectorStatus is the outcome of the last refresh of a collector.
+// LastSuccess is zero until the first successful refresh and Err is the error
+// of the last refresh, nil if it succeeded or has not happened yet.
+type CollectorStatus struct {
+	Name        string
+	Interval    time.Duration
+	LastSuccess time.Time
+	Duration    time.Duration
+	Err         error
+}
+
+// cachedCollector exposes the last snapshot of a Fetcher.
+type cachedCollector struct {
+	name     string
+	fetcher  Fetcher
+	interval time.Duration
+	timeout  time.Duration
+	logger   logrus.FieldLogger
+	current  atomic.Pointer[snapshot]
+	mu       sync.Mutex
+}
+
+func newCachedCollector(name string, fetcher Fetcher, interval, timeout time.Duration, logger logrus.FieldLogger) *cachedCollector {
+	c := &cachedCollector{name: name, fetcher: fetcher, interval: interval, timeout: timeout, logger: logger}
+	c.current.Store(&snapshot{})
+	return c
+}
+
+func (c *cachedCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.fetcher.Describe(ch)
+}
+
+func (c *cachedCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, metric := range c.current.Load().metrics {
+		ch <- metric
+	}
+}
+
+// Refresh fetches new metrics and replaces the snapshot. Concurrent refreshes
+// of the same collector are serialised.
+func (c *cachedCollector) Refresh(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	metrics, err := c.fetcher.Fetch(ctx)
+	previous := c.current.Load()
+	next := &snapshot{
+		metrics:     metrics,
+		lastSuccess: start,
+		duration:    time.Since(start),
+		err:         err,
+	}
+	if err != nil {
+		c.logger.WithError(err).Errorf("Failed to refresh %s collector", c.name)
+		next.metrics = previous.metrics
+		next.lastSuccess = previous.lastSuccess
+	}
+	c.current.Store(next)
+	return err
+}
+
+// run refreshes the collector every interval until ctx is cancelled. The first
+// refresh is immediate unless the snapshot already succeeded within the
+// interval, after a Refresh or Inherit.
+func (c *cachedCollector) run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	if current := c.current.Load(); current.err != nil || time.Since(current.lastSuccess) >= c.interval {
+		_ = c.Refresh(ctx)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		_ = c.Refresh(ctx)
+	}
+}
+
+// statusCollector reports when each collector was last refreshed.
+type statusCollector []*cachedCollector
+
+func (s statusCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- lastRefreshDesc
+	ch <- collectorDurationDesc
+	ch <- collectorSuccessDesc
+	ch <- collectorEnabledDesc
+}
+
+func (s statusCollector) Collect(ch chan<- prometheus.Metric) {
+	enabled := map[string]bool{}
+	for _, c := range s {
+		enabled[c.name] = true
+	}
+	for _, name := range CollectorNames() {
+		value := 0.0
+		if enabled[name] {
+			value = 1
+		}
+		ch <- prometheus.MustNewConstMetric(collectorEnabledDesc, prometheus.GaugeValue, value, name)
+	}
+	for _, c := range s {
+		current := c.current.Load()
+		lastSuccess := 0.0
+		if !current.lastSuccess.IsZero() {
+			lastSuccess = float64(current.lastSuccess.UnixNano()) / 1e9
+		}
+		ch <- prometheus.MustNewConstMetric(lastRefreshDesc, prometheus.GaugeValue, lastSuccess, c.name)
+		success := 0.0
+		if current.err == nil && !current.lastSuccess.IsZero() {
+			success = 1
+		}
+		ch <- prometheus.MustNewConstMetric(collectorDurationDesc, prometheus.GaugeValue, current.duration.Seconds(), c.name)
+		ch <- prometheus.MustNewConstMetric(collectorSuccessDesc, prometheus.GaugeValue, success, c.name)
+	}
+}
@@ -0,0 +1,84 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var fakeDesc = prometheus.NewDesc("atlas_exporter_fake", "Fake metric for tests", nil, nil)
+
+type fakeFetcher struct {
+	value float64
+	err   error
+	calls int
+}
+
+func (f *fakeFetcher) Describe(ch chan<- *prometheus.Desc) {
+	ch <- fakeDesc
+}
+
+func (f *fakeFetcher) Fetch(_ context.Context) ([]prometheus.Metric, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return []prometheus.Metric{prometheus.MustNewConstMetric(fakeDesc, prometheus.GaugeValue, f.value)}, nil
+}
+
+// refreshedCollector wraps fetcher in a cachedCollector that has been refreshed once.
+func refreshedCollector(t *testing.T, name string, fetcher Fetcher) *cachedCollector {
+	t.Helper()
+	collector := newCachedCollector(name, fetcher, time.Minute, 10*time.Second, testLogger())
+	if err := collector.Refresh(t.Context()); err != nil {
+		t.Fatalf("Failed to refresh %s collector: %v", name, err)
+	}
+	return collector
+}
+
+func TestCachedCollectorKeepsSnapshotOnFailure(t *testing.T) {
+	fetcher := &fakeFetcher{value: 42}
+	collector := refreshedCollector(t, "fake", fetcher)
+
+	fetcher.err = errors.New("atlas is down")
+	fetcher.value = 0
+	if err := collector.Refresh(t.Context()); err == nil {
+		t.Fatal("expected refresh to fail")
+	}
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(`
+# HELP atlas_exporter_fake Fake metric for tests
+# TYPE atlas_exporter_fake gauge
+atlas_exporter_fake 42
+`)); err != nil {
+		t.Errorf("cachedCollector did not keep the previous snapshot: %v", err)
+	}
+	if fetcher.calls != 2 {
+		t.Errorf("expected 2 fetches, got %d", fetcher.calls)
+	}
+}
+
+func TestStatusCollectorSuccess(t *testing.T) {
+	failing := newCachedCollector("failing", &fakeFetcher{err: errors.New("atlas is down")}, time.Hour, 10*time.Second, testLogger())
+	status := statusCollector{
+		refreshedCollector(t, "working", &fakeFetcher{value: 1}),
+		failing,
+		newCachedCollector("pending", &fakeFetcher{}, time.Hour, 10*time.Second, testLogger()),
+	}
+	if err := failing.Refresh(t.Context()); err == nil {
+		t.Fatal("expected refresh of the failing collector to fail")
+	}
+	if err := testutil.CollectAndCompare(status, strings.NewReader(`
+# HELP atlas_exporter_collector_success Whether the last refresh of each collector succeeded (1 = success)
+# TYPE atlas_exporter_collector_success gauge
+atlas_exporter_collector_success{collector="failing"} 0
+atlas_exporter_collector_success{collector="pending"} 0
+atlas_exporter_collector_success{collector="working"} 1
+`), "atlas_exporter_collector_success"); err != nil {
+		t.Errorf("unexpected collector success metrics: %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package exporter
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var collectorEnabledDesc = prometheus.NewDesc(
+	"atlas_exporter_collector_enabled",
+	"Whether each collector is enabled (1 = enabled)",
+	[]string{"collector"},
+	nil,
+)
+
+// collectorFactory creates the Fetcher of a collector from the settings of
+// the Exporter. It returns nil when there is nothing to fetch.
+type collectorFactory func(e *Exporter) Fetcher
+
+// CollectorInfo describes a collector available to the Exporter.
+type CollectorInfo struct {
+	Name           string
+	Help           string
+	DefaultEnabled bool
+	factory        collectorFactory
+}
+
+// collectorRegistry holds every collector in registration order.
+var collectorRegistry []CollectorInfo
+
+// registerCollector makes a collector available under name. It must be called
+// from an init function.
+func registerCollector(name string, defaultEnabled bool, help string, factory collectorFactory) {
+	for _, collector := range collectorRegistry {
+		if collector.Name == name {
+			panic(fmt.Sprintf("collector %s registered twice", name))
+		}
+	}
+	collectorRegistry = append(collectorRegistry, CollectorInfo{
+		Name:           name,
+		Help:           help,
+		DefaultEnabled: defaultEnabled,
+		factory:        factory,
+	})
+}
+
+// AvailableCollectors returns every available collector in registration order.
+func AvailableCollectors() []CollectorInfo {
+	return append([]CollectorInfo(nil), collectorRegistry...)
+}
+
+// CollectorNames returns the name of every available collector.
+func CollectorNames() []string {
+	names := make([]string, len(collectorRegistry))
+	for i, collector := range collectorRegistry {
+		names[i] = collector.Name
+	}
+	return names
+}
@@ -0,0 +1,179 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrUnknownMeasurementKind = errors.New("unknown measurement kind")
+
+	targetSuccessDesc = prometheus.NewDesc(
+		"atlas_exporter_target_success",
+		"Whether the results of the requested measurement were fetched (1 = success)",
+		nil,
+		nil,
+	)
+	targetDurationDesc = prometheus.NewDesc(
+		"atlas_exporter_target_duration_seconds",
+		"Time taken to fetch the results of the requested measurement in seconds",
+		nil,
+		nil,
+	)
+)
+
+// measurementKind is a kind of measurement that can be exported on demand by
+// a TargetCollector. Fields are the metrics of the collector by name without
+// the atlas_exporter_<kind>_ prefix.
+type measurementKind struct {
+	factory func(client Client, logger logrus.FieldLogger, measurements []int) Fetcher
+	fields  map[string]*prometheus.Desc
+}
+
+var measurementKinds = map[string]measurementKind{
+	"sslcert": {
+		factory: SSLCertCollectorFactory,
+		fields: map[string]*prometheus.Desc{
+			"not_after":         sslCertNotAfterDesc,
+			"info":              sslCertInfoDesc,
+			"hostname_match":    sslCertHostnameMatchDesc,
+			"chain_length":      sslCertChainLengthDesc,
+			"handshake_seconds": sslCertHandshakeDesc,
+		},
+	},
+	"http": {
+		factory: HTTPCollectorFactory,
+		fields: map[string]*prometheus.Desc{
+			"status_code":                httpStatusCodeDesc,
+			"time_to_first_byte_seconds": httpTimeToFirstByteDesc,
+			"total_seconds":              httpTotalTimeDesc,
+			"header_bytes":               httpHeaderSizeDesc,
+			"body_bytes":                 httpBodySizeDesc,
+			"error":                      httpErrorDesc,
+		},
+	},
+	"ntp": {
+		factory: NTPCollectorFactory,
+		fields: map[string]*prometheus.Desc{
+			"offset_seconds": ntpOffsetDesc,
+			"rtt_seconds":    ntpRoundTripDesc,
+			"stratum":        ntpStratumDesc,
+			"poll_seconds":   ntpPollDesc,
+			"reference_info": ntpReferenceDesc,
+		},
+	},
+}
+
+// MeasurementKinds returns the measurement kinds supported by TargetCollector.
+func MeasurementKinds() []string {
+	kinds := make([]string, 0, len(measurementKinds))
+	for kind := range measurementKinds {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// MeasurementFields returns the fields of a measurement kind, or nil for an
+// unknown kind.
+func MeasurementFields(kind string) []string {
+	var fields []string
+	for field := range measurementKinds[kind].fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// targetCollector fetches the results of a single measurement during the scrape.
+type targetCollector struct {
+	ctx           context.Context
+	client        Client
+	kind          string
+	fetcher       Fetcher
+	fields        map[*prometheus.Desc]bool
+	measurementID int
+	timeout       time.Duration
+	logger        logrus.FieldLogger
+}
+
+// TargetCollector returns a collector fetching the latest results of a single
+// measurement of the given kind on every scrape, limited to fields (all of
+// them when empty). The fetch is cancelled with ctx or after the timeout of
+// the Exporter. Fetch errors, and measurements of another kind, are reported
+// by atlas_exporter_target_success.
+func (e *Exporter) TargetCollector(ctx context.Context, kind string, measurementID int, fields []string) (prometheus.Collector, error) {
+	measurement, ok := measurementKinds[kind]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMeasurementKind, kind)
+	}
+	for _, field := range fields {
+		if _, ok = measurement.fields[field]; !ok {
+			return nil, fmt.Errorf("unknown %s field %q", kind, field)
+		}
+	}
+	selected := map[*prometheus.Desc]bool{}
+	for name, desc := range measurement.fields {
+		if len(fields) == 0 || slices.Contains(fields, name) {
+			selected[desc] = true
+		}
+	}
+	return &targetCollector{
+		ctx:           ctx,
+		client:        e.client,
+		kind:          kind,
+		fetcher:       measurement.factory(e.client, e.logger, []int{measurementID}),
+		fields:        selected,
+		measurementID: measurementID,
+		timeout:       e.timeout,
+		logger:        e.logger,
+	}, nil
+}
+
+func (c *targetCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- targetSuccessDesc
+	ch <- targetDurationDesc
+	for desc := range c.fields {
+		ch <- desc
+	}
+}
+
+func (c *targetCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeout)
+	defer cancel()
+	start := time.Now()
+	metrics, err := c.fetch(ctx)
+	success := 1.0
+	if err != nil {
+		c.logger.WithError(err).Errorf("Failed to fetch results for measurement %d", c.measurementID)
+		success = 0
+	}
+	for _, metric := range metrics {
+		if c.fields[metric.Desc()] {
+			ch <- metric
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(targetDurationDesc, prometheus.GaugeValue, time.Since(start).Seconds())
+	ch <- prometheus.MustNewConstMetric(targetSuccessDesc, prometheus.GaugeValue, success)
+}
+
+// fetch fetches the results of the measurement after checking that it is of
+// the kind of the collector, as the results of another kind would decode into
+// empty metrics.
+func (c *targetCollector) fetch(ctx context.Context) ([]prometheus.Metric, error) {
+	measurement, err := c.client.GetMeasurement(ctx, c.measurementID)
+	if err != nil {
+		return nil, err
+	}
+	if measurement.Type != c.kind {
+		return nil, fmt.Errorf("measurement %d is of type %s, not %s", c.measurementID, measurement.Type, c.kind)
+	}
+	return c.fetcher.Fetch(ctx)
+}
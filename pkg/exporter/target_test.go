@@ -0,0 +1,74 @@
+package exporter
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/atlas"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestTargetCollector(t *testing.T) {
+	client := &fakeClient{
+		measurements: []atlas.Measurement{{ID: 1003, Type: "ntp"}, {ID: 1004, Type: "http"}},
+		ntp:          map[int][]atlas.NTPResult{},
+	}
+	client.ntp[1003] = decode[[]atlas.NTPResult](t, []map[string]any{
+		{
+			"msm_id": 1003, "prb_id": 1, "dst_name": "ntp.example.com", "stratum": 2, "poll": 64,
+			"result": []map[string]any{{"offset": 0.002, "rtt": 0.02}},
+		},
+	})
+	client.ntp[1004] = client.ntp[1003]
+	e, err := New(client, testLogger())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	collector, err := e.TargetCollector(t.Context(), "ntp", 1003, []string{"offset_seconds"})
+	if err != nil {
+		t.Fatalf("TargetCollector failed: %v", err)
+	}
+	if err = testutil.CollectAndCompare(collector, strings.NewReader(`
+# HELP atlas_exporter_ntp_offset_seconds Mean clock offset between the probe and the NTP server in seconds
+# TYPE atlas_exporter_ntp_offset_seconds gauge
+atlas_exporter_ntp_offset_seconds{measurement_id="1003",probe_id="1",target="ntp.example.com"} 0.002
+# HELP atlas_exporter_target_success Whether the results of the requested measurement were fetched (1 = success)
+# TYPE atlas_exporter_target_success gauge
+atlas_exporter_target_success 1
+`), "atlas_exporter_ntp_offset_seconds", "atlas_exporter_ntp_stratum", "atlas_exporter_target_success"); err != nil {
+		t.Errorf("unexpected target metrics: %v", err)
+	}
+
+	collector, err = e.TargetCollector(t.Context(), "ntp", 404, nil)
+	if err != nil {
+		t.Fatalf("TargetCollector failed: %v", err)
+	}
+	if err = testutil.CollectAndCompare(collector, strings.NewReader(`
+# HELP atlas_exporter_target_success Whether the results of the requested measurement were fetched (1 = success)
+# TYPE atlas_exporter_target_success gauge
+atlas_exporter_target_success 0
+`), "atlas_exporter_target_success"); err != nil {
+		t.Errorf("unexpected target metrics for a failed fetch: %v", err)
+	}
+
+	collector, err = e.TargetCollector(t.Context(), "ntp", 1004, nil)
+	if err != nil {
+		t.Fatalf("TargetCollector failed: %v", err)
+	}
+	if err = testutil.CollectAndCompare(collector, strings.NewReader(`
+# HELP atlas_exporter_target_success Whether the results of the requested measurement were fetched (1 = success)
+# TYPE atlas_exporter_target_success gauge
+atlas_exporter_target_success 0
+`), "atlas_exporter_ntp_offset_seconds", "atlas_exporter_target_success"); err != nil {
+		t.Errorf("unexpected target metrics for a measurement of another type: %v", err)
+	}
+
+	if _, err = e.TargetCollector(t.Context(), "dns", 1003, nil); !errors.Is(err, ErrUnknownMeasurementKind) {
+		t.Errorf("expected ErrUnknownMeasurementKind, got %v", err)
+	}
+	if _, err = e.TargetCollector(t.Context(), "ntp", 1003, []string{"bogus"}); err == nil {
+		t.Error("expected an error for an unknown field")
+	}
+}
@@ -0,0 +1,65 @@
+// Package samples flattens gathered metric families into samples the way the
+// Prometheus text format does, for the packages pushing metrics elsewhere.
+package samples
+
+import (
+	"math"
+	"strconv"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Label is a label added to the labels of the metric of a sample, the
+// quantile of a summary or the le of a histogram bucket.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Visit calls visit with every sample of family. Summaries and histograms are
+// split into their quantile, _bucket, _sum and _count samples like in the text
+// format, and a +Inf bucket is added to histograms without one.
+func Visit(family *dto.MetricFamily, visit func(metric *dto.Metric, name string, value float64, extra ...Label)) {
+	name := family.GetName()
+	for _, metric := range family.GetMetric() {
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			visit(metric, name, metric.GetCounter().GetValue())
+		case dto.MetricType_GAUGE:
+			visit(metric, name, metric.GetGauge().GetValue())
+		case dto.MetricType_SUMMARY:
+			summary := metric.GetSummary()
+			for _, quantile := range summary.GetQuantile() {
+				visit(metric, name, quantile.GetValue(), Label{"quantile", FormatFloat(quantile.GetQuantile())})
+			}
+			visit(metric, name+"_sum", summary.GetSampleSum())
+			visit(metric, name+"_count", float64(summary.GetSampleCount()))
+		case dto.MetricType_HISTOGRAM:
+			histogram := metric.GetHistogram()
+			infSeen := false
+			for _, bucket := range histogram.GetBucket() {
+				infSeen = infSeen || math.IsInf(bucket.GetUpperBound(), 1)
+				visit(metric, name+"_bucket", float64(bucket.GetCumulativeCount()), Label{"le", FormatFloat(bucket.GetUpperBound())})
+			}
+			if !infSeen {
+				visit(metric, name+"_bucket", float64(histogram.GetSampleCount()), Label{"le", "+Inf"})
+			}
+			visit(metric, name+"_sum", histogram.GetSampleSum())
+			visit(metric, name+"_count", float64(histogram.GetSampleCount()))
+		default:
+			visit(metric, name, metric.GetUntyped().GetValue())
+		}
+	}
+}
+
+// FormatFloat formats f like the text format does for label values.
+func FormatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
@@ -0,0 +1,39 @@
+package samples
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestVisit(t *testing.T) {
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_seconds", Help: "Test", Buckets: []float64{0.5}})
+	histogram.Observe(0.2)
+	histogram.Observe(2)
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(histogram)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+	var got []string
+	Visit(families[0], func(_ *dto.Metric, name string, value float64, extra ...Label) {
+		got = append(got, fmt.Sprintf("%s%v %v", name, extra, value))
+	})
+	expected := "test_seconds_bucket[{le 0.5}] 1,test_seconds_bucket[{le +Inf}] 2,test_seconds_sum[] 2.2,test_seconds_count[] 2"
+	if strings.Join(got, ",") != expected {
+		t.Errorf("expected samples %s, got %s", expected, strings.Join(got, ","))
+	}
+}
+
+func TestFormatFloat(t *testing.T) {
+	for value, expected := range map[float64]string{0.25: "0.25", 1e-9: "1e-09", math.Inf(1): "+Inf", math.Inf(-1): "-Inf"} {
+		if got := FormatFloat(value); got != expected {
+			t.Errorf("expected %v to be formatted as %s, got %s", value, expected, got)
+		}
+	}
+}
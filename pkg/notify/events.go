@@ -0,0 +1,294 @@
+package notify
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Kinds of events.
+const (
+	KindProbeDisconnected = "probe_disconnected"
+	KindProbeAbandoned    = "probe_abandoned"
+	KindCreditsLow        = "credits_low"
+	KindAPIKeyExpiring    = "api_key_expiring"
+)
+
+// Statuses of events.
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+)
+
+// Thresholds configure when events fire. A zero threshold disables the events
+// of its kind.
+type Thresholds struct {
+	// ProbeDisconnectedFor is how long a probe is disconnected before it fires.
+	ProbeDisconnectedFor time.Duration
+	// CreditsBelow is the balance below which the credits are low.
+	CreditsBelow float64
+	// KeyExpiresWithin is how long before the API key expires it fires.
+	KeyExpiresWithin time.Duration
+}
+
+// Event is a condition of an account detected in the exporter metrics.
+type Event struct {
+	// Kind is the kind of the event, e.g. probe_disconnected.
+	Kind string
+	// Alert is the name of the matching alert of the rules command, e.g.
+	// AtlasProbeDisconnected.
+	Alert string
+	// Status is firing or resolved.
+	Status string
+	// Labels are the labels of the metric the event was detected in.
+	Labels map[string]string
+	// Value is the value of the metric. It is 0 for resolved events.
+	Value       float64
+	Summary     string
+	Description string
+	StartsAt    time.Time
+	// EndsAt is zero while the event is firing.
+	EndsAt time.Time
+	// Fingerprint identifies the event across evaluations.
+	Fingerprint string
+}
+
+// Text returns the event as a plain text message.
+func (e Event) Text() string {
+	text := fmt.Sprintf("[%s] %s", strings.ToUpper(e.Status), e.Summary)
+	if e.Description != "" {
+		text += "\n" + e.Description
+	}
+	return text
+}
+
+// AlertLabels returns the labels of the event with the alertname label, as
+// Alertmanager labels alerts.
+func (e Event) AlertLabels() map[string]string {
+	labels := make(map[string]string, len(e.Labels)+1)
+	for name, value := range e.Labels {
+		labels[name] = value
+	}
+	labels["alertname"] = e.Alert
+	return labels
+}
+
+// Annotations returns the summary and description of the event.
+func (e Event) Annotations() map[string]string {
+	annotations := map[string]string{"summary": e.Summary}
+	if e.Description != "" {
+		annotations["description"] = e.Description
+	}
+	return annotations
+}
+
+// detector detects the events of a kind in the samples of a metric.
+type detector struct {
+	kind   string
+	alert  string
+	metric string
+	// identity are the labels identifying the subject of an event besides
+	// the account.
+	identity []string
+	// firing returns whether a sample fires at now, with the summary and
+	// description of the event.
+	firing func(t Thresholds, labels map[string]string, value float64, now time.Time) (bool, string, string)
+	// resolved returns the summary of the resolved event.
+	resolved func(t Thresholds, labels map[string]string) string
+}
+
+var detectors = []detector{
+	{
+		kind:     KindProbeDisconnected,
+		alert:    "AtlasProbeDisconnected",
+		metric:   "atlas_exporter_probe_last_connected",
+		identity: []string{"probe_id"},
+		firing: func(t Thresholds, labels map[string]string, value float64, now time.Time) (bool, string, string) {
+			lastConnected := time.Unix(int64(value), 0)
+			// Probes that never connected have no last connection.
+			if t.ProbeDisconnectedFor <= 0 || value <= 0 || now.Sub(lastConnected) <= t.ProbeDisconnectedFor {
+				return false, "", ""
+			}
+			return true,
+				fmt.Sprintf("%s%s is disconnected", probeName(labels), ofAccount(labels)),
+				fmt.Sprintf("It last connected at %s, %s ago.", formatTime(lastConnected), humanizeDuration(now.Sub(lastConnected)))
+		},
+		resolved: func(_ Thresholds, labels map[string]string) string {
+			return fmt.Sprintf("%s%s reconnected", probeName(labels), ofAccount(labels))
+		},
+	},
+	{
+		kind:     KindProbeAbandoned,
+		alert:    "AtlasProbeAbandoned",
+		metric:   "atlas_exporter_probe_status",
+		identity: []string{"probe_id"},
+		firing: func(_ Thresholds, labels map[string]string, _ float64, _ time.Time) (bool, string, string) {
+			if labels["status"] != "Abandoned" {
+				return false, "", ""
+			}
+			return true,
+				fmt.Sprintf("%s%s was abandoned", probeName(labels), ofAccount(labels)),
+				"RIPE Atlas marks probes as abandoned after they have been disconnected for a long time."
+		},
+		resolved: func(_ Thresholds, labels map[string]string) string {
+			return fmt.Sprintf("%s%s is no longer abandoned", probeName(labels), ofAccount(labels))
+		},
+	},
+	{
+		kind:   KindCreditsLow,
+		alert:  "AtlasCreditsLow",
+		metric: "atlas_exporter_credits",
+		firing: func(t Thresholds, labels map[string]string, value float64, _ time.Time) (bool, string, string) {
+			if t.CreditsBelow <= 0 || value >= t.CreditsBelow {
+				return false, "", ""
+			}
+			return true,
+				fmt.Sprintf("Credits%s are low", ofAccount(labels)),
+				fmt.Sprintf("%s credits are left, below the threshold of %s.", formatNumber(value), formatNumber(t.CreditsBelow))
+		},
+		resolved: func(t Thresholds, labels map[string]string) string {
+			return fmt.Sprintf("Credits%s are back above %s", ofAccount(labels), formatNumber(t.CreditsBelow))
+		},
+	},
+	{
+		kind:   KindAPIKeyExpiring,
+		alert:  "AtlasAPIKeyExpiring",
+		metric: "atlas_exporter_api_key_expiry_timestamp_seconds",
+		firing: func(t Thresholds, labels map[string]string, value float64, now time.Time) (bool, string, string) {
+			expiry := time.Unix(int64(value), 0)
+			if t.KeyExpiresWithin <= 0 || expiry.Sub(now) >= t.KeyExpiresWithin {
+				return false, "", ""
+			}
+			description := fmt.Sprintf("It expires at %s, in %s.", formatTime(expiry), humanizeDuration(expiry.Sub(now)))
+			if !expiry.After(now) {
+				description = fmt.Sprintf("It expired at %s.", formatTime(expiry))
+			}
+			return true, fmt.Sprintf("API key%s is expiring", ofAccount(labels)), description
+		},
+		resolved: func(_ Thresholds, labels map[string]string) string {
+			return fmt.Sprintf("API key%s no longer expires soon", ofAccount(labels))
+		},
+	},
+}
+
+// detectorOf returns the detector of kind.
+func detectorOf(kind string) (detector, bool) {
+	for _, d := range detectors {
+		if d.kind == kind {
+			return d, true
+		}
+	}
+	return detector{}, false
+}
+
+// Kinds returns the kinds of events.
+func Kinds() []string {
+	kinds := make([]string, len(detectors))
+	for i, d := range detectors {
+		kinds[i] = d.kind
+	}
+	return kinds
+}
+
+// detect returns the firing events in the metric families at now by
+// fingerprint, and the kinds whose metric was gathered by account, as
+// returned by source.
+func detect(families []*dto.MetricFamily, t Thresholds, now time.Time) (map[string]Event, map[string]bool) {
+	events := map[string]Event{}
+	gathered := map[string]bool{}
+	for _, family := range families {
+		for _, d := range detectors {
+			if family.GetName() != d.metric {
+				continue
+			}
+			for _, metric := range family.GetMetric() {
+				labels := make(map[string]string, len(metric.GetLabel()))
+				for _, pair := range metric.GetLabel() {
+					labels[pair.GetName()] = pair.GetValue()
+				}
+				gathered[source(d.kind, labels)] = true
+				value := metric.GetGauge().GetValue()
+				firing, summary, description := d.firing(t, labels, value, now)
+				if !firing {
+					continue
+				}
+				event := Event{
+					Kind:        d.kind,
+					Alert:       d.alert,
+					Status:      StatusFiring,
+					Labels:      labels,
+					Value:       value,
+					Summary:     summary,
+					Description: description,
+					Fingerprint: fingerprint(d, labels),
+				}
+				events[event.Fingerprint] = event
+			}
+		}
+	}
+	return events, gathered
+}
+
+// source identifies the metric of kind for the account in labels.
+func source(kind string, labels map[string]string) string {
+	return kind + "/" + labels["account"]
+}
+
+// fingerprint returns the hash of the kind and identity labels of an event,
+// so changes to other labels such as the description of a probe do not
+// create a new event.
+func fingerprint(d detector, labels map[string]string) string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(d.kind))
+	for _, name := range append([]string{"account"}, d.identity...) {
+		_, _ = h.Write([]byte{0xff})
+		_, _ = h.Write([]byte(name + "=" + labels[name]))
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// probeName returns the probe ID of labels with its description.
+func probeName(labels map[string]string) string {
+	name := "Probe " + labels["probe_id"]
+	if description := labels["description"]; description != "" {
+		name += " (" + description + ")"
+	}
+	return name
+}
+
+// ofAccount names the account of labels, if any.
+func ofAccount(labels map[string]string) string {
+	if account := labels["account"]; account != "" {
+		return " of account " + account
+	}
+	return ""
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04 MST")
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// humanizeDuration formats d in days, hours and minutes.
+func humanizeDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	days, hours, minutes := int(d/(24*time.Hour)), int(d%(24*time.Hour)/time.Hour), int(d%time.Hour/time.Minute)
+	var parts []string
+	if days > 0 {
+		parts = append(parts, strconv.Itoa(days)+"d")
+	}
+	if hours > 0 {
+		parts = append(parts, strconv.Itoa(hours)+"h")
+	}
+	if minutes > 0 || len(parts) == 0 {
+		parts = append(parts, strconv.Itoa(minutes)+"m")
+	}
+	return strings.Join(parts, " ")
+}
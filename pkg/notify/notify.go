@@ -0,0 +1,242 @@
+// Package notify detects events in the metrics of the exporter, such as
+// disconnected probes or low credits, and sends them to webhooks. It is meant
+// for setups without Alertmanager.
+//
+// Events are deduplicated per webhook: a firing event is sent once, then
+// again every repeat interval, and a resolution message is sent when it stops
+// firing. What was sent is kept in the state store so restarts do not send
+// events again.
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/state"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// stateNamespace holds the events sent to every webhook, by webhook name.
+const stateNamespace = "notifications"
+
+// sentEvent is an event sent to a webhook, kept until its resolution is sent.
+type sentEvent struct {
+	Kind     string            `json:"kind"`
+	Labels   map[string]string `json:"labels"`
+	StartsAt time.Time         `json:"starts_at"`
+	LastSent time.Time         `json:"last_sent"`
+}
+
+// Option is a functional option for configuring a Notifier.
+type Option func(*Notifier) error
+
+// Notifier evaluates the events in the metrics of a prometheus.Gatherer and
+// sends them to webhooks.
+type Notifier struct {
+	gatherer   prometheus.Gatherer
+	store      state.Store
+	logger     logrus.FieldLogger
+	thresholds Thresholds
+	webhooks   []*Webhook
+	sent       *prometheus.CounterVec
+	failed     *prometheus.CounterVec
+}
+
+// New creates a Notifier for the metrics of g, keeping the sent events in
+// store.
+func New(g prometheus.Gatherer, store state.Store, logger logrus.FieldLogger, opts ...Option) (*Notifier, error) {
+	if store == nil {
+		return nil, errors.New("state store must not be nil")
+	}
+	n := &Notifier{
+		gatherer: g,
+		store:    store,
+		logger:   logger,
+		sent: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "atlas_exporter_notifications_sent_total",
+			Help: "Number of notifications sent to each webhook by event status",
+		}, []string{"webhook", "status"}),
+		failed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "atlas_exporter_notifications_failed_total",
+			Help: "Number of notifications to each webhook that failed",
+		}, []string{"webhook"}),
+	}
+	for _, opt := range opts {
+		if err := opt(n); err != nil {
+			return nil, fmt.Errorf("failed to apply option: %w", err)
+		}
+	}
+	return n, nil
+}
+
+// WithThresholds sets when events fire. Without thresholds only abandoned
+// probes are detected.
+func WithThresholds(t Thresholds) Option {
+	return func(n *Notifier) error {
+		n.thresholds = t
+		return nil
+	}
+}
+
+// WithWebhook sends the events to w. Webhook names must be unique.
+func WithWebhook(w *Webhook) Option {
+	return func(n *Notifier) error {
+		if w == nil {
+			return errors.New("webhook must not be nil")
+		}
+		for _, existing := range n.webhooks {
+			if existing.name == w.name {
+				return fmt.Errorf("duplicate webhook name %q", w.name)
+			}
+		}
+		n.webhooks = append(n.webhooks, w)
+		return nil
+	}
+}
+
+// Run evaluates the events every interval until ctx is cancelled.
+func (n *Notifier) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := n.Evaluate(ctx, time.Now()); err != nil {
+			n.logger.WithError(err).Error("Failed to send notifications")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Evaluate detects the events at now and sends the new, repeated and
+// resolved ones to every webhook. Metrics gathered despite an error are still
+// evaluated. Events are only resolved when the metric they were detected in
+// is still gathered for their account, so a collector that has not refreshed
+// yet does not resolve them. Failed sends are retried on the next evaluation.
+func (n *Notifier) Evaluate(ctx context.Context, now time.Time) error {
+	families, gatherErr := n.gatherer.Gather()
+	var errs []error
+	if gatherErr != nil {
+		errs = append(errs, fmt.Errorf("failed to gather metrics: %w", gatherErr))
+	}
+	firing, gathered := detect(families, n.thresholds, now)
+	for _, w := range n.webhooks {
+		if err := n.notify(ctx, w, firing, gathered, now); err != nil {
+			errs = append(errs, fmt.Errorf("webhook %s: %w", w.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// notify sends the events of w which changed since the last evaluation.
+func (n *Notifier) notify(ctx context.Context, w *Webhook, firing map[string]Event, gathered map[string]bool, now time.Time) error {
+	sent, err := n.load(w)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	send := func(e Event) bool {
+		if sendErr := w.Send(ctx, e); sendErr != nil {
+			n.failed.WithLabelValues(w.name).Inc()
+			errs = append(errs, fmt.Errorf("failed to send %s event %s: %w", e.Kind, e.Fingerprint, sendErr))
+			return false
+		}
+		n.sent.WithLabelValues(w.name, e.Status).Inc()
+		return true
+	}
+	for fp, e := range firing {
+		if !w.accepts(e.Kind) {
+			continue
+		}
+		previous, ok := sent[fp]
+		e.StartsAt = now
+		if ok {
+			e.StartsAt = previous.StartsAt
+			if w.repeatInterval <= 0 || now.Sub(previous.LastSent) < w.repeatInterval {
+				continue
+			}
+		}
+		if send(e) {
+			sent[fp] = &sentEvent{Kind: e.Kind, Labels: e.Labels, StartsAt: e.StartsAt, LastSent: now}
+		}
+	}
+	for fp, previous := range sent {
+		if _, ok := firing[fp]; ok && w.accepts(previous.Kind) {
+			continue
+		}
+		d, known := detectorOf(previous.Kind)
+		switch {
+		case !known || !w.accepts(previous.Kind):
+			// Events the webhook no longer sends are forgotten.
+		case !gathered[source(previous.Kind, previous.Labels)]:
+			continue
+		case w.sendResolved && !send(Event{
+			Kind:        previous.Kind,
+			Alert:       d.alert,
+			Status:      StatusResolved,
+			Labels:      previous.Labels,
+			Summary:     d.resolved(n.thresholds, previous.Labels),
+			StartsAt:    previous.StartsAt,
+			EndsAt:      now,
+			Fingerprint: fp,
+		}):
+			continue
+		}
+		delete(sent, fp)
+	}
+	if err = n.save(w, sent); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// load returns the events sent to w by fingerprint.
+func (n *Notifier) load(w *Webhook) (map[string]*sentEvent, error) {
+	sent := map[string]*sentEvent{}
+	value, err := n.store.Get(stateNamespace, w.name)
+	if errors.Is(err, state.ErrNotFound) {
+		return sent, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sent events: %w", err)
+	}
+	if err = json.Unmarshal(value, &sent); err != nil {
+		n.logger.WithError(err).WithField("webhook", w.name).Warn("Ignoring invalid sent events in the state store")
+		return map[string]*sentEvent{}, nil
+	}
+	return sent, nil
+}
+
+// save records the events sent to w.
+func (n *Notifier) save(w *Webhook, sent map[string]*sentEvent) error {
+	if len(sent) == 0 {
+		if err := n.store.Delete(stateNamespace, w.name); err != nil {
+			return fmt.Errorf("failed to record sent events: %w", err)
+		}
+		return nil
+	}
+	value, err := json.Marshal(sent)
+	if err != nil {
+		return fmt.Errorf("failed to encode sent events: %w", err)
+	}
+	if err = n.store.Put(stateNamespace, w.name, value); err != nil {
+		return fmt.Errorf("failed to record sent events: %w", err)
+	}
+	return nil
+}
+
+func (n *Notifier) Describe(ch chan<- *prometheus.Desc) {
+	n.sent.Describe(ch)
+	n.failed.Describe(ch)
+}
+
+func (n *Notifier) Collect(ch chan<- prometheus.Metric) {
+	n.sent.Collect(ch)
+	n.failed.Collect(ch)
+}
@@ -0,0 +1,339 @@
+package notify
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/state"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+)
+
+var testNow = time.Date(2026, time.March, 2, 12, 0, 0, 0, time.UTC)
+
+var testThresholds = Thresholds{
+	ProbeDisconnectedFor: 30 * time.Minute,
+	CreditsBelow:         1000,
+	KeyExpiresWithin:     7 * 24 * time.Hour,
+}
+
+func testLogger() logrus.FieldLogger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+// testServer records the bodies of the requests it receives and responds
+// with status.
+type testServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   []string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	s := &testServer{status: http.StatusOK}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.requests = append(s.requests, r)
+		s.bodies = append(s.bodies, string(body))
+		w.WriteHeader(s.status)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// texts returns the text of the slack payloads received since the last call,
+// sorted.
+func (s *testServer) texts(t *testing.T) []string {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	texts := make([]string, 0, len(s.bodies))
+	for _, body := range s.bodies {
+		var payload struct {
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal([]byte(body), &payload); err != nil {
+			t.Fatalf("Invalid payload %s: %v", body, err)
+		}
+		texts = append(texts, payload.Text)
+	}
+	s.bodies = nil
+	sort.Strings(texts)
+	return texts
+}
+
+// testMetrics are the exporter metrics evaluated by the notifier.
+type testMetrics struct {
+	registry      *prometheus.Registry
+	credits       *prometheus.GaugeVec
+	lastConnected *prometheus.GaugeVec
+	status        *prometheus.GaugeVec
+	keyExpiry     *prometheus.GaugeVec
+}
+
+func newTestMetrics() *testMetrics {
+	m := &testMetrics{
+		registry:      prometheus.NewRegistry(),
+		credits:       prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "atlas_exporter_credits", Help: "Credits"}, []string{"account"}),
+		lastConnected: prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "atlas_exporter_probe_last_connected", Help: "Last connected"}, []string{"account", "probe_id", "country_code", "description"}),
+		status:        prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "atlas_exporter_probe_status", Help: "Status"}, []string{"account", "probe_id", "status"}),
+		keyExpiry:     prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "atlas_exporter_api_key_expiry_timestamp_seconds", Help: "Expiry"}, []string{"account"}),
+	}
+	m.registry.MustRegister(m.credits, m.lastConnected, m.status, m.keyExpiry)
+	return m
+}
+
+func TestNotifier(t *testing.T) {
+	server := newTestServer(t)
+	metrics := newTestMetrics()
+	store := state.NewMemory(state.Retention{})
+	newNotifier := func() *Notifier {
+		webhook, err := NewWebhook("chat", server.URL, WithPreset("slack"), WithRepeatInterval(time.Hour))
+		if err != nil {
+			t.Fatalf("NewWebhook failed: %v", err)
+		}
+		notifier, err := New(metrics.registry, store, testLogger(), WithThresholds(testThresholds), WithWebhook(webhook))
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		return notifier
+	}
+	notifier := newNotifier()
+	firing := []string{
+		"[FIRING] API key of account a is expiring\nIt expires at 2026-03-05 12:00 UTC, in 3d.",
+		"[FIRING] Credits of account a are low\n500 credits are left, below the threshold of 1000.",
+		"[FIRING] Probe 1 (home) of account a is disconnected\nIt last connected at 2026-03-02 10:00 UTC, 2h ago.",
+		"[FIRING] Probe 3 of account a was abandoned\nRIPE Atlas marks probes as abandoned after they have been disconnected for a long time.",
+	}
+
+	tests := []struct {
+		name     string
+		at       time.Time
+		update   func()
+		expected []string
+	}{
+		{
+			name: "firing",
+			at:   testNow,
+			update: func() {
+				metrics.credits.WithLabelValues("a").Set(500)
+				metrics.lastConnected.WithLabelValues("a", "1", "NL", "home").Set(float64(testNow.Add(-2 * time.Hour).Unix()))
+				// Probes that never connected are not disconnected.
+				metrics.lastConnected.WithLabelValues("a", "2", "NL", "").Set(0)
+				metrics.status.WithLabelValues("a", "2", "Never Connected").Set(1)
+				metrics.status.WithLabelValues("a", "3", "Abandoned").Set(1)
+				metrics.keyExpiry.WithLabelValues("a").Set(float64(testNow.Add(3 * 24 * time.Hour).Unix()))
+			},
+			expected: firing,
+		},
+		{
+			name:     "deduplicated",
+			at:       testNow.Add(30 * time.Minute),
+			update:   func() {},
+			expected: []string{},
+		},
+		{
+			name:     "deduplicated after restart",
+			at:       testNow.Add(45 * time.Minute),
+			update:   func() { notifier = newNotifier() },
+			expected: []string{},
+		},
+		{
+			name:   "repeated",
+			at:     testNow.Add(time.Hour),
+			update: func() {},
+			expected: []string{
+				"[FIRING] API key of account a is expiring\nIt expires at 2026-03-05 12:00 UTC, in 2d 23h.",
+				"[FIRING] Credits of account a are low\n500 credits are left, below the threshold of 1000.",
+				"[FIRING] Probe 1 (home) of account a is disconnected\nIt last connected at 2026-03-02 10:00 UTC, 3h ago.",
+				firing[3],
+			},
+		},
+		{
+			name: "resolved",
+			at:   testNow.Add(90 * time.Minute),
+			update: func() {
+				metrics.credits.WithLabelValues("a").Set(5000)
+				metrics.lastConnected.WithLabelValues("a", "1", "NL", "home").Set(float64(testNow.Add(90 * time.Minute).Unix()))
+			},
+			expected: []string{
+				"[RESOLVED] Credits of account a are back above 1000",
+				"[RESOLVED] Probe 1 (home) of account a reconnected",
+			},
+		},
+		{
+			name:     "firing again",
+			at:       testNow.Add(100 * time.Minute),
+			update:   func() { metrics.credits.WithLabelValues("a").Set(10) },
+			expected: []string{"[FIRING] Credits of account a are low\n10 credits are left, below the threshold of 1000."},
+		},
+		{
+			name:     "metric not gathered",
+			at:       testNow.Add(110 * time.Minute),
+			update:   func() { metrics.registry.Unregister(metrics.credits) },
+			expected: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.update()
+			if err := notifier.Evaluate(t.Context(), tt.at); err != nil {
+				t.Fatalf("Evaluate failed: %v", err)
+			}
+			texts := server.texts(t)
+			if strings.Join(texts, "\n---\n") != strings.Join(tt.expected, "\n---\n") {
+				t.Errorf("expected messages:\n%s\ngot:\n%s", strings.Join(tt.expected, "\n---\n"), strings.Join(texts, "\n---\n"))
+			}
+		})
+	}
+}
+
+func TestNotifierRetriesFailedSends(t *testing.T) {
+	server := newTestServer(t)
+	server.status = http.StatusBadGateway
+	metrics := newTestMetrics()
+	metrics.credits.WithLabelValues("a").Set(500)
+	webhook, err := NewWebhook("chat", server.URL, WithPreset("slack"), WithKinds(KindCreditsLow))
+	if err != nil {
+		t.Fatalf("NewWebhook failed: %v", err)
+	}
+	notifier, err := New(metrics.registry, state.NewMemory(state.Retention{}), testLogger(), WithThresholds(testThresholds), WithWebhook(webhook))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	if err = notifier.Evaluate(t.Context(), testNow); err == nil || !strings.Contains(err.Error(), "webhook returned status 502") {
+		t.Errorf("expected the send to fail, got %v", err)
+	}
+	server.mu.Lock()
+	server.status = http.StatusOK
+	server.mu.Unlock()
+	if err = notifier.Evaluate(t.Context(), testNow.Add(time.Minute)); err != nil {
+		t.Fatalf("Evaluate failed: %v", err)
+	}
+	if texts := server.texts(t); len(texts) != 2 || texts[0] != texts[1] {
+		t.Errorf("expected the event to be sent again, got %q", texts)
+	}
+	expected := `
+# HELP atlas_exporter_notifications_failed_total Number of notifications to each webhook that failed
+# TYPE atlas_exporter_notifications_failed_total counter
+atlas_exporter_notifications_failed_total{webhook="chat"} 1
+# HELP atlas_exporter_notifications_sent_total Number of notifications sent to each webhook by event status
+# TYPE atlas_exporter_notifications_sent_total counter
+atlas_exporter_notifications_sent_total{status="firing",webhook="chat"} 1
+`
+	if err = testutil.CollectAndCompare(notifier, strings.NewReader(expected)); err != nil {
+		t.Errorf("unexpected metrics: %v", err)
+	}
+}
+
+func TestWebhookPayloads(t *testing.T) {
+	event := Event{
+		Kind:        KindCreditsLow,
+		Alert:       "AtlasCreditsLow",
+		Status:      StatusFiring,
+		Labels:      map[string]string{"account": "a"},
+		Value:       500,
+		Summary:     "Credits of account a are low",
+		Description: `500 credits are left, "below" the threshold.`,
+		StartsAt:    testNow,
+		Fingerprint: "0123456789abcdef",
+	}
+	text := "[FIRING] Credits of account a are low\n500 credits are left, \"below\" the threshold."
+	tests := []struct {
+		name     string
+		opts     []WebhookOption
+		expected map[string]any
+	}{
+		{"slack", []WebhookOption{WithPreset("slack")}, map[string]any{"text": text}},
+		{"discord", []WebhookOption{WithPreset("discord")}, map[string]any{"content": text}},
+		{"matrix", []WebhookOption{WithPreset("matrix")}, map[string]any{"msgtype": "m.text", "body": text}},
+		{
+			"alertmanager",
+			nil,
+			map[string]any{
+				"version": "4", "groupKey": "0123456789abcdef", "truncatedAlerts": 0.0, "status": "firing", "receiver": "ops",
+				"groupLabels":       map[string]any{"alertname": "AtlasCreditsLow"},
+				"commonLabels":      map[string]any{"alertname": "AtlasCreditsLow", "account": "a"},
+				"commonAnnotations": map[string]any{"summary": event.Summary, "description": event.Description},
+				"externalURL":       "",
+				"alerts": []any{map[string]any{
+					"status":       "firing",
+					"labels":       map[string]any{"alertname": "AtlasCreditsLow", "account": "a"},
+					"annotations":  map[string]any{"summary": event.Summary, "description": event.Description},
+					"startsAt":     "2026-03-02T12:00:00Z",
+					"endsAt":       "0001-01-01T00:00:00Z",
+					"generatorURL": "",
+					"fingerprint":  "0123456789abcdef",
+				}},
+			},
+		},
+		{
+			"template",
+			[]WebhookOption{WithTemplate(`{"event": {{ json (upper .Kind) }}, "value": {{ .Value }}, "to": {{ json .Receiver }}}`)},
+			map[string]any{"event": "CREDITS_LOW", "value": 500.0, "to": "ops"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t)
+			webhook, err := NewWebhook("ops", server.URL+"/hook", append(tt.opts, WithHeaders(map[string]string{"Authorization": "Bearer secret"}))...)
+			if err != nil {
+				t.Fatalf("NewWebhook failed: %v", err)
+			}
+			if err = webhook.Send(t.Context(), event); err != nil {
+				t.Fatalf("Send failed: %v", err)
+			}
+			req := server.requests[0]
+			if req.URL.Path != "/hook" || req.Header.Get("Authorization") != "Bearer secret" || req.Header.Get("Content-Type") != "application/json" {
+				t.Errorf("unexpected request %s %v", req.URL, req.Header)
+			}
+			var payload map[string]any
+			if err = json.Unmarshal([]byte(server.bodies[0]), &payload); err != nil {
+				t.Fatalf("Invalid payload %s: %v", server.bodies[0], err)
+			}
+			expected, _ := json.Marshal(tt.expected)
+			got, _ := json.Marshal(payload)
+			if string(expected) != string(got) {
+				t.Errorf("expected payload %s, got %s", expected, got)
+			}
+		})
+	}
+}
+
+func TestNewWebhookErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		opts     []WebhookOption
+		expected string
+	}{
+		{"invalid URL", "ftp://example.com", nil, `invalid webhook URL "ftp://example.com"`},
+		{"unknown preset", "https://example.com", []WebhookOption{WithPreset("teams")}, `unknown preset "teams", must be one of alertmanager, discord, matrix, slack`},
+		{"invalid template", "https://example.com", []WebhookOption{WithTemplate("{{ .Text")}, "failed to parse template"},
+		{"unknown kind", "https://example.com", []WebhookOption{WithKinds("probe_lost")}, `unknown event kind "probe_lost"`},
+		{"negative repeat", "https://example.com", []WebhookOption{WithRepeatInterval(-time.Minute)}, "repeat interval must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewWebhook("ops", tt.url, tt.opts...); err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("expected error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// presets are the payload templates of common chat services and of the
+// Alertmanager webhook receiver, by name.
+var presets = map[string]string{
+	"slack":   `{"text": {{ json .Text }}}`,
+	"discord": `{"content": {{ json .Text }}}`,
+	"matrix":  `{"msgtype": "m.text", "body": {{ json .Text }}}`,
+	"alertmanager": `{"version": "4", "groupKey": {{ json .Fingerprint }}, "truncatedAlerts": 0, "status": {{ json .Status }},` +
+		` "receiver": {{ json .Receiver }}, "groupLabels": {"alertname": {{ json .Alert }}}, "commonLabels": {{ json .AlertLabels }},` +
+		` "commonAnnotations": {{ json .Annotations }}, "externalURL": "", "alerts": [{"status": {{ json .Status }},` +
+		` "labels": {{ json .AlertLabels }}, "annotations": {{ json .Annotations }}, "startsAt": {{ json .StartsAt }},` +
+		` "endsAt": {{ json .EndsAt }}, "generatorURL": "", "fingerprint": {{ json .Fingerprint }}}]}`,
+}
+
+// Presets returns the names of the payload presets.
+func Presets() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// templateFuncs are the functions available to payload templates.
+var templateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		encoded, err := json.Marshal(v)
+		return string(encoded), err
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// ParseTemplate parses a payload template. Templates are executed with a
+// Message and can use the json, upper and lower functions.
+func ParseTemplate(text string) (*template.Template, error) {
+	return template.New("payload").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+}
+
+// Message is the data of payload templates.
+type Message struct {
+	Event
+	// Receiver is the name of the webhook.
+	Receiver string
+}
+
+// WebhookOption is a functional option for configuring a Webhook.
+type WebhookOption func(*Webhook) error
+
+// Webhook posts events to a URL with a payload rendered from a template.
+type Webhook struct {
+	name           string
+	url            string
+	template       *template.Template
+	headers        map[string]string
+	repeatInterval time.Duration
+	sendResolved   bool
+	kinds          map[string]bool
+	httpClient     *http.Client
+}
+
+// NewWebhook creates a webhook posting to webhookURL. It sends every kind of
+// event with the alertmanager preset and resolution messages by default.
+func NewWebhook(name, webhookURL string, opts ...WebhookOption) (*Webhook, error) {
+	if name == "" {
+		return nil, errors.New("webhook name cannot be empty")
+	}
+	parsed, err := url.Parse(webhookURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook URL: %w", err)
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid webhook URL %q: must be an http or https URL", webhookURL)
+	}
+	w := &Webhook{
+		name:         name,
+		url:          webhookURL,
+		sendResolved: true,
+		httpClient:   &http.Client{Timeout: 10 * time.Second},
+	}
+	if err = WithPreset("alertmanager")(w); err != nil {
+		return nil, err
+	}
+	for _, opt := range opts {
+		if err = opt(w); err != nil {
+			return nil, fmt.Errorf("failed to apply option: %w", err)
+		}
+	}
+	return w, nil
+}
+
+// WithPreset renders the payload with a preset: slack, discord, matrix or
+// alertmanager.
+func WithPreset(preset string) WebhookOption {
+	return func(w *Webhook) error {
+		text, ok := presets[preset]
+		if !ok {
+			return fmt.Errorf("unknown preset %q, must be one of %s", preset, strings.Join(Presets(), ", "))
+		}
+		tmpl, err := ParseTemplate(text)
+		if err != nil {
+			return fmt.Errorf("failed to parse preset %s: %w", preset, err)
+		}
+		w.template = tmpl
+		return nil
+	}
+}
+
+// WithTemplate renders the payload with a custom template. See ParseTemplate.
+func WithTemplate(text string) WebhookOption {
+	return func(w *Webhook) error {
+		tmpl, err := ParseTemplate(text)
+		if err != nil {
+			return fmt.Errorf("failed to parse template: %w", err)
+		}
+		w.template = tmpl
+		return nil
+	}
+}
+
+// WithHeaders adds headers to every request, e.g. Authorization. The
+// Content-Type is application/json unless set.
+func WithHeaders(headers map[string]string) WebhookOption {
+	return func(w *Webhook) error {
+		w.headers = headers
+		return nil
+	}
+}
+
+// WithRepeatInterval sends firing events again every interval until they are
+// resolved. Zero sends them once.
+func WithRepeatInterval(interval time.Duration) WebhookOption {
+	return func(w *Webhook) error {
+		if interval < 0 {
+			return errors.New("repeat interval must not be negative")
+		}
+		w.repeatInterval = interval
+		return nil
+	}
+}
+
+// WithSendResolved sets whether resolved events are sent.
+func WithSendResolved(sendResolved bool) WebhookOption {
+	return func(w *Webhook) error {
+		w.sendResolved = sendResolved
+		return nil
+	}
+}
+
+// WithKinds only sends events of kinds. No kinds sends every kind.
+func WithKinds(kinds ...string) WebhookOption {
+	return func(w *Webhook) error {
+		if len(kinds) == 0 {
+			w.kinds = nil
+			return nil
+		}
+		w.kinds = make(map[string]bool, len(kinds))
+		for _, kind := range kinds {
+			if _, ok := detectorOf(kind); !ok {
+				return fmt.Errorf("unknown event kind %q, must be one of %s", kind, strings.Join(Kinds(), ", "))
+			}
+			w.kinds[kind] = true
+		}
+		return nil
+	}
+}
+
+// WithTimeout sets the timeout of a single request.
+func WithTimeout(timeout time.Duration) WebhookOption {
+	return func(w *Webhook) error {
+		if timeout <= 0 {
+			return errors.New("timeout must be positive")
+		}
+		w.httpClient.Timeout = timeout
+		return nil
+	}
+}
+
+// Name identifies the webhook in logs, metrics and the Receiver of messages.
+func (w *Webhook) Name() string {
+	return w.name
+}
+
+// accepts returns whether the webhook sends events of kind.
+func (w *Webhook) accepts(kind string) bool {
+	return w.kinds == nil || w.kinds[kind]
+}
+
+// Send posts the rendered payload of e.
+func (w *Webhook) Send(ctx context.Context, e Event) error {
+	var body bytes.Buffer
+	if err := w.template.Execute(&body, Message{Event: e, Receiver: w.name}); err != nil {
+		return fmt.Errorf("failed to render payload: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, &body)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range w.headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook returned status %d: %s", resp.StatusCode, bytes.TrimSpace(message))
+	}
+	return nil
+}
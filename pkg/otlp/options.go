@@ -0,0 +1,52 @@
+package otlp
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Option is a functional option for configuring the OTLP exporter.
+type Option func(*Exporter) error
+
+// WithHeaders sets headers sent with every export, e.g. for authentication.
+func WithHeaders(headers map[string]string) Option {
+	return func(e *Exporter) error {
+		e.headers = headers
+		return nil
+	}
+}
+
+// WithTimeout sets the timeout of a single export, including retries.
+func WithTimeout(timeout time.Duration) Option {
+	return func(e *Exporter) error {
+		if timeout <= 0 {
+			return errors.New("timeout must be positive")
+		}
+		e.timeout = timeout
+		return nil
+	}
+}
+
+// WithResourceAttributes adds attributes to the resource of every export.
+func WithResourceAttributes(attributes map[string]string) Option {
+	return func(e *Exporter) error {
+		for key, value := range attributes {
+			if key == accountAttribute {
+				return errors.New(accountAttribute + " resource attribute is set from the account label")
+			}
+			e.attributes = append(e.attributes, attribute.String(key, value))
+		}
+		return nil
+	}
+}
+
+// WithLogger sets the logger used to report export failures.
+func WithLogger(logger logrus.FieldLogger) Option {
+	return func(e *Exporter) error {
+		e.logger = logger
+		return nil
+	}
+}
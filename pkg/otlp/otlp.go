@@ -0,0 +1,222 @@
+// Package otlp sends the metrics of a prometheus.Gatherer to an OpenTelemetry
+// collector with OTLP over gRPC or HTTP.
+//
+// Counters, gauges, histograms and summaries are converted to the matching
+// OpenTelemetry data points. The account label of a metric becomes the
+// atlas.account resource attribute, so every account is exported as its own
+// resource.
+package otlp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/version"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/sirupsen/logrus"
+	promBridge "go.opentelemetry.io/contrib/bridges/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+	"google.golang.org/protobuf/proto"
+)
+
+// Supported OTLP transport protocols.
+const (
+	ProtocolGRPC = "grpc"
+	ProtocolHTTP = "http/protobuf"
+)
+
+const (
+	// accountLabel is the metric label moved to the accountAttribute resource
+	// attribute.
+	accountLabel     = "account"
+	accountAttribute = "atlas.account"
+	serviceName      = "atlas_exporter"
+	// defaultHTTPPath is the path of the OTLP/HTTP metrics endpoint used when
+	// the endpoint URL has none.
+	defaultHTTPPath = "/v1/metrics"
+)
+
+// Exporter gathers metrics and sends them to an OTLP endpoint.
+type Exporter struct {
+	headers    map[string]string
+	timeout    time.Duration
+	attributes []attribute.KeyValue
+	logger     logrus.FieldLogger
+	exporter   sdkmetric.Exporter
+
+	exports       prometheus.Counter
+	failedExports prometheus.Counter
+}
+
+// New creates an Exporter sending to the endpoint URL with protocol. Plain
+// http endpoints are sent to without TLS.
+func New(ctx context.Context, protocol, endpoint string, opts ...Option) (*Exporter, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OTLP endpoint: %w", err)
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q: must be an http or https URL", endpoint)
+	}
+	e := &Exporter{
+		timeout: 10 * time.Second,
+		logger:  logrus.StandardLogger(),
+		exports: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "atlas_exporter_otlp_exports_total",
+			Help: "Number of OTLP exports attempted, one per account and interval",
+		}),
+		failedExports: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "atlas_exporter_otlp_failed_exports_total",
+			Help: "Number of OTLP exports that failed after retries",
+		}),
+	}
+	for _, opt := range opts {
+		if err = opt(e); err != nil {
+			return nil, fmt.Errorf("failed to apply option: %w", err)
+		}
+	}
+	switch protocol {
+	case ProtocolGRPC:
+		e.exporter, err = otlpmetricgrpc.New(ctx,
+			otlpmetricgrpc.WithEndpointURL(endpoint),
+			otlpmetricgrpc.WithHeaders(e.headers),
+			otlpmetricgrpc.WithTimeout(e.timeout),
+		)
+	case ProtocolHTTP:
+		if parsed.Path == "" || parsed.Path == "/" {
+			parsed.Path = defaultHTTPPath
+		}
+		e.exporter, err = otlpmetrichttp.New(ctx,
+			otlpmetrichttp.WithEndpointURL(parsed.String()),
+			otlpmetrichttp.WithHeaders(e.headers),
+			otlpmetrichttp.WithTimeout(e.timeout),
+		)
+	default:
+		return nil, fmt.Errorf("unsupported OTLP protocol %q, must be %s or %s", protocol, ProtocolGRPC, ProtocolHTTP)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP %s exporter: %w", protocol, err)
+	}
+	return e, nil
+}
+
+// Run exports the metrics of g every interval until ctx is cancelled, then
+// shuts the exporter down.
+func (e *Exporter) Run(ctx context.Context, g prometheus.Gatherer, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := e.Export(ctx, g); err != nil {
+			e.logger.WithError(err).Error("Failed to export metrics with OTLP")
+		}
+		select {
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), e.timeout)
+			if err := e.exporter.Shutdown(shutdownCtx); err != nil {
+				e.logger.WithError(err).Warn("Failed to shut down the OTLP exporter")
+			}
+			cancel()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Export gathers g and sends the metrics of every account as its own resource.
+// Metrics without an account label are sent as a resource without the
+// atlas.account attribute. Metrics gathered despite an error are still sent.
+func (e *Exporter) Export(ctx context.Context, g prometheus.Gatherer) error {
+	families, gatherErr := g.Gather()
+	groups := splitByAccount(families)
+	accounts := make([]string, 0, len(groups))
+	for account := range groups {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+	errs := []error{gatherErr}
+	for _, account := range accounts {
+		group := groups[account]
+		producer := promBridge.NewMetricProducer(promBridge.WithGatherer(prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+			return group, nil
+		})))
+		scopes, err := producer.Produce(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to convert metrics: %w", err))
+			continue
+		}
+		e.exports.Inc()
+		if err = e.exporter.Export(ctx, &metricdata.ResourceMetrics{Resource: e.resource(account), ScopeMetrics: scopes}); err != nil {
+			e.failedExports.Inc()
+			if account != "" {
+				err = fmt.Errorf("account %s: %w", account, err)
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// resource returns the resource of the metrics of account.
+func (e *Exporter) resource(account string) *resource.Resource {
+	attributes := []attribute.KeyValue{
+		semconv.ServiceName(serviceName),
+		semconv.ServiceVersion(version.Version),
+	}
+	attributes = append(attributes, e.attributes...)
+	if account != "" {
+		attributes = append(attributes, attribute.String(accountAttribute, account))
+	}
+	return resource.NewWithAttributes(semconv.SchemaURL, attributes...)
+}
+
+// splitByAccount groups the metrics of the families by the value of their
+// account label, which is removed. Metrics without the label are grouped
+// under the empty string.
+func splitByAccount(families []*dto.MetricFamily) map[string][]*dto.MetricFamily {
+	groups := map[string][]*dto.MetricFamily{}
+	for _, family := range families {
+		byAccount := map[string]*dto.MetricFamily{}
+		for _, metric := range family.GetMetric() {
+			account := ""
+			metric = proto.Clone(metric).(*dto.Metric)
+			labels := metric.Label[:0]
+			for _, pair := range metric.GetLabel() {
+				if pair.GetName() == accountLabel {
+					account = pair.GetValue()
+					continue
+				}
+				labels = append(labels, pair)
+			}
+			metric.Label = labels
+			grouped, ok := byAccount[account]
+			if !ok {
+				grouped = &dto.MetricFamily{Name: family.Name, Help: family.Help, Type: family.Type, Unit: family.Unit}
+				byAccount[account] = grouped
+				groups[account] = append(groups[account], grouped)
+			}
+			grouped.Metric = append(grouped.Metric, metric)
+		}
+	}
+	return groups
+}
+
+func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
+	e.exports.Describe(ch)
+	e.failedExports.Describe(ch)
+}
+
+func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
+	ch <- e.exports
+	ch <- e.failedExports
+}
@@ -0,0 +1,228 @@
+package otlp
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/version"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	collectormetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// fakeCollector is an in-process stand-in for an OpenTelemetry collector
+// receiving metrics over gRPC and HTTP.
+type fakeCollector struct {
+	collectormetrics.UnimplementedMetricsServiceServer
+	mu       sync.Mutex
+	requests []*collectormetrics.ExportMetricsServiceRequest
+	headers  []string
+}
+
+func (f *fakeCollector) record(req *collectormetrics.ExportMetricsServiceRequest, tenant string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, req)
+	f.headers = append(f.headers, tenant)
+}
+
+func (f *fakeCollector) Export(ctx context.Context, req *collectormetrics.ExportMetricsServiceRequest) (*collectormetrics.ExportMetricsServiceResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	f.record(req, strings.Join(md.Get("x-tenant"), ","))
+	return &collectormetrics.ExportMetricsServiceResponse{}, nil
+}
+
+func (f *fakeCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != defaultHTTPPath {
+		http.NotFound(w, r)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	req := &collectormetrics.ExportMetricsServiceRequest{}
+	if err := proto.Unmarshal(body, req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.record(req, r.Header.Get("X-Tenant"))
+	response, _ := proto.Marshal(&collectormetrics.ExportMetricsServiceResponse{})
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	_, _ = w.Write(response)
+}
+
+// resources returns the received resource metrics keyed by their
+// atlas.account attribute.
+func (f *fakeCollector) resources(t *testing.T) map[string]*metricspb.ResourceMetrics {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	result := map[string]*metricspb.ResourceMetrics{}
+	for _, req := range f.requests {
+		for _, rm := range req.GetResourceMetrics() {
+			attributes := map[string]string{}
+			for _, kv := range rm.GetResource().GetAttributes() {
+				attributes[kv.GetKey()] = kv.GetValue().GetStringValue()
+			}
+			if attributes["service.name"] != serviceName || attributes["service.version"] != version.Version || attributes["site"] != "ams" {
+				t.Errorf("unexpected resource attributes %v", attributes)
+			}
+			result[attributes[accountAttribute]] = rm
+		}
+	}
+	return result
+}
+
+func startGRPCCollector(t *testing.T, collector *fakeCollector) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := grpc.NewServer()
+	collectormetrics.RegisterMetricsServiceServer(server, collector)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+	return "http://" + listener.Addr().String()
+}
+
+func findMetric(rm *metricspb.ResourceMetrics, name string) *metricspb.Metric {
+	for _, scope := range rm.GetScopeMetrics() {
+		for _, metric := range scope.GetMetrics() {
+			if metric.GetName() == name {
+				return metric
+			}
+		}
+	}
+	return nil
+}
+
+func TestExport(t *testing.T) {
+	tests := []struct {
+		name     string
+		protocol string
+		start    func(*testing.T, *fakeCollector) string
+	}{
+		{"grpc", ProtocolGRPC, startGRPCCollector},
+		{"http", ProtocolHTTP, func(t *testing.T, collector *fakeCollector) string {
+			server := httptest.NewServer(collector)
+			t.Cleanup(server.Close)
+			return server.URL
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector := &fakeCollector{}
+			endpoint := tt.start(t, collector)
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+			exporter, err := New(t.Context(), tt.protocol, endpoint,
+				WithHeaders(map[string]string{"X-Tenant": "atlas"}),
+				WithResourceAttributes(map[string]string{"site": "ams"}),
+				WithLogger(logger),
+			)
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+
+			reg := prometheus.NewRegistry()
+			credits := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "atlas_exporter_credits", Help: "Credits"}, []string{"account"})
+			credits.WithLabelValues("team-a").Set(500)
+			credits.WithLabelValues("team-b").Set(20)
+			requests := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "atlas_exporter_api_requests_total", Help: "Requests"}, []string{"account", "code"})
+			requests.WithLabelValues("team-a", "200").Add(3)
+			buildInfo := prometheus.NewGauge(prometheus.GaugeOpts{Name: "atlas_exporter_build_info", Help: "Build"})
+			buildInfo.Set(1)
+			reg.MustRegister(credits, requests, buildInfo)
+
+			if err = exporter.Export(t.Context(), reg); err != nil {
+				t.Fatalf("Export failed: %v", err)
+			}
+			resources := collector.resources(t)
+			if len(resources) != 3 {
+				t.Fatalf("expected 3 resources, got %d", len(resources))
+			}
+			for account, expected := range map[string]float64{"team-a": 500, "team-b": 20} {
+				metric := findMetric(resources[account], "atlas_exporter_credits")
+				if metric == nil {
+					t.Fatalf("expected atlas_exporter_credits for %s", account)
+				}
+				points := metric.GetGauge().GetDataPoints()
+				if len(points) != 1 || points[0].GetAsDouble() != expected {
+					t.Errorf("expected one gauge point of %v for %s, got %v", expected, account, points)
+				}
+				if len(points[0].GetAttributes()) != 0 {
+					t.Errorf("expected the account label to be removed, got %v", points[0].GetAttributes())
+				}
+			}
+			sum := findMetric(resources["team-a"], "atlas_exporter_api_requests_total").GetSum()
+			if !sum.GetIsMonotonic() || len(sum.GetDataPoints()) != 1 || sum.GetDataPoints()[0].GetAsDouble() != 3 {
+				t.Errorf("expected a monotonic sum of 3, got %v", sum)
+			}
+			if findMetric(resources[""], "atlas_exporter_build_info") == nil {
+				t.Error("expected metrics without an account label in a resource without atlas.account")
+			}
+			for _, header := range collector.headers {
+				if header != "atlas" {
+					t.Errorf("expected the X-Tenant header to be sent, got %q", header)
+				}
+			}
+			if exports := testutil.ToFloat64(exporter.exports); exports != 3 {
+				t.Errorf("expected 3 exports, got %v", exports)
+			}
+		})
+	}
+}
+
+func TestExportFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	t.Cleanup(server.Close)
+	exporter, err := New(t.Context(), ProtocolHTTP, server.URL)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	reg := prometheus.NewRegistry()
+	credits := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "atlas_exporter_credits", Help: "Credits"}, []string{"account"})
+	credits.WithLabelValues("team-a").Set(500)
+	reg.MustRegister(credits)
+	if err = exporter.Export(t.Context(), reg); err == nil || !strings.Contains(err.Error(), "account team-a") {
+		t.Errorf("expected an error for account team-a, got %v", err)
+	}
+	if failed := testutil.ToFloat64(exporter.failedExports); failed != 1 {
+		t.Errorf("expected 1 failed export, got %v", failed)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		protocol string
+		endpoint string
+		opts     []Option
+		expected string
+	}{
+		{"protocol", "udp", "http://localhost:4317", nil, `unsupported OTLP protocol "udp"`},
+		{"endpoint", ProtocolGRPC, "localhost:4317", nil, "must be an http or https URL"},
+		{"timeout", ProtocolGRPC, "http://localhost:4317", []Option{WithTimeout(0)}, "timeout must be positive"},
+		{"account attribute", ProtocolHTTP, "http://localhost:4318", []Option{WithResourceAttributes(map[string]string{accountAttribute: "x"})}, "set from the account label"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(t.Context(), tt.protocol, tt.endpoint, tt.opts...)
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("expected error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package remotewrite
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/internal/samples"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Field numbers of the remote write 1.0 protobuf messages.
+const (
+	writeRequestTimeseries = 1
+	writeRequestMetadata   = 3
+
+	timeSeriesLabels  = 1
+	timeSeriesSamples = 2
+
+	labelName  = 1
+	labelValue = 2
+
+	sampleValue     = 1
+	sampleTimestamp = 2
+
+	metadataType = 1
+	metadataName = 2
+	metadataHelp = 4
+)
+
+// Metric types of the MetricMetadata message.
+const (
+	metadataTypeUnknown   = 0
+	metadataTypeCounter   = 1
+	metadataTypeGauge     = 2
+	metadataTypeHistogram = 3
+	metadataTypeSummary   = 5
+)
+
+type label struct {
+	name  string
+	value string
+}
+
+// series is a single sample with its labels, including __name__.
+type series struct {
+	labels    []label
+	value     float64
+	timestamp int64
+}
+
+// encodeWriteRequest encodes the metric families as a remote write 1.0
+// WriteRequest. Samples without a timestamp are timestamped with now.
+// Summaries and histograms are split into their _sum, _count, quantile and
+// _bucket series like in the text format.
+func encodeWriteRequest(families []*dto.MetricFamily, externalLabels map[string]string, now time.Time) ([]byte, int) {
+	var buf []byte
+	samples := 0
+	for _, family := range families {
+		for _, s := range familySeries(family, externalLabels, now) {
+			buf = protowire.AppendTag(buf, writeRequestTimeseries, protowire.BytesType)
+			buf = protowire.AppendBytes(buf, encodeSeries(s))
+			samples++
+		}
+	}
+	for _, family := range families {
+		var metadata []byte
+		metadata = protowire.AppendTag(metadata, metadataType, protowire.VarintType)
+		metadata = protowire.AppendVarint(metadata, metadataTypeOf(family.GetType()))
+		metadata = protowire.AppendTag(metadata, metadataName, protowire.BytesType)
+		metadata = protowire.AppendString(metadata, family.GetName())
+		metadata = protowire.AppendTag(metadata, metadataHelp, protowire.BytesType)
+		metadata = protowire.AppendString(metadata, family.GetHelp())
+		buf = protowire.AppendTag(buf, writeRequestMetadata, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, metadata)
+	}
+	return buf, samples
+}
+
+func encodeSeries(s series) []byte {
+	var buf []byte
+	for _, l := range s.labels {
+		var encoded []byte
+		encoded = protowire.AppendTag(encoded, labelName, protowire.BytesType)
+		encoded = protowire.AppendString(encoded, l.name)
+		encoded = protowire.AppendTag(encoded, labelValue, protowire.BytesType)
+		encoded = protowire.AppendString(encoded, l.value)
+		buf = protowire.AppendTag(buf, timeSeriesLabels, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, encoded)
+	}
+	var sample []byte
+	sample = protowire.AppendTag(sample, sampleValue, protowire.Fixed64Type)
+	sample = protowire.AppendFixed64(sample, math.Float64bits(s.value))
+	sample = protowire.AppendTag(sample, sampleTimestamp, protowire.VarintType)
+	sample = protowire.AppendVarint(sample, uint64(s.timestamp))
+	buf = protowire.AppendTag(buf, timeSeriesSamples, protowire.BytesType)
+	return protowire.AppendBytes(buf, sample)
+}
+
+func metadataTypeOf(metricType dto.MetricType) uint64 {
+	switch metricType {
+	case dto.MetricType_COUNTER:
+		return metadataTypeCounter
+	case dto.MetricType_GAUGE:
+		return metadataTypeGauge
+	case dto.MetricType_HISTOGRAM:
+		return metadataTypeHistogram
+	case dto.MetricType_SUMMARY:
+		return metadataTypeSummary
+	default:
+		return metadataTypeUnknown
+	}
+}
+
+// familySeries returns every series of a metric family.
+func familySeries(family *dto.MetricFamily, externalLabels map[string]string, now time.Time) []series {
+	var result []series
+	samples.Visit(family, func(metric *dto.Metric, name string, value float64, extra ...samples.Label) {
+		timestamp := now.UnixMilli()
+		if metric.TimestampMs != nil {
+			timestamp = metric.GetTimestampMs()
+		}
+		result = append(result, series{
+			labels:    seriesLabels(name, metric.GetLabel(), externalLabels, extra...),
+			value:     value,
+			timestamp: timestamp,
+		})
+	})
+	return result
+}
+
+// seriesLabels returns the labels of a series sorted by name. Labels of the
+// metric take precedence over external labels.
+func seriesLabels(name string, pairs []*dto.LabelPair, externalLabels map[string]string, extra ...samples.Label) []label {
+	labels := map[string]string{}
+	for labelName, value := range externalLabels {
+		labels[labelName] = value
+	}
+	for _, pair := range pairs {
+		labels[pair.GetName()] = pair.GetValue()
+	}
+	for _, l := range extra {
+		labels[l.Name] = l.Value
+	}
+	labels["__name__"] = name
+	result := make([]label, 0, len(labels))
+	for labelName, value := range labels {
+		result = append(result, label{labelName, value})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].name < result[j].name })
+	return result
+}
@@ -0,0 +1,109 @@
+package remotewrite
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Option is a functional option for configuring the remote write client.
+type Option func(*Client) error
+
+// WithHTTPClient accepts a custom *http.Client for sending requests.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) error {
+		c.httpClient = client
+		return nil
+	}
+}
+
+// WithHeaders sets custom HTTP headers sent with every request (e.g. a
+// tenant ID for Mimir or Cortex).
+func WithHeaders(headers http.Header) Option {
+	return func(c *Client) error {
+		c.headers = headers.Clone()
+		return nil
+	}
+}
+
+// WithBasicAuth authenticates requests with HTTP basic auth.
+func WithBasicAuth(username, password string) Option {
+	return func(c *Client) error {
+		if username == "" {
+			return errors.New("basic auth username cannot be empty")
+		}
+		c.username = username
+		c.password = password
+		return nil
+	}
+}
+
+// WithBearerToken authenticates requests with a bearer token.
+func WithBearerToken(token string) Option {
+	return func(c *Client) error {
+		if token == "" {
+			return errors.New("bearer token cannot be empty")
+		}
+		c.bearerToken = token
+		return nil
+	}
+}
+
+// WithTimeout sets the timeout of a single request.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) error {
+		if timeout <= 0 {
+			return errors.New("timeout must be positive")
+		}
+		c.httpClient.Timeout = timeout
+		return nil
+	}
+}
+
+// WithExternalLabels adds labels to every series. Labels of the metrics take
+// precedence over external labels.
+func WithExternalLabels(labels map[string]string) Option {
+	return func(c *Client) error {
+		c.externalLabels = labels
+		return nil
+	}
+}
+
+// WithQueueCapacity sets how many batches can wait to be sent before the
+// oldest is dropped.
+func WithQueueCapacity(capacity int) Option {
+	return func(c *Client) error {
+		if capacity <= 0 {
+			return errors.New("queue capacity must be positive")
+		}
+		c.queue = make(chan batch, capacity)
+		return nil
+	}
+}
+
+// WithRetries sets how many times a batch is retried after a recoverable
+// error, and the backoff between attempts which doubles up to maxBackoff.
+func WithRetries(maxRetries int, minBackoff, maxBackoff time.Duration) Option {
+	return func(c *Client) error {
+		if maxRetries < 0 {
+			return errors.New("max retries cannot be negative")
+		}
+		if minBackoff <= 0 || maxBackoff < minBackoff {
+			return errors.New("backoff must be positive and min backoff must not exceed max backoff")
+		}
+		c.maxRetries = maxRetries
+		c.minBackoff = minBackoff
+		c.maxBackoff = maxBackoff
+		return nil
+	}
+}
+
+// WithLogger sets the logger used to report send failures.
+func WithLogger(logger logrus.FieldLogger) Option {
+	return func(c *Client) error {
+		c.logger = logger
+		return nil
+	}
+}
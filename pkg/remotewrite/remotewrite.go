@@ -0,0 +1,222 @@
+// Package remotewrite sends the metrics of a prometheus.Gatherer to a
+// Prometheus remote write 1.0 endpoint such as Prometheus, Mimir or Thanos.
+package remotewrite
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/version"
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+var queueLengthDesc = prometheus.NewDesc(
+	"atlas_exporter_remote_write_queue_length",
+	"Number of batches waiting to be sent",
+	nil,
+	nil,
+)
+
+// batch is an encoded WriteRequest waiting to be sent.
+type batch struct {
+	payload []byte
+	samples int
+}
+
+// Client gathers metrics and sends them to a remote write endpoint. Batches
+// are queued and sent in order, retrying recoverable errors with exponential
+// backoff. When the queue is full, the oldest batch is dropped.
+type Client struct {
+	url            string
+	httpClient     *http.Client
+	headers        http.Header
+	username       string
+	password       string
+	bearerToken    string
+	externalLabels map[string]string
+	maxRetries     int
+	minBackoff     time.Duration
+	maxBackoff     time.Duration
+	logger         logrus.FieldLogger
+	queue          chan batch
+
+	sentSamples    prometheus.Counter
+	failedBatches  prometheus.Counter
+	droppedBatches prometheus.Counter
+}
+
+// recoverableError is an error after which sending the batch again may succeed.
+type recoverableError struct {
+	error
+}
+
+// New creates a Client sending to endpoint.
+func New(endpoint string, opts ...Option) (*Client, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid remote write URL: %w", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, fmt.Errorf("invalid remote write URL %q: scheme must be http or https", endpoint)
+	}
+	c := &Client{
+		url:        endpoint,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		headers:    http.Header{},
+		maxRetries: 5,
+		minBackoff: time.Second,
+		maxBackoff: time.Minute,
+		logger:     logrus.StandardLogger(),
+		queue:      make(chan batch, 10),
+		sentSamples: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "atlas_exporter_remote_write_sent_samples_total",
+			Help: "Number of samples sent to the remote write endpoint",
+		}),
+		failedBatches: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "atlas_exporter_remote_write_failed_batches_total",
+			Help: "Number of batches dropped after a non-recoverable error or too many retries",
+		}),
+		droppedBatches: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "atlas_exporter_remote_write_dropped_batches_total",
+			Help: "Number of batches dropped because the queue was full",
+		}),
+	}
+	for _, opt := range opts {
+		if err = opt(c); err != nil {
+			return nil, fmt.Errorf("failed to apply option: %w", err)
+		}
+	}
+	return c, nil
+}
+
+// Run gathers g every interval and sends the samples until ctx is cancelled.
+func (c *Client) Run(ctx context.Context, g prometheus.Gatherer, interval time.Duration) {
+	go c.send(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := c.Enqueue(g, time.Now()); err != nil {
+			c.logger.WithError(err).Error("Failed to gather metrics for remote write")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Enqueue gathers g and queues the samples, timestamped with now, to be sent.
+// Metrics gathered despite an error are still queued.
+func (c *Client) Enqueue(g prometheus.Gatherer, now time.Time) error {
+	families, err := g.Gather()
+	payload, samples := encodeWriteRequest(families, c.externalLabels, now)
+	b := batch{payload: snappy.Encode(nil, payload), samples: samples}
+	for {
+		select {
+		case c.queue <- b:
+			return err
+		default:
+		}
+		select {
+		case <-c.queue:
+			c.droppedBatches.Inc()
+			c.logger.Warn("Remote write queue is full, dropped the oldest batch")
+		default:
+		}
+	}
+}
+
+// send sends the queued batches in order until ctx is cancelled.
+func (c *Client) send(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case b := <-c.queue:
+			c.sendWithRetries(ctx, b)
+		}
+	}
+}
+
+func (c *Client) sendWithRetries(ctx context.Context, b batch) {
+	backoff := c.minBackoff
+	for attempt := 0; ; attempt++ {
+		err := c.Send(ctx, b.payload)
+		if err == nil {
+			c.sentSamples.Add(float64(b.samples))
+			return
+		}
+		var recoverable recoverableError
+		if !errors.As(err, &recoverable) || attempt >= c.maxRetries {
+			c.failedBatches.Inc()
+			c.logger.WithError(err).Errorf("Failed to send %d samples to the remote write endpoint, dropping them", b.samples)
+			return
+		}
+		c.logger.WithError(err).Warnf("Failed to send samples to the remote write endpoint, retrying in %s", backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, c.maxBackoff)
+	}
+}
+
+// Send sends a snappy compressed WriteRequest. Network errors, 429 and 5xx
+// responses are recoverable.
+func (c *Client) Send(ctx context.Context, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create remote write request: %w", err)
+	}
+	for name, values := range c.headers {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", "go-atlas-stats-exporter/"+version.Version)
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	switch {
+	case c.bearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+c.bearerToken)
+	case c.username != "":
+		req.SetBasicAuth(c.username, c.password)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return recoverableError{fmt.Errorf("remote write request failed: %w", err)}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("remote write endpoint returned status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError {
+		return recoverableError{err}
+	}
+	return err
+}
+
+func (c *Client) Describe(ch chan<- *prometheus.Desc) {
+	c.sentSamples.Describe(ch)
+	c.failedBatches.Describe(ch)
+	c.droppedBatches.Describe(ch)
+	ch <- queueLengthDesc
+}
+
+func (c *Client) Collect(ch chan<- prometheus.Metric) {
+	ch <- c.sentSamples
+	ch <- c.failedBatches
+	ch <- c.droppedBatches
+	ch <- prometheus.MustNewConstMetric(queueLengthDesc, prometheus.GaugeValue, float64(len(c.queue)))
+}
@@ -0,0 +1,317 @@
+package remotewrite
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// receivedSeries is a series decoded by the test receiver, with its labels
+// formatted as name="value" pairs.
+type receivedSeries struct {
+	labels    string
+	value     float64
+	timestamp int64
+}
+
+// decodeWriteRequest decodes the time series of a WriteRequest.
+func decodeWriteRequest(t *testing.T, data []byte) []receivedSeries {
+	t.Helper()
+	var result []receivedSeries
+	forEachField(t, data, func(num protowire.Number, value []byte) {
+		if num != writeRequestTimeseries {
+			return
+		}
+		var s receivedSeries
+		var labels []string
+		forEachField(t, value, func(num protowire.Number, value []byte) {
+			switch num {
+			case timeSeriesLabels:
+				var name, labelVal string
+				forEachField(t, value, func(num protowire.Number, value []byte) {
+					if num == labelName {
+						name = string(value)
+					} else {
+						labelVal = string(value)
+					}
+				})
+				labels = append(labels, name+"="+`"`+labelVal+`"`)
+			case timeSeriesSamples:
+				forEachField(t, value, func(num protowire.Number, value []byte) {
+					if num == sampleValue {
+						bits, _ := protowire.ConsumeFixed64(value)
+						s.value = math.Float64frombits(bits)
+					} else {
+						v, _ := protowire.ConsumeVarint(value)
+						s.timestamp = int64(v)
+					}
+				})
+			}
+		})
+		s.labels = strings.Join(labels, ",")
+		result = append(result, s)
+	})
+	return result
+}
+
+// forEachField calls fn with every field of a protobuf message. Varint and
+// fixed64 values are passed still encoded.
+func forEachField(t *testing.T, data []byte, fn func(protowire.Number, []byte)) {
+	t.Helper()
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			t.Fatalf("Failed to decode tag: %v", protowire.ParseError(n))
+		}
+		data = data[n:]
+		var value []byte
+		switch typ {
+		case protowire.BytesType:
+			value, n = protowire.ConsumeBytes(data)
+		case protowire.VarintType:
+			_, n = protowire.ConsumeVarint(data)
+			value = data[:max(n, 0)]
+		case protowire.Fixed64Type:
+			_, n = protowire.ConsumeFixed64(data)
+			value = data[:max(n, 0)]
+		default:
+			t.Fatalf("unexpected wire type %d", typ)
+		}
+		if n < 0 {
+			t.Fatalf("Failed to decode field %d: %v", num, protowire.ParseError(n))
+		}
+		fn(num, value)
+		data = data[n:]
+	}
+}
+
+// receiver is a fake remote write endpoint answering with the given statuses
+// in order, then 204.
+type receiver struct {
+	t        *testing.T
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	series   [][]receivedSeries
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	decoded, err := snappy.Decode(nil, body)
+	if err != nil {
+		r.t.Errorf("Failed to decode snappy body: %v", err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req)
+	r.series = append(r.series, decodeWriteRequest(r.t, decoded))
+	status := http.StatusNoContent
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (r *receiver) requestCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.requests)
+}
+
+func testRegistry() *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	credits := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "atlas_exporter_credits", Help: "Credits"}, []string{"account"})
+	credits.WithLabelValues("team-a").Set(500)
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "duration_seconds", Help: "Duration", Buckets: []float64{1}})
+	histogram.Observe(0.5)
+	reg.MustRegister(credits, histogram)
+	return reg
+}
+
+func testLogger() logrus.FieldLogger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func TestEnqueueAndSend(t *testing.T) {
+	recv := &receiver{t: t}
+	server := httptest.NewServer(recv)
+	t.Cleanup(server.Close)
+
+	client, err := New(server.URL,
+		WithBearerToken("secret"),
+		WithHeaders(http.Header{"X-Scope-Orgid": []string{"tenant"}}),
+		WithExternalLabels(map[string]string{"cluster": "edge", "account": "overridden"}),
+		WithLogger(testLogger()),
+	)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	now := time.UnixMilli(1700000000000)
+	if err = client.Enqueue(testRegistry(), now); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	client.sendWithRetries(t.Context(), <-client.queue)
+
+	if recv.requestCount() != 1 {
+		t.Fatalf("expected 1 request, got %d", recv.requestCount())
+	}
+	req := recv.requests[0]
+	expectedHeaders := map[string]string{
+		"Authorization":                     "Bearer secret",
+		"Content-Encoding":                  "snappy",
+		"Content-Type":                      "application/x-protobuf",
+		"X-Prometheus-Remote-Write-Version": "0.1.0",
+		"X-Scope-Orgid":                     "tenant",
+	}
+	for name, expected := range expectedHeaders {
+		if got := req.Header.Get(name); got != expected {
+			t.Errorf("expected header %s to be %q, got %q", name, expected, got)
+		}
+	}
+
+	got := map[string]float64{}
+	for _, s := range recv.series[0] {
+		if s.timestamp != now.UnixMilli() {
+			t.Errorf("expected timestamp %d for %s, got %d", now.UnixMilli(), s.labels, s.timestamp)
+		}
+		got[s.labels] = s.value
+	}
+	expected := map[string]float64{
+		`__name__="atlas_exporter_credits",account="team-a",cluster="edge"`:                500,
+		`__name__="duration_seconds_bucket",account="overridden",cluster="edge",le="1"`:    1,
+		`__name__="duration_seconds_bucket",account="overridden",cluster="edge",le="+Inf"`: 1,
+		`__name__="duration_seconds_sum",account="overridden",cluster="edge"`:              0.5,
+		`__name__="duration_seconds_count",account="overridden",cluster="edge"`:            1,
+	}
+	if len(got) != len(expected) {
+		keys := make([]string, 0, len(got))
+		for k := range got {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		t.Fatalf("expected %d series, got %d: %v", len(expected), len(got), keys)
+	}
+	for labels, value := range expected {
+		if v, ok := got[labels]; !ok || v != value {
+			t.Errorf("expected series {%s} with value %v, got %v (present: %t)", labels, value, v, ok)
+		}
+	}
+	if sent := testutil.ToFloat64(client.sentSamples); sent != 5 {
+		t.Errorf("expected 5 sent samples, got %v", sent)
+	}
+}
+
+func TestSendRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		requests int
+		sent     float64
+		failed   float64
+	}{
+		{"success", nil, 1, 1, 0},
+		{"recovers after server errors", []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}, 3, 1, 0},
+		{"client error is not retried", []int{http.StatusBadRequest}, 1, 0, 1},
+		{"gives up after max retries", []int{500, 500, 500, 500}, 3, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recv := &receiver{t: t, statuses: tt.statuses}
+			server := httptest.NewServer(recv)
+			t.Cleanup(server.Close)
+			client, err := New(server.URL,
+				WithBasicAuth("writer", "password"),
+				WithRetries(2, time.Millisecond, 2*time.Millisecond),
+				WithLogger(testLogger()),
+			)
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+			reg := prometheus.NewRegistry()
+			reg.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: "up_gauge", Help: "Up"}))
+			if err = client.Enqueue(reg, time.Now()); err != nil {
+				t.Fatalf("Enqueue failed: %v", err)
+			}
+			client.sendWithRetries(t.Context(), <-client.queue)
+
+			if recv.requestCount() != tt.requests {
+				t.Errorf("expected %d requests, got %d", tt.requests, recv.requestCount())
+			}
+			if username, password, _ := recv.requests[0].BasicAuth(); username != "writer" || password != "password" {
+				t.Errorf("expected basic auth credentials, got %q:%q", username, password)
+			}
+			if sent := testutil.ToFloat64(client.sentSamples); sent != tt.sent {
+				t.Errorf("expected %v sent samples, got %v", tt.sent, sent)
+			}
+			if failed := testutil.ToFloat64(client.failedBatches); failed != tt.failed {
+				t.Errorf("expected %v failed batches, got %v", tt.failed, failed)
+			}
+		})
+	}
+}
+
+func TestQueueDropsOldest(t *testing.T) {
+	recv := &receiver{t: t}
+	server := httptest.NewServer(recv)
+	t.Cleanup(server.Close)
+	client, err := New(server.URL, WithQueueCapacity(2), WithLogger(testLogger()))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	reg := prometheus.NewRegistry()
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "value", Help: "Value"})
+	reg.MustRegister(gauge)
+	for i := range 3 {
+		gauge.Set(float64(i))
+		if err = client.Enqueue(reg, time.Now()); err != nil {
+			t.Fatalf("Enqueue failed: %v", err)
+		}
+	}
+	if dropped := testutil.ToFloat64(client.droppedBatches); dropped != 1 {
+		t.Errorf("expected 1 dropped batch, got %v", dropped)
+	}
+	for len(client.queue) > 0 {
+		client.sendWithRetries(t.Context(), <-client.queue)
+	}
+	for i, series := range recv.series {
+		if series[0].value != float64(i+1) {
+			t.Errorf("expected batch %d to hold value %d, got %v", i, i+1, series[0].value)
+		}
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		opts     []Option
+		expected string
+	}{
+		{"scheme", "ftp://example.com", nil, "scheme must be http or https"},
+		{"empty bearer token", "http://example.com", []Option{WithBearerToken("")}, "bearer token cannot be empty"},
+		{"queue capacity", "http://example.com", []Option{WithQueueCapacity(0)}, "queue capacity must be positive"},
+		{"backoff", "http://example.com", []Option{WithRetries(1, time.Minute, time.Second)}, "min backoff must not exceed max backoff"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.url, tt.opts...)
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("expected error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
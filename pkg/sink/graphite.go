@@ -0,0 +1,103 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// invalidGraphiteChars matches the characters replaced in Graphite path
+// components and tags.
+var invalidGraphiteChars = regexp.MustCompile(`[^a-zA-Z0-9_\-]`)
+
+// Graphite writes samples in the plaintext protocol over TCP. By default the
+// tags of a sample are encoded in its path as prefix.name.tag.value, with
+// WithGraphiteTags they are written as Graphite 1.1 tags.
+type Graphite struct {
+	address string
+	options *options
+}
+
+// NewGraphite creates a sink writing to the plaintext listener at address,
+// usually port 2003 of carbon or carbon-relay.
+func NewGraphite(address string, opts ...Option) (*Graphite, error) {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return nil, fmt.Errorf("invalid Graphite address %q: %w", address, err)
+	}
+	o, err := newOptions(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply option: %w", err)
+	}
+	return &Graphite{address: address, options: o}, nil
+}
+
+func (g *Graphite) Name() string {
+	return "graphite"
+}
+
+// Send writes the samples over a new connection. NaN and infinite values are
+// skipped.
+func (g *Graphite) Send(ctx context.Context, samples []Sample) error {
+	ctx, cancel := context.WithTimeout(ctx, g.options.timeout)
+	defer cancel()
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", g.address)
+	if err != nil {
+		return fmt.Errorf("failed to connect to Graphite: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err = conn.SetWriteDeadline(deadline); err != nil {
+			return fmt.Errorf("failed to set write deadline: %w", err)
+		}
+	}
+	if _, err = conn.Write(g.encode(samples)); err != nil {
+		return fmt.Errorf("failed to write to Graphite: %w", err)
+	}
+	return nil
+}
+
+// encode returns the samples in the plaintext protocol, one per line.
+func (g *Graphite) encode(samples []Sample) []byte {
+	var buf bytes.Buffer
+	for _, s := range samples {
+		if math.IsNaN(s.Value) || math.IsInf(s.Value, 0) {
+			continue
+		}
+		buf.WriteString(g.path(s))
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.FormatFloat(s.Value, 'g', -1, 64))
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.FormatInt(s.Timestamp.Truncate(time.Second).Unix(), 10))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+func (g *Graphite) path(s Sample) string {
+	var buf bytes.Buffer
+	buf.WriteString(g.options.prefix)
+	buf.WriteString(sanitizeGraphite(s.Name))
+	for _, tag := range g.options.tagsOf(s) {
+		if g.options.tagged {
+			buf.WriteByte(';')
+			buf.WriteString(sanitizeGraphite(tag.Name))
+			buf.WriteByte('=')
+		} else {
+			buf.WriteByte('.')
+			buf.WriteString(sanitizeGraphite(tag.Name))
+			buf.WriteByte('.')
+		}
+		buf.WriteString(sanitizeGraphite(tag.Value))
+	}
+	return buf.String()
+}
+
+func sanitizeGraphite(s string) string {
+	return invalidGraphiteChars.ReplaceAllString(s, "_")
+}
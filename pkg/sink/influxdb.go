@@ -0,0 +1,110 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// The line protocol cannot escape line breaks, which end the point, so they
+// are written as escaped spaces.
+var (
+	measurementEscaper = strings.NewReplacer(`,`, `\,`, ` `, `\ `, "\n", `\ `, "\r", `\ `)
+	tagEscaper         = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `, "\n", `\ `, "\r", `\ `)
+)
+
+// InfluxDB writes samples in the line protocol to the HTTP write API of
+// InfluxDB 1 (/write?db=...) or 2 (/api/v2/write?org=...&bucket=...). Every
+// sample is a point of the measurement named after the metric with a single
+// value field.
+type InfluxDB struct {
+	url        string
+	httpClient *http.Client
+	options    *options
+}
+
+// NewInfluxDB creates a sink writing to the write API at writeURL, which
+// includes the database or the org and bucket in its query.
+func NewInfluxDB(writeURL string, opts ...Option) (*InfluxDB, error) {
+	parsed, err := url.Parse(writeURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid InfluxDB write URL: %w", err)
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid InfluxDB write URL %q: must be an http or https URL", writeURL)
+	}
+	o, err := newOptions(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply option: %w", err)
+	}
+	query := parsed.Query()
+	if query.Get("precision") == "" {
+		query.Set("precision", "ns")
+		parsed.RawQuery = query.Encode()
+	}
+	return &InfluxDB{
+		url:        parsed.String(),
+		httpClient: &http.Client{Timeout: o.timeout},
+		options:    o,
+	}, nil
+}
+
+func (i *InfluxDB) Name() string {
+	return "influxdb"
+}
+
+// Send writes the samples in a single request. NaN and infinite values
+// cannot be written in the line protocol and are skipped.
+func (i *InfluxDB) Send(ctx context.Context, samples []Sample) error {
+	body := i.encode(samples)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create InfluxDB write request: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	switch {
+	case i.options.token != "":
+		req.Header.Set("Authorization", "Token "+i.options.token)
+	case i.options.username != "":
+		req.SetBasicAuth(i.options.username, i.options.password)
+	}
+	resp, err := i.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("InfluxDB write request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("InfluxDB returned status %d: %s", resp.StatusCode, bytes.TrimSpace(message))
+	}
+	return nil
+}
+
+// encode returns the samples in the line protocol, one point per line.
+func (i *InfluxDB) encode(samples []Sample) []byte {
+	var buf bytes.Buffer
+	for _, s := range samples {
+		if math.IsNaN(s.Value) || math.IsInf(s.Value, 0) {
+			continue
+		}
+		buf.WriteString(measurementEscaper.Replace(i.options.prefix + s.Name))
+		for _, tag := range i.options.tagsOf(s) {
+			buf.WriteByte(',')
+			buf.WriteString(tagEscaper.Replace(tag.Name))
+			buf.WriteByte('=')
+			buf.WriteString(tagEscaper.Replace(tag.Value))
+		}
+		buf.WriteString(" value=")
+		buf.WriteString(strconv.FormatFloat(s.Value, 'g', -1, 64))
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.FormatInt(s.Timestamp.UnixNano(), 10))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
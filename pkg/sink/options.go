@@ -0,0 +1,127 @@
+package sink
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// Option is a functional option for configuring a sink.
+type Option func(*options) error
+
+// options holds the settings shared by every sink.
+type options struct {
+	prefix     string
+	tags       map[string]string
+	tagMapping map[string]string
+	timeout    time.Duration
+	// InfluxDB
+	token    string
+	username string
+	password string
+	// Graphite
+	tagged bool
+}
+
+func newOptions(opts []Option) (*options, error) {
+	o := &options{timeout: 30 * time.Second}
+	for _, opt := range opts {
+		if err := opt(o); err != nil {
+			return nil, err
+		}
+	}
+	return o, nil
+}
+
+// WithPrefix prepends prefix to every metric name.
+func WithPrefix(prefix string) Option {
+	return func(o *options) error {
+		o.prefix = prefix
+		return nil
+	}
+}
+
+// WithTags adds tags to every sample. Labels of the sample take precedence.
+func WithTags(tags map[string]string) Option {
+	return func(o *options) error {
+		o.tags = tags
+		return nil
+	}
+}
+
+// WithTagMapping renames labels to tags, e.g. account to atlas_account. A
+// label mapped to an empty name is dropped.
+func WithTagMapping(mapping map[string]string) Option {
+	return func(o *options) error {
+		o.tagMapping = mapping
+		return nil
+	}
+}
+
+// WithTimeout sets the timeout of a single send.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) error {
+		if timeout <= 0 {
+			return errors.New("timeout must be positive")
+		}
+		o.timeout = timeout
+		return nil
+	}
+}
+
+// WithToken authenticates InfluxDB 2 writes with an API token.
+func WithToken(token string) Option {
+	return func(o *options) error {
+		if token == "" {
+			return errors.New("token cannot be empty")
+		}
+		o.token = token
+		return nil
+	}
+}
+
+// WithBasicAuth authenticates InfluxDB 1 writes with a username and password.
+func WithBasicAuth(username, password string) Option {
+	return func(o *options) error {
+		if username == "" {
+			return errors.New("basic auth username cannot be empty")
+		}
+		o.username = username
+		o.password = password
+		return nil
+	}
+}
+
+// WithGraphiteTags writes Graphite 1.1 tagged series (name;tag=value)
+// instead of encoding the labels in the metric path.
+func WithGraphiteTags(tagged bool) Option {
+	return func(o *options) error {
+		o.tagged = tagged
+		return nil
+	}
+}
+
+// tagsOf returns the tags of a sample sorted by name: the static tags, then
+// the labels renamed by the tag mapping.
+func (o *options) tagsOf(s Sample) []Label {
+	tags := map[string]string{}
+	for name, value := range o.tags {
+		tags[name] = value
+	}
+	for _, label := range s.Labels {
+		name := label.Name
+		if mapped, ok := o.tagMapping[name]; ok {
+			name = mapped
+		}
+		if name == "" || label.Value == "" {
+			continue
+		}
+		tags[name] = label.Value
+	}
+	result := make([]Label, 0, len(tags))
+	for name, value := range tags {
+		result = append(result, Label{name, value})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
@@ -0,0 +1,129 @@
+// Package sink sends the metrics of a prometheus.Gatherer to time series
+// databases that do not scrape Prometheus endpoints, such as InfluxDB and
+// Graphite.
+package sink
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/internal/samples"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/sirupsen/logrus"
+)
+
+// Sink writes samples to a time series database.
+type Sink interface {
+	// Name identifies the sink in logs and metrics.
+	Name() string
+	// Send writes the samples.
+	Send(ctx context.Context, samples []Sample) error
+}
+
+// Label is a label of a sample.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is a single value of a metric. Labels are sorted by name.
+type Sample struct {
+	Name      string
+	Labels    []Label
+	Value     float64
+	Timestamp time.Time
+}
+
+// Runner gathers metrics and sends them to sinks.
+type Runner struct {
+	gatherer    prometheus.Gatherer
+	logger      logrus.FieldLogger
+	sentSamples *prometheus.CounterVec
+	failedSends *prometheus.CounterVec
+}
+
+// NewRunner creates a Runner sending the metrics of g.
+func NewRunner(g prometheus.Gatherer, logger logrus.FieldLogger) *Runner {
+	return &Runner{
+		gatherer: g,
+		logger:   logger,
+		sentSamples: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "atlas_exporter_sink_sent_samples_total",
+			Help: "Number of samples sent to each output sink",
+		}, []string{"sink"}),
+		failedSends: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "atlas_exporter_sink_failed_sends_total",
+			Help: "Number of sends to each output sink that failed",
+		}, []string{"sink"}),
+	}
+}
+
+// Run sends the metrics to s every interval until ctx is cancelled.
+func (r *Runner) Run(ctx context.Context, s Sink, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := r.Send(ctx, s, time.Now()); err != nil {
+			r.logger.WithError(err).WithField("sink", s.Name()).Error("Failed to send metrics")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Send gathers the metrics and sends them to s. Samples without a timestamp
+// are timestamped with now. Metrics gathered despite an error are still sent.
+func (r *Runner) Send(ctx context.Context, s Sink, now time.Time) error {
+	families, gatherErr := r.gatherer.Gather()
+	if gatherErr != nil {
+		gatherErr = fmt.Errorf("failed to gather metrics: %w", gatherErr)
+	}
+	samples := Samples(families, now)
+	if err := s.Send(ctx, samples); err != nil {
+		r.failedSends.WithLabelValues(s.Name()).Inc()
+		return fmt.Errorf("failed to send metrics to %s: %w", s.Name(), err)
+	}
+	r.sentSamples.WithLabelValues(s.Name()).Add(float64(len(samples)))
+	return gatherErr
+}
+
+func (r *Runner) Describe(ch chan<- *prometheus.Desc) {
+	r.sentSamples.Describe(ch)
+	r.failedSends.Describe(ch)
+}
+
+func (r *Runner) Collect(ch chan<- prometheus.Metric) {
+	r.sentSamples.Collect(ch)
+	r.failedSends.Collect(ch)
+}
+
+// Samples returns every sample of the metric families. Summaries and
+// histograms are split into their _sum, _count, quantile and _bucket samples
+// like in the text format.
+func Samples(families []*dto.MetricFamily, now time.Time) []Sample {
+	var result []Sample
+	for _, family := range families {
+		samples.Visit(family, func(metric *dto.Metric, name string, value float64, extra ...samples.Label) {
+			timestamp := now
+			if metric.TimestampMs != nil {
+				timestamp = time.UnixMilli(metric.GetTimestampMs())
+			}
+			labels := make([]Label, 0, len(metric.GetLabel())+len(extra))
+			for _, pair := range metric.GetLabel() {
+				labels = append(labels, Label{pair.GetName(), pair.GetValue()})
+			}
+			for _, l := range extra {
+				labels = append(labels, Label{l.Name, l.Value})
+			}
+			sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+			result = append(result, Sample{Name: name, Labels: labels, Value: value, Timestamp: timestamp})
+		})
+	}
+	return result
+}
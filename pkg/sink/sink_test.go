@@ -0,0 +1,227 @@
+package sink
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+)
+
+var testNow = time.Unix(1700000000, 0)
+
+func testRegistry() *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	credits := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "atlas_exporter_credits", Help: "Credits"}, []string{"account"})
+	credits.WithLabelValues("team a").Set(500)
+	probes := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "atlas_exporter_probe_last_connected", Help: "Last connected"}, []string{"account", "probe_id"})
+	probes.WithLabelValues("team a", "1001").Set(1699999000)
+	probes.WithLabelValues("team a", "1002").Set(math.NaN())
+	reg.MustRegister(credits, probes)
+	return reg
+}
+
+func testLogger() logrus.FieldLogger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func TestInfluxDB(t *testing.T) {
+	var mu sync.Mutex
+	var requests []*http.Request
+	var bodies []string
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, r)
+		bodies = append(bodies, string(body))
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	influx, err := NewInfluxDB(server.URL+"/api/v2/write?org=atlas&bucket=metrics",
+		WithToken("secret"),
+		WithPrefix("ripe_"),
+		WithTags(map[string]string{"site": "ams"}),
+		WithTagMapping(map[string]string{"account": "atlas_account"}),
+	)
+	if err != nil {
+		t.Fatalf("NewInfluxDB failed: %v", err)
+	}
+	runner := NewRunner(testRegistry(), testLogger())
+	if err = runner.Send(t.Context(), influx, testNow); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(requests))
+	}
+	req := requests[0]
+	if req.URL.Path != "/api/v2/write" || req.URL.Query().Get("bucket") != "metrics" || req.URL.Query().Get("precision") != "ns" {
+		t.Errorf("unexpected write URL %s", req.URL)
+	}
+	if auth := req.Header.Get("Authorization"); auth != "Token secret" {
+		t.Errorf("expected token authentication, got %q", auth)
+	}
+	expected := `ripe_atlas_exporter_credits,atlas_account=team\ a,site=ams value=500 1700000000000000000
+ripe_atlas_exporter_probe_last_connected,atlas_account=team\ a,probe_id=1001,site=ams value=1.699999e+09 1700000000000000000
+`
+	if bodies[0] != expected {
+		t.Errorf("unexpected line protocol:\n%s\nexpected:\n%s", bodies[0], expected)
+	}
+	if sent := testutil.ToFloat64(runner.sentSamples.WithLabelValues("influxdb")); sent != 3 {
+		t.Errorf("expected 3 sent samples, got %v", sent)
+	}
+
+	status = http.StatusUnauthorized
+	if err = runner.Send(t.Context(), influx, testNow); err == nil || !strings.Contains(err.Error(), "status 401") {
+		t.Errorf("expected an error with status 401, got %v", err)
+	}
+	if failed := testutil.ToFloat64(runner.failedSends.WithLabelValues("influxdb")); failed != 1 {
+		t.Errorf("expected 1 failed send, got %v", failed)
+	}
+}
+
+func TestInfluxDBLineBreaks(t *testing.T) {
+	influx, err := NewInfluxDB("http://influxdb:8086/write?db=atlas")
+	if err != nil {
+		t.Fatalf("NewInfluxDB failed: %v", err)
+	}
+	body := influx.encode([]Sample{{
+		Name:      "atlas_exporter_measurement_info",
+		Labels:    []Label{{Name: "description", Value: "first line\r\nsecond line"}},
+		Value:     1,
+		Timestamp: testNow,
+	}})
+	expected := `atlas_exporter_measurement_info,description=first\ line\ \ second\ line value=1 1700000000000000000
+`
+	if string(body) != expected {
+		t.Errorf("unexpected line protocol:\n%s\nexpected:\n%s", body, expected)
+	}
+}
+
+// startGraphite starts a plaintext listener and returns its address and a
+// channel receiving the lines of every connection.
+func startGraphite(t *testing.T) (string, <-chan []string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	received := make(chan []string, 10)
+	go func() {
+		for {
+			conn, acceptErr := listener.Accept()
+			if acceptErr != nil {
+				return
+			}
+			var lines []string
+			scanner := bufio.NewScanner(conn)
+			for scanner.Scan() {
+				lines = append(lines, scanner.Text())
+			}
+			_ = conn.Close()
+			received <- lines
+		}
+	}()
+	return listener.Addr().String(), received
+}
+
+func TestGraphite(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		expected []string
+	}{
+		{
+			"path",
+			[]Option{WithPrefix("atlas."), WithTagMapping(map[string]string{"account": ""})},
+			[]string{
+				"atlas.atlas_exporter_credits 500 1700000000",
+				"atlas.atlas_exporter_probe_last_connected.probe_id.1001 1.699999e+09 1700000000",
+			},
+		},
+		{
+			"tagged",
+			[]Option{WithGraphiteTags(true), WithTags(map[string]string{"site": "ams"})},
+			[]string{
+				"atlas_exporter_credits;account=team_a;site=ams 500 1700000000",
+				"atlas_exporter_probe_last_connected;account=team_a;probe_id=1001;site=ams 1.699999e+09 1700000000",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address, received := startGraphite(t)
+			graphite, err := NewGraphite(address, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewGraphite failed: %v", err)
+			}
+			if err = NewRunner(testRegistry(), testLogger()).Send(t.Context(), graphite, testNow); err != nil {
+				t.Fatalf("Send failed: %v", err)
+			}
+			select {
+			case lines := <-received:
+				if strings.Join(lines, "\n") != strings.Join(tt.expected, "\n") {
+					t.Errorf("unexpected lines:\n%s\nexpected:\n%s", strings.Join(lines, "\n"), strings.Join(tt.expected, "\n"))
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("expected Graphite to receive metrics")
+			}
+		})
+	}
+}
+
+func TestGraphiteUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	address := listener.Addr().String()
+	_ = listener.Close()
+	graphite, err := NewGraphite(address, WithTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("NewGraphite failed: %v", err)
+	}
+	var opErr *net.OpError
+	if err = graphite.Send(t.Context(), nil); !errors.As(err, &opErr) {
+		t.Errorf("expected a connection error, got %v", err)
+	}
+}
+
+func TestSamples(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "duration_seconds", Help: "Duration", Buckets: []float64{1}})
+	histogram.Observe(0.5)
+	reg.MustRegister(histogram)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+	var got []string
+	for _, s := range Samples(families, testNow) {
+		labels := make([]string, 0, len(s.Labels))
+		for _, l := range s.Labels {
+			labels = append(labels, l.Name+"="+l.Value)
+		}
+		got = append(got, s.Name+"{"+strings.Join(labels, ",")+"}")
+	}
+	expected := "duration_seconds_bucket{le=1} duration_seconds_bucket{le=+Inf} duration_seconds_sum{} duration_seconds_count{}"
+	if strings.Join(got, " ") != expected {
+		t.Errorf("expected samples %s, got %s", expected, strings.Join(got, " "))
+	}
+}
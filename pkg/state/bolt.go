@@ -0,0 +1,329 @@
+package state
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	bolt "go.etcd.io/bbolt"
+	berrors "go.etcd.io/bbolt/errors"
+)
+
+// schemaVersion is the version of the bucket layout written by this version
+// of the exporter. Files with an older version are migrated by OpenBolt.
+const schemaVersion = 1
+
+// Top level buckets.
+var (
+	metaBucket    = []byte("meta")
+	entriesBucket = []byte("entries")
+	seriesBucket  = []byte("series")
+
+	schemaVersionKey = []byte("schema_version")
+)
+
+// migrations upgrade the file from version i to i+1.
+var migrations = []func(tx *bolt.Tx) error{
+	// 0 -> 1: the meta, entries and series buckets. Entries and series
+	// hold a bucket per namespace, series a bucket per series in it keyed
+	// by the big endian Unix nanoseconds of the records.
+	func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{metaBucket, entriesBucket, seriesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+var stateSizeDesc = prometheus.NewDesc(
+	"atlas_exporter_state_size_bytes",
+	"Size of the state file in bytes",
+	nil,
+	nil,
+)
+
+// Bolt is a Store in a bbolt file. Only one process can open the file.
+type Bolt struct {
+	path      string
+	retention Retention
+	// mu guards db, which is replaced by Compact.
+	mu sync.RWMutex
+	db *bolt.DB
+
+	pruned         prometheus.Counter
+	lastCompaction prometheus.Gauge
+}
+
+// OpenBolt opens the state file at path, creating it if needed, and migrates
+// it to the current schema. Files written by a newer version are rejected.
+func OpenBolt(path string, retention Retention) (*Bolt, error) {
+	db, err := openBoltDB(path)
+	if err != nil {
+		return nil, err
+	}
+	if err = migrate(db); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &Bolt{
+		path:      path,
+		retention: retention,
+		db:        db,
+		pruned: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "atlas_exporter_state_pruned_records_total",
+			Help: "Number of records deleted from the state file by retention",
+		}),
+		lastCompaction: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "atlas_exporter_state_last_compaction_timestamp_seconds",
+			Help: "Time (Unix timestamp) of the last successful compaction of the state file",
+		}),
+	}, nil
+}
+
+func openBoltDB(path string) (*bolt.DB, error) {
+	// The timeout fails fast when another process holds the file lock.
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if errors.Is(err, berrors.ErrTimeout) {
+		err = ErrLocked
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state file %s: %w", path, err)
+	}
+	return db, nil
+}
+
+func migrate(db *bolt.DB) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		version := 0
+		if meta := tx.Bucket(metaBucket); meta != nil {
+			if value := meta.Get(schemaVersionKey); len(value) == 8 {
+				version = int(binary.BigEndian.Uint64(value))
+			}
+		}
+		if version > schemaVersion {
+			return fmt.Errorf("state file has schema version %d, this version of the exporter supports up to %d", version, schemaVersion)
+		}
+		for ; version < schemaVersion; version++ {
+			if err := migrations[version](tx); err != nil {
+				return fmt.Errorf("failed to migrate state file to schema version %d: %w", version+1, err)
+			}
+		}
+		return tx.Bucket(metaBucket).Put(schemaVersionKey, binary.BigEndian.AppendUint64(nil, schemaVersion))
+	})
+}
+
+func (b *Bolt) view(fn func(tx *bolt.Tx) error) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.db == nil {
+		return ErrClosed
+	}
+	return b.db.View(fn)
+}
+
+func (b *Bolt) update(fn func(tx *bolt.Tx) error) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.db == nil {
+		return ErrClosed
+	}
+	return b.db.Update(fn)
+}
+
+func (b *Bolt) Get(namespace, key string) ([]byte, error) {
+	var value []byte
+	err := b.view(func(tx *bolt.Tx) error {
+		if bucket := tx.Bucket(entriesBucket).Bucket([]byte(namespace)); bucket != nil {
+			value = bytes.Clone(bucket.Get([]byte(key)))
+		}
+		return nil
+	})
+	if err == nil && value == nil {
+		err = ErrNotFound
+	}
+	return value, err
+}
+
+func (b *Bolt) Put(namespace, key string, value []byte) error {
+	return b.update(func(tx *bolt.Tx) error {
+		bucket, err := tx.Bucket(entriesBucket).CreateBucketIfNotExists([]byte(namespace))
+		if err != nil {
+			return err
+		}
+		// A nil value would read back as a missing key.
+		return bucket.Put([]byte(key), append([]byte{}, value...))
+	})
+}
+
+func (b *Bolt) Delete(namespace, key string) error {
+	return b.update(func(tx *bolt.Tx) error {
+		if bucket := tx.Bucket(entriesBucket).Bucket([]byte(namespace)); bucket != nil {
+			return bucket.Delete([]byte(key))
+		}
+		return nil
+	})
+}
+
+func (b *Bolt) Append(namespace, series string, t time.Time, value []byte) error {
+	return b.update(func(tx *bolt.Tx) error {
+		bucket, err := tx.Bucket(seriesBucket).CreateBucketIfNotExists([]byte(namespace))
+		if err != nil {
+			return err
+		}
+		if bucket, err = bucket.CreateBucketIfNotExists([]byte(series)); err != nil {
+			return err
+		}
+		return bucket.Put(timeKey(t), append([]byte{}, value...))
+	})
+}
+
+func (b *Bolt) Range(namespace, series string, from, to time.Time) ([]Record, error) {
+	var records []Record
+	err := b.view(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(seriesBucket).Bucket([]byte(namespace))
+		if bucket == nil {
+			return nil
+		}
+		if bucket = bucket.Bucket([]byte(series)); bucket == nil {
+			return nil
+		}
+		end := timeKey(to)
+		cursor := bucket.Cursor()
+		for key, value := cursor.Seek(timeKey(from)); key != nil && bytes.Compare(key, end) <= 0; key, value = cursor.Next() {
+			records = append(records, Record{Time: keyTime(key), Value: bytes.Clone(value)})
+		}
+		return nil
+	})
+	return records, err
+}
+
+func (b *Bolt) Prune(now time.Time) (int, error) {
+	pruned := 0
+	err := b.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(seriesBucket).ForEachBucket(func(namespace []byte) error {
+			cutoff, ok := b.retention.cutoff(string(namespace), now)
+			if !ok {
+				return nil
+			}
+			namespaceBucket := tx.Bucket(seriesBucket).Bucket(namespace)
+			end := timeKey(cutoff)
+			var empty [][]byte
+			err := namespaceBucket.ForEachBucket(func(series []byte) error {
+				bucket := namespaceBucket.Bucket(series)
+				cursor := bucket.Cursor()
+				for key, _ := cursor.First(); key != nil && bytes.Compare(key, end) < 0; key, _ = cursor.First() {
+					if err := cursor.Delete(); err != nil {
+						return err
+					}
+					pruned++
+				}
+				if key, _ := cursor.First(); key == nil {
+					empty = append(empty, bytes.Clone(series))
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			for _, series := range empty {
+				if err = namespaceBucket.DeleteBucket(series); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune state file: %w", err)
+	}
+	b.pruned.Add(float64(pruned))
+	return pruned, nil
+}
+
+// Compact rewrites the state file without the space of deleted data, which
+// bbolt otherwise keeps for reuse. The store is blocked while compacting. If
+// the file cannot be reopened once the old handle is closed, the error is
+// returned and the store behaves as closed.
+func (b *Bolt) Compact() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.db == nil {
+		return ErrClosed
+	}
+	tmpPath := b.path + ".compact"
+	// A compaction interrupted by a crash leaves the file behind, and
+	// bolt.Compact fails to create the buckets it already holds.
+	if err := os.Remove(tmpPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove stale compacted state file: %w", err)
+	}
+	dst, err := bolt.Open(tmpPath, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return fmt.Errorf("failed to create compacted state file: %w", err)
+	}
+	if err = bolt.Compact(dst, b.db, 64<<20); err != nil {
+		_ = dst.Close()
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to compact state file: %w", err)
+	}
+	if err = dst.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to compact state file: %w", err)
+	}
+	if err = b.db.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		var openErr error
+		b.db, openErr = openBoltDB(b.path)
+		return errors.Join(fmt.Errorf("failed to compact state file: %w", err), openErr)
+	}
+	renameErr := os.Rename(tmpPath, b.path)
+	if renameErr != nil {
+		_ = os.Remove(tmpPath)
+		renameErr = fmt.Errorf("failed to replace state file: %w", renameErr)
+	}
+	b.db, err = openBoltDB(b.path)
+	if err = errors.Join(renameErr, err); err != nil {
+		return err
+	}
+	b.lastCompaction.SetToCurrentTime()
+	return nil
+}
+
+func (b *Bolt) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.db == nil {
+		return nil
+	}
+	err := b.db.Close()
+	b.db = nil
+	return err
+}
+
+func (b *Bolt) Describe(ch chan<- *prometheus.Desc) {
+	ch <- stateSizeDesc
+	b.pruned.Describe(ch)
+	b.lastCompaction.Describe(ch)
+}
+
+func (b *Bolt) Collect(ch chan<- prometheus.Metric) {
+	if info, err := os.Stat(b.path); err == nil {
+		ch <- prometheus.MustNewConstMetric(stateSizeDesc, prometheus.GaugeValue, float64(info.Size()))
+	}
+	ch <- b.pruned
+	ch <- b.lastCompaction
+}
+
+func timeKey(t time.Time) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(t.UnixNano()))
+}
+
+func keyTime(key []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(key)))
+}
@@ -0,0 +1,132 @@
+package state
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Memory is a Store keeping everything in memory, for when no state file is
+// configured. Its content is lost on restart.
+type Memory struct {
+	mu        sync.RWMutex
+	retention Retention
+	closed    bool
+	entries   map[string]map[string][]byte
+	series    map[string]map[string][]Record
+}
+
+// NewMemory creates an empty Memory store.
+func NewMemory(retention Retention) *Memory {
+	return &Memory{
+		retention: retention,
+		entries:   map[string]map[string][]byte{},
+		series:    map[string]map[string][]Record{},
+	}
+}
+
+func (m *Memory) Get(namespace, key string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.closed {
+		return nil, ErrClosed
+	}
+	value, ok := m.entries[namespace][key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), value...), nil
+}
+
+func (m *Memory) Put(namespace, key string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+	if m.entries[namespace] == nil {
+		m.entries[namespace] = map[string][]byte{}
+	}
+	m.entries[namespace][key] = append([]byte(nil), value...)
+	return nil
+}
+
+func (m *Memory) Delete(namespace, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+	delete(m.entries[namespace], key)
+	return nil
+}
+
+func (m *Memory) Append(namespace, series string, t time.Time, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+	if m.series[namespace] == nil {
+		m.series[namespace] = map[string][]Record{}
+	}
+	records := m.series[namespace][series]
+	i := sort.Search(len(records), func(i int) bool { return !records[i].Time.Before(t) })
+	record := Record{Time: t, Value: append([]byte(nil), value...)}
+	if i < len(records) && records[i].Time.Equal(t) {
+		records[i] = record
+	} else {
+		records = append(records, Record{})
+		copy(records[i+1:], records[i:])
+		records[i] = record
+	}
+	m.series[namespace][series] = records
+	return nil
+}
+
+func (m *Memory) Range(namespace, series string, from, to time.Time) ([]Record, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.closed {
+		return nil, ErrClosed
+	}
+	var result []Record
+	for _, record := range m.series[namespace][series] {
+		if !record.Time.Before(from) && !record.Time.After(to) {
+			result = append(result, Record{Time: record.Time, Value: append([]byte(nil), record.Value...)})
+		}
+	}
+	return result, nil
+}
+
+func (m *Memory) Prune(now time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return 0, ErrClosed
+	}
+	pruned := 0
+	for namespace, series := range m.series {
+		cutoff, ok := m.retention.cutoff(namespace, now)
+		if !ok {
+			continue
+		}
+		for name, records := range series {
+			i := sort.Search(len(records), func(i int) bool { return !records[i].Time.Before(cutoff) })
+			pruned += i
+			if i == len(records) {
+				delete(series, name)
+				continue
+			}
+			series[name] = records[i:]
+		}
+	}
+	return pruned, nil
+}
+
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	return nil
+}
@@ -0,0 +1,160 @@
+// Package state persists data derived by the exporter, such as previous
+// probe statuses or the credit history, so it survives restarts.
+//
+// A Store holds namespaces, usually one per feature and account. Every
+// namespace has key/value entries holding the latest state of something, and
+// time series of records which are deleted once they are older than the
+// retention of the namespace.
+package state
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrNotFound is returned by Get when the key has no value.
+var ErrNotFound = errors.New("not found")
+
+// ErrClosed is returned when the store is used after Close.
+var ErrClosed = errors.New("state store is closed")
+
+// ErrLocked is returned by OpenBolt when another process holds the file.
+var ErrLocked = errors.New("state file is locked by another process")
+
+// Record is a value of a time series.
+type Record struct {
+	Time  time.Time
+	Value []byte
+}
+
+// Store persists key/value entries and time series by namespace. It is safe
+// for concurrent use.
+type Store interface {
+	// Get returns the value of key, or ErrNotFound.
+	Get(namespace, key string) ([]byte, error)
+	// Put sets the value of key.
+	Put(namespace, key string, value []byte) error
+	// Delete removes key. Deleting a missing key is not an error.
+	Delete(namespace, key string) error
+	// Append adds a record to series. A record with the same time is replaced.
+	Append(namespace, series string, t time.Time, value []byte) error
+	// Range returns the records of series from from to to inclusive, oldest
+	// first.
+	Range(namespace, series string, from, to time.Time) ([]Record, error)
+	// Prune deletes the records older than the retention of their namespace
+	// at now and returns how many were deleted.
+	Prune(now time.Time) (int, error)
+	// Close releases the store.
+	Close() error
+}
+
+// Compactor is implemented by stores that can reclaim the space of deleted
+// data.
+type Compactor interface {
+	Compact() error
+}
+
+// Retention is how long the records of time series are kept. Namespaces are
+// matched on their full name first, then on the part after the last "/", so
+// a policy for "credits" applies to the credits namespace of every account.
+// A zero duration keeps records forever.
+type Retention struct {
+	Default    time.Duration
+	Namespaces map[string]time.Duration
+}
+
+// of returns the retention of namespace.
+func (r Retention) of(namespace string) time.Duration {
+	if retention, ok := r.Namespaces[namespace]; ok {
+		return retention
+	}
+	if i := strings.LastIndex(namespace, "/"); i >= 0 {
+		if retention, ok := r.Namespaces[namespace[i+1:]]; ok {
+			return retention
+		}
+	}
+	return r.Default
+}
+
+// cutoff returns the time before which the records of namespace are pruned
+// at now, and false when they are kept forever.
+func (r Retention) cutoff(namespace string, now time.Time) (time.Time, bool) {
+	retention := r.of(namespace)
+	if retention <= 0 {
+		return time.Time{}, false
+	}
+	return now.Add(-retention), true
+}
+
+// namespaced is a Store whose namespaces are prefixed.
+type namespaced struct {
+	store  Store
+	prefix string
+}
+
+// Namespaced returns a view of store where every namespace is prefixed with
+// prefix and "/", e.g. to separate the state of accounts. Closing the view
+// does not close store.
+func Namespaced(store Store, prefix string) Store {
+	return &namespaced{store: store, prefix: prefix + "/"}
+}
+
+func (n *namespaced) Get(namespace, key string) ([]byte, error) {
+	return n.store.Get(n.prefix+namespace, key)
+}
+
+func (n *namespaced) Put(namespace, key string, value []byte) error {
+	return n.store.Put(n.prefix+namespace, key, value)
+}
+
+func (n *namespaced) Delete(namespace, key string) error {
+	return n.store.Delete(n.prefix+namespace, key)
+}
+
+func (n *namespaced) Append(namespace, series string, t time.Time, value []byte) error {
+	return n.store.Append(n.prefix+namespace, series, t, value)
+}
+
+func (n *namespaced) Range(namespace, series string, from, to time.Time) ([]Record, error) {
+	return n.store.Range(n.prefix+namespace, series, from, to)
+}
+
+func (n *namespaced) Prune(now time.Time) (int, error) {
+	return n.store.Prune(now)
+}
+
+func (n *namespaced) Close() error {
+	return nil
+}
+
+// Maintain prunes store every interval until ctx is cancelled. After a prune
+// that deleted records, the store is compacted when it is a Compactor.
+func Maintain(ctx context.Context, store Store, interval time.Duration, logger logrus.FieldLogger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		pruned, err := store.Prune(time.Now())
+		if err != nil {
+			logger.WithError(err).Error("Failed to prune state store")
+			continue
+		}
+		logger.Debugf("Pruned %d records from the state store", pruned)
+		if pruned == 0 {
+			continue
+		}
+		if compactor, ok := store.(Compactor); ok {
+			if err = compactor.Compact(); err != nil {
+				logger.WithError(err).Error("Failed to compact state store")
+			}
+		}
+	}
+}
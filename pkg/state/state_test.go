@@ -0,0 +1,287 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+var testNow = time.Unix(1700000000, 0)
+
+func openStores(t *testing.T, retention Retention) map[string]Store {
+	t.Helper()
+	boltStore, err := OpenBolt(filepath.Join(t.TempDir(), "state.db"), retention)
+	if err != nil {
+		t.Fatalf("OpenBolt failed: %v", err)
+	}
+	t.Cleanup(func() { _ = boltStore.Close() })
+	return map[string]Store{
+		"memory": NewMemory(retention),
+		"bolt":   boltStore,
+	}
+}
+
+func recordTimes(records []Record) []int64 {
+	times := make([]int64, 0, len(records))
+	for _, record := range records {
+		times = append(times, record.Time.Unix()-testNow.Unix())
+	}
+	return times
+}
+
+func TestEntries(t *testing.T) {
+	for name, store := range openStores(t, Retention{}) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.Get("probes", "1001"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("expected ErrNotFound, got %v", err)
+			}
+			if err := store.Put("probes", "1001", []byte("Connected")); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+			if err := store.Put("probes", "1002", nil); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+			if value, err := store.Get("probes", "1001"); err != nil || string(value) != "Connected" {
+				t.Errorf("expected Connected, got %q, %v", value, err)
+			}
+			if value, err := store.Get("probes", "1002"); err != nil || len(value) != 0 {
+				t.Errorf("expected an empty value, got %q, %v", value, err)
+			}
+			if _, err := store.Get("other", "1001"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("expected namespaces to be separate, got %v", err)
+			}
+			if err := store.Delete("probes", "1001"); err != nil {
+				t.Fatalf("Delete failed: %v", err)
+			}
+			if err := store.Delete("missing", "1001"); err != nil {
+				t.Errorf("expected deleting a missing key to succeed, got %v", err)
+			}
+			if _, err := store.Get("probes", "1001"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("expected ErrNotFound after Delete, got %v", err)
+			}
+		})
+	}
+}
+
+func TestSeries(t *testing.T) {
+	retention := Retention{
+		Default:    time.Hour,
+		Namespaces: map[string]time.Duration{"credits": 10 * time.Hour, "a/events": 0},
+	}
+	for name, store := range openStores(t, retention) {
+		t.Run(name, func(t *testing.T) {
+			offsets := []time.Duration{-5 * time.Hour, -2 * time.Hour, 0, -30 * time.Minute}
+			for _, namespace := range []string{"a/credits", "a/probes", "a/events"} {
+				for _, offset := range offsets {
+					if err := store.Append(namespace, "balance", testNow.Add(offset), []byte(offset.String())); err != nil {
+						t.Fatalf("Append failed: %v", err)
+					}
+				}
+			}
+			if err := store.Append("a/credits", "balance", testNow, []byte("replaced")); err != nil {
+				t.Fatalf("Append failed: %v", err)
+			}
+
+			records, err := store.Range("a/credits", "balance", testNow.Add(-2*time.Hour), testNow)
+			if err != nil {
+				t.Fatalf("Range failed: %v", err)
+			}
+			if got := recordTimes(records); len(got) != 3 || got[0] != -7200 || got[1] != -1800 || got[2] != 0 {
+				t.Errorf("expected records at -7200, -1800 and 0, got %v", got)
+			}
+			if string(records[2].Value) != "replaced" {
+				t.Errorf("expected the record at the same time to be replaced, got %q", records[2].Value)
+			}
+			if records, _ = store.Range("a/credits", "missing", testNow.Add(-time.Hour), testNow); len(records) != 0 {
+				t.Errorf("expected no records for a missing series, got %d", len(records))
+			}
+
+			pruned, err := store.Prune(testNow)
+			if err != nil {
+				t.Fatalf("Prune failed: %v", err)
+			}
+			// a/probes keeps 1h and loses 2 records, a/credits keeps 10h and
+			// a/events forever.
+			if pruned != 2 {
+				t.Errorf("expected 2 pruned records, got %d", pruned)
+			}
+			for namespace, expected := range map[string]int{"a/credits": 4, "a/probes": 2, "a/events": 4} {
+				records, _ = store.Range(namespace, "balance", testNow.Add(-24*time.Hour), testNow)
+				if len(records) != expected {
+					t.Errorf("expected %d records in %s, got %d", expected, namespace, len(records))
+				}
+			}
+		})
+	}
+}
+
+func TestNamespaced(t *testing.T) {
+	store := NewMemory(Retention{})
+	account := Namespaced(store, "team")
+	if err := account.Put("probes", "1001", []byte("Connected")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := account.Append("credits", "balance", testNow, []byte("1")); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	if value, err := store.Get("team/probes", "1001"); err != nil || string(value) != "Connected" {
+		t.Errorf("expected the entry in team/probes, got %q, %v", value, err)
+	}
+	if records, _ := store.Range("team/credits", "balance", testNow, testNow); len(records) != 1 {
+		t.Errorf("expected the record in team/credits, got %d records", len(records))
+	}
+	if err := account.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := store.Get("team/probes", "1001"); err != nil {
+		t.Errorf("expected closing the view to keep the store open, got %v", err)
+	}
+}
+
+func TestBoltPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+	store, err := OpenBolt(path, Retention{})
+	if err != nil {
+		t.Fatalf("OpenBolt failed: %v", err)
+	}
+	if err = store.Put("probes", "1001", []byte("Connected")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err = store.Append("credits", "balance", testNow, []byte("500")); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	if _, err = OpenBolt(path, Retention{}); !errors.Is(err, ErrLocked) {
+		t.Errorf("expected ErrLocked, got %v", err)
+	}
+	if err = store.Compact(); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	if testutil.ToFloat64(store.lastCompaction) == 0 {
+		t.Error("expected the last compaction time to be set")
+	}
+	if value, err := store.Get("probes", "1001"); err != nil || string(value) != "Connected" {
+		t.Errorf("expected the entry to survive compaction, got %q, %v", value, err)
+	}
+	if err = store.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err = store.Get("probes", "1001"); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+
+	store, err = OpenBolt(path, Retention{})
+	if err != nil {
+		t.Fatalf("OpenBolt failed: %v", err)
+	}
+	defer store.Close()
+	if value, err := store.Get("probes", "1001"); err != nil || string(value) != "Connected" {
+		t.Errorf("expected the entry to survive a restart, got %q, %v", value, err)
+	}
+	if records, _ := store.Range("credits", "balance", testNow, testNow); len(records) != 1 || string(records[0].Value) != "500" {
+		t.Errorf("expected the record to survive a restart, got %v", records)
+	}
+}
+
+func TestBoltCompactStaleFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+	// A compaction interrupted before the rename leaves a file with buckets.
+	stale, err := OpenBolt(path+".compact", Retention{})
+	if err != nil {
+		t.Fatalf("OpenBolt failed: %v", err)
+	}
+	if err = stale.Put("probes", "1001", []byte("Abandoned")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err = stale.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	store, err := OpenBolt(path, Retention{})
+	if err != nil {
+		t.Fatalf("OpenBolt failed: %v", err)
+	}
+	defer store.Close()
+	if err = store.Put("probes", "1001", []byte("Connected")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err = store.Compact(); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	if value, err := store.Get("probes", "1001"); err != nil || string(value) != "Connected" {
+		t.Errorf("expected the entry of the store to survive compaction, got %q, %v", value, err)
+	}
+	if _, err = os.Stat(path + ".compact"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the compacted file to be renamed, got %v", err)
+	}
+}
+
+func TestBoltNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucket(metaBucket)
+		if err != nil {
+			return err
+		}
+		return meta.Put(schemaVersionKey, []byte{0, 0, 0, 0, 0, 0, 0, schemaVersion + 1})
+	})
+	if err != nil {
+		t.Fatalf("Failed to write schema version: %v", err)
+	}
+	_ = db.Close()
+	if _, err = OpenBolt(path, Retention{}); err == nil || !strings.Contains(err.Error(), "schema version 2") {
+		t.Errorf("expected a schema version error, got %v", err)
+	}
+}
+
+// compactCounter counts the compactions of a Store.
+type compactCounter struct {
+	Store
+	compactions atomic.Int32
+}
+
+func (c *compactCounter) Compact() error {
+	c.compactions.Add(1)
+	return nil
+}
+
+func TestMaintain(t *testing.T) {
+	store := &compactCounter{Store: NewMemory(Retention{Default: time.Hour})}
+	if err := store.Append("credits", "balance", time.Now(), []byte("500")); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		Maintain(ctx, store, 10*time.Millisecond, logrus.New())
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if compactions := store.compactions.Load(); compactions != 0 {
+		t.Errorf("expected no compaction without pruned records, got %d", compactions)
+	}
+	if err := store.Append("credits", "balance", time.Now().Add(-2*time.Hour), []byte("600")); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for store.compactions.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+	if compactions := store.compactions.Load(); compactions != 1 {
+		t.Errorf("expected one compaction after pruning, got %d", compactions)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/exporter"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"github.com/urfave/cli/v3"
+)
+
+// Exit codes of the push command and of the textfile command with --once.
+const (
+	exitOutputFailed     = 1
+	exitCollectorsFailed = 2
+)
+
+func pushCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "push",
+		Usage: "Run every enabled collector once and push the metrics to a Pushgateway, then exit",
+		Description: "Exits with status 1 if the configuration is invalid or the push failed, " +
+			"and with status 2 if the metrics were pushed but at least one collector failed.",
+		Action: Push,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "pushgateway_url",
+				Usage:    "URL of the Pushgateway",
+				Required: true,
+				Sources:  cli.EnvVars("ATLAS_EXPORTER_PUSHGATEWAY_URL"),
+			},
+			&cli.StringFlag{
+				Name:    "job",
+				Usage:   "Value of the job label of the pushed metrics",
+				Value:   "atlas_exporter",
+				Sources: cli.EnvVars("ATLAS_EXPORTER_PUSH_JOB"),
+			},
+			&cli.StringMapFlag{
+				Name:    "grouping",
+				Usage:   "Grouping labels of the pushed metrics, e.g. instance=edge-1,site=ams",
+				Sources: cli.EnvVars("ATLAS_EXPORTER_PUSH_GROUPING"),
+			},
+			&cli.StringFlag{
+				Name:    "pushgateway_username",
+				Usage:   "Username for basic authentication with the Pushgateway",
+				Sources: cli.EnvVars("ATLAS_EXPORTER_PUSHGATEWAY_USERNAME"),
+			},
+			&cli.StringFlag{
+				Name:    "pushgateway_password",
+				Usage:   "Password for basic authentication with the Pushgateway",
+				Sources: cli.EnvVars("ATLAS_EXPORTER_PUSHGATEWAY_PASSWORD"),
+			},
+			&cli.DurationFlag{
+				Name:    "push_timeout",
+				Usage:   "Timeout of the request to the Pushgateway",
+				Value:   30 * time.Second,
+				Sources: cli.EnvVars("ATLAS_EXPORTER_PUSH_TIMEOUT"),
+			},
+		},
+	}
+}
+
+// Push runs every enabled collector of every account once and pushes the
+// metrics to a Pushgateway, replacing the metrics of the job and grouping.
+func Push(ctx context.Context, c *cli.Command) error {
+	reloader := NewReloader(ctx, c)
+	if err := reloader.Load(); err != nil {
+		return err
+	}
+	defer reloader.Stop()
+	refreshErr := reloader.Refresh(ctx, "", "")
+	if errors.Is(refreshErr, exporter.ErrUnknownCollector) {
+		// Every collector is disabled.
+		refreshErr = nil
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(atlasCollectors(reloader)...)
+	pusher := push.New(c.String("pushgateway_url"), c.String("job")).
+		Gatherer(registry).
+		Client(&http.Client{Timeout: c.Duration("push_timeout")})
+	for name, value := range c.StringMap("grouping") {
+		pusher = pusher.Grouping(name, value)
+	}
+	if username := c.String("pushgateway_username"); username != "" {
+		pusher = pusher.BasicAuth(username, c.String("pushgateway_password"))
+	}
+	if err := pusher.PushContext(ctx); err != nil {
+		return cli.Exit(fmt.Sprintf("failed to push metrics: %v", err), exitOutputFailed)
+	}
+	if refreshErr != nil {
+		return cli.Exit(fmt.Sprintf("pushed metrics, but collectors failed: %v", refreshErr), exitCollectorsFailed)
+	}
+	logger.Infof("Pushed metrics to %s", c.String("pushgateway_url"))
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/urfave/cli/v3"
+)
+
+// pushRequest is a request received by the fake Pushgateway.
+type pushRequest struct {
+	method   string
+	path     string
+	username string
+	password string
+	body     string
+}
+
+func TestPush(t *testing.T) {
+	tests := []struct {
+		name          string
+		creditsStatus int
+		gatewayStatus int
+		exitCode      int
+	}{
+		{"success", http.StatusOK, http.StatusOK, 0},
+		{"collector failure", http.StatusInternalServerError, http.StatusOK, exitCollectorsFailed},
+		{"push failure", http.StatusOK, http.StatusBadGateway, exitOutputFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atlasMux := http.NewServeMux()
+			atlasMux.HandleFunc("/credits", func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.creditsStatus)
+				_, _ = w.Write([]byte(`{"current_balance": 500}`))
+			})
+			atlasServer := httptest.NewServer(atlasMux)
+			t.Cleanup(atlasServer.Close)
+
+			var mu sync.Mutex
+			var requests []pushRequest
+			gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				username, password, _ := r.BasicAuth()
+				mu.Lock()
+				requests = append(requests, pushRequest{r.Method, r.URL.Path, username, password, string(body)})
+				mu.Unlock()
+				w.WriteHeader(tt.gatewayStatus)
+			}))
+			t.Cleanup(gateway.Close)
+
+			app := buildApp()
+			app.ExitErrHandler = func(context.Context, *cli.Command, error) {}
+			args := []string{"atlas_exporter", "--api_token", "test-token", "--base_url", atlasServer.URL}
+			for _, name := range []string{"probe_last_connected", "probe_measurements", "measurement_metadata"} {
+				args = append(args, "--no-collector."+name)
+			}
+			args = append(args, "push",
+				"--pushgateway_url", gateway.URL,
+				"--grouping", "instance=edge-1",
+				"--pushgateway_username", "pusher",
+				"--pushgateway_password", "secret",
+			)
+			err := app.Run(t.Context(), args)
+			exitCode := 0
+			var exitErr cli.ExitCoder
+			if errors.As(err, &exitErr) {
+				exitCode = exitErr.ExitCode()
+			} else if err != nil {
+				t.Fatalf("Failed to run app: %v", err)
+			}
+			if exitCode != tt.exitCode {
+				t.Errorf("expected exit code %d, got %d (%v)", tt.exitCode, exitCode, err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(requests) != 1 {
+				t.Fatalf("expected 1 push, got %d", len(requests))
+			}
+			request := requests[0]
+			if request.method != http.MethodPut || request.path != "/metrics/job/atlas_exporter/instance/edge-1" {
+				t.Errorf("unexpected push %s %s", request.method, request.path)
+			}
+			if request.username != "pusher" || request.password != "secret" {
+				t.Errorf("expected basic auth credentials, got %q:%q", request.username, request.password)
+			}
+			for _, name := range []string{"atlas_exporter_build_info", "atlas_exporter_collector_success"} {
+				if !strings.Contains(request.body, name) {
+					t.Errorf("expected the pushed metrics to contain %s", name)
+				}
+			}
+			if tt.creditsStatus == http.StatusOK && !strings.Contains(request.body, "atlas_exporter_credits") {
+				t.Error("expected the pushed metrics to contain atlas_exporter_credits")
+			}
+		})
+	}
+}
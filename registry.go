@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/exporter"
+	"github.com/urfave/cli/v3"
+)
+
+// collectorFlags returns a --collector.<name>/--no-collector.<name> flag for
+// every available collector.
+func collectorFlags() []cli.Flag {
+	collectors := exporter.AvailableCollectors()
+	flags := make([]cli.Flag, len(collectors))
+	for i, collector := range collectors {
+		state := "disabled"
+		if collector.DefaultEnabled {
+			state = "enabled"
+		}
+		flags[i] = &cli.BoolWithInverseFlag{
+			Name:        "collector." + collector.Name,
+			Usage:       fmt.Sprintf("Enable the %s collector: %s (default: %s)", collector.Name, collector.Help, state),
+			Value:       collector.DefaultEnabled,
+			HideDefault: true,
+			Sources:     cli.EnvVars("ATLAS_EXPORTER_COLLECTOR_" + strings.ToUpper(collector.Name)),
+		}
+	}
+	return flags
+}
+
+// enabledCollectors returns the collectors enabled in settings, the collectors
+// block of an account merged with the top level one, or the default state of
+// each collector when settings does not enable or disable it. The highest
+// precedence goes to the --[no-]collector.<name> flags and their environment
+// variables, then to the collectors block of the account, then to the top
+// level collectors block, then to the default state.
+func (c *Config) enabledCollectors(settings *CollectorsConfig) []string {
+	var names []string
+	collectors := settings.byName()
+	for _, collector := range exporter.AvailableCollectors() {
+		enabled := collector.DefaultEnabled
+		if setting := collectors[collector.Name]; setting != nil && setting.Enabled != nil {
+			enabled = *setting.Enabled
+		}
+		if enabled {
+			names = append(names, collector.Name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCollectorEnabled(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		args     []string
+		expected string
+	}{
+		{"defaults", "api_token: x\n", nil, "credits,credit_forecast,probe_last_connected,probe_measurements,measurement_metadata,sslcert,http,ntp"},
+		{"disable flag", "api_token: x\n", []string{"--no-collector.probe_measurements", "--no-collector.measurement_metadata"}, "credits,credit_forecast,probe_last_connected,sslcert,http,ntp"},
+		{"config file", "api_token: x\ncollectors:\n  credits:\n    enabled: false\n", nil, "credit_forecast,probe_last_connected,probe_measurements,measurement_metadata,sslcert,http,ntp"},
+		{"flag overrides config file", "api_token: x\ncollectors:\n  credits:\n    enabled: false\n", []string{"--collector.credits"}, "credits,credit_forecast,probe_last_connected,probe_measurements,measurement_metadata,sslcert,http,ntp"},
+		{
+			"account overrides top level",
+			"collectors:\n  credits:\n    enabled: false\naccounts:\n  - name: a\n    api_token: x\n    collectors:\n      credits:\n        enabled: true\n      ntp:\n        enabled: false\n",
+			[]string{"--no-collector.probe_measurements"},
+			"credits,credit_forecast,probe_last_connected,measurement_metadata,sslcert,http",
+		},
+		{
+			"flag overrides account",
+			"accounts:\n  - name: a\n    api_token: x\n    collectors:\n      ntp:\n        enabled: true\n      http:\n        enabled: false\n",
+			[]string{"--no-collector.ntp", "--collector.http"},
+			"credits,credit_forecast,probe_last_connected,probe_measurements,measurement_metadata,sslcert,http",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := loadTestConfig(t, tt.content, tt.args...)
+			if err != nil {
+				t.Fatalf("LoadConfig failed: %v", err)
+			}
+			if got := strings.Join(cfg.enabledCollectors(cfg.accounts()[0].Collectors), ","); got != tt.expected {
+				t.Errorf("expected collectors %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/atlas"
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/exporter"
+	statepkg "github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/state"
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/version"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v3"
+)
+
+// ErrUnknownAccount is returned when a refresh names an account that is not configured.
+var ErrUnknownAccount = errors.New("unknown account")
+
+// exporterState is everything built from a single configuration. It is
+// replaced as a whole when the configuration is reloaded.
+type exporterState struct {
+	cfg      *Config
+	accounts []*accountState
+	stop     context.CancelFunc
+	// loadedAt is the time of the load and reloaded whether it replaced a
+	// previous configuration.
+	loadedAt time.Time
+	reloaded bool
+}
+
+// accountState holds the API client and collectors of one account. Every
+// metric of the account is labelled with its name by collector.
+type accountState struct {
+	name      string
+	config    AccountConfig
+	client    *atlas.API
+	exporter  *exporter.Exporter
+	collector prometheus.Collector
+	// tokenFile is the api_token_file of the account, if any.
+	tokenFile string
+}
+
+// collectorGroup collects several collectors as one.
+type collectorGroup []prometheus.Collector
+
+func (g collectorGroup) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range g {
+		c.Describe(ch)
+	}
+}
+
+func (g collectorGroup) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range g {
+		c.Collect(ch)
+	}
+}
+
+// Reloader owns the API clients and collectors of every account and rebuilds
+// them from the command flags and configuration file on Reload. It is an
+// unchecked prometheus.Collector exposing the metrics of the current collectors.
+type Reloader struct {
+	ctx     context.Context
+	cmd     *cli.Command
+	mu      sync.Mutex
+	current atomic.Pointer[exporterState]
+	// apiMetrics keeps the API request metrics of each account across reloads.
+	apiMetrics map[string]*atlas.Metrics
+	// store is opened by the first load and kept until Stop.
+	store statepkg.Store
+
+	lastReloadSuccessful       prometheus.Gauge
+	lastReloadSuccessTimestamp prometheus.Gauge
+}
+
+// NewReloader creates a Reloader. Nothing is loaded until the first call to
+// Reload. Refresh loops are stopped when ctx is cancelled.
+func NewReloader(ctx context.Context, cmd *cli.Command) *Reloader {
+	return &Reloader{
+		ctx:        ctx,
+		cmd:        cmd,
+		apiMetrics: map[string]*atlas.Metrics{},
+		lastReloadSuccessful: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "atlas_exporter_config_last_reload_successful",
+			Help: "Whether the last configuration reload attempt was successful (1 = success)",
+		}),
+		lastReloadSuccessTimestamp: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "atlas_exporter_config_last_reload_success_timestamp_seconds",
+			Help: "Time (Unix timestamp) of the last successful configuration reload",
+		}),
+	}
+}
+
+// Reload loads the configuration and, when it is valid, replaces the API
+// client and collectors. On failure the previous configuration stays active.
+func (r *Reloader) Reload() error {
+	return r.load(true, false)
+}
+
+// ReloadAndRefresh reloads the configuration like Reload, but first refreshes
+// every collector that did not keep its data from the previous configuration,
+// so the metrics are complete when it returns. A failed refresh is logged and
+// retried by the background refreshes.
+func (r *Reloader) ReloadAndRefresh() error {
+	return r.load(true, true)
+}
+
+// Load loads the configuration like Reload without starting the background
+// refreshes, for one-shot runs that call Refresh instead.
+func (r *Reloader) Load() error {
+	return r.load(false, false)
+}
+
+func (r *Reloader) load(start, refresh bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state, err := r.build()
+	if err != nil {
+		r.lastReloadSuccessful.Set(0)
+		return err
+	}
+	previous := r.current.Load()
+	if previous != nil {
+		warnRestartRequired(previous.cfg, state.cfg)
+		inheritSnapshots(previous, state)
+		state.reloaded = true
+	}
+	state.loadedAt = time.Now()
+	if err = SetLogLevel(state.cfg); err != nil {
+		logger.WithError(err).Error("Failed to set log level")
+	}
+	if refresh {
+		refreshPending(r.ctx, state)
+	}
+	pollerCtx, stop := context.WithCancel(r.ctx)
+	state.stop = stop
+	if start {
+		for _, account := range state.accounts {
+			account.exporter.Start(pollerCtx)
+			if account.tokenFile != "" {
+				go watchTokenFile(pollerCtx, account, tokenFileReloadInterval)
+			}
+		}
+	}
+	r.current.Store(state)
+	if previous != nil {
+		previous.stop()
+		logger.Info("Configuration reloaded")
+	}
+	r.lastReloadSuccessful.Set(1)
+	r.lastReloadSuccessTimestamp.SetToCurrentTime()
+	return nil
+}
+
+func (r *Reloader) build() (*exporterState, error) {
+	cfg, err := LoadConfig(r.cmd)
+	if err != nil {
+		return nil, err
+	}
+	debug := false
+	if level, levelErr := logrus.ParseLevel(cfg.LogLevel); levelErr == nil && level >= logrus.DebugLevel {
+		debug = true
+	}
+	if r.store == nil {
+		if r.store, err = cfg.State.open(); err != nil {
+			return nil, err
+		}
+	}
+	state := &exporterState{cfg: cfg}
+	for _, account := range cfg.accounts() {
+		apiMetrics, ok := r.apiMetrics[account.Name]
+		if !ok {
+			apiMetrics = atlas.NewMetrics("atlas_exporter")
+			r.apiMetrics[account.Name] = apiMetrics
+		}
+		client, clientErr := atlas.New(
+			atlas.WithUserAgent("go-atlas-stats-exporter/"+version.Version),
+			atlas.WithAPIToken(account.APIToken),
+			atlas.WithBaseURL(account.BaseURL),
+			atlas.WithMetrics(apiMetrics),
+			atlas.WithDebug(debug),
+		)
+		if clientErr != nil {
+			return nil, fmt.Errorf("failed to initialize Atlas API client for account %s: %w", account.Name, clientErr)
+		}
+		accountExporter, exporterErr := buildExporter(cfg, account, client, statepkg.Namespaced(r.store, account.Name))
+		if exporterErr != nil {
+			return nil, fmt.Errorf("failed to create exporter for account %s: %w", account.Name, exporterErr)
+		}
+		state.accounts = append(state.accounts, &accountState{
+			name:      account.Name,
+			config:    account,
+			client:    client,
+			exporter:  accountExporter,
+			collector: prometheus.WrapCollectorWith(prometheus.Labels{"account": account.Name}, collectorGroup{apiMetrics, accountExporter}),
+			tokenFile: account.APITokenFile,
+		})
+	}
+	return state, nil
+}
+
+// inheritSnapshots starts the collectors of next from the snapshots of the
+// same account in previous when their settings did not change, so the metrics
+// and readiness do not reset on every reload.
+func inheritSnapshots(previous, next *exporterState) {
+	for _, account := range next.accounts {
+		for _, old := range previous.accounts {
+			if old.name != account.name || !sameCredentials(old, account) || old.config.BaseURL != account.config.BaseURL {
+				continue
+			}
+			var unchanged []string
+			for _, name := range exporter.CollectorNames() {
+				if reflect.DeepEqual(old.config.Collectors.dataSettings(name), account.config.Collectors.dataSettings(name)) {
+					unchanged = append(unchanged, name)
+				}
+			}
+			account.exporter.Inherit(old.exporter, unchanged...)
+		}
+	}
+}
+
+// sameCredentials reports whether two accounts authenticate as the same key:
+// they read their token from the same file, which may have been rotated since,
+// or use the same token. The token of the client is compared, as the watcher
+// of the token file updates it without changing the configuration.
+func sameCredentials(old, next *accountState) bool {
+	if old.tokenFile != "" && old.tokenFile == next.tokenFile {
+		return true
+	}
+	return old.client.Token() == next.client.Token()
+}
+
+// refreshPending refreshes the collectors of state that have never succeeded.
+func refreshPending(ctx context.Context, state *exporterState) {
+	for _, account := range state.accounts {
+		for _, status := range account.exporter.Status() {
+			if status.LastSuccess.IsZero() {
+				// Errors are logged by the collector.
+				_ = account.exporter.Refresh(ctx, status.Name)
+			}
+		}
+	}
+}
+
+// warnRestartRequired logs the settings that only take effect after a restart.
+func warnRestartRequired(previous, next *Config) {
+	for _, setting := range []struct {
+		name    string
+		changed bool
+	}{
+		{"listen_address", previous.ListenAddress != next.ListenAddress},
+		{"metrics_path", previous.MetricsPath != next.MetricsPath},
+		{"tls_enabled", previous.TLSEnabled != next.TLSEnabled},
+		{"tls_cert_chain_path", previous.TLSCertChainPath != next.TLSCertChainPath},
+		{"tls_key_path", previous.TLSKeyPath != next.TLSKeyPath},
+		{"web_config_file", previous.WebConfigFile != next.WebConfigFile},
+		{"tls_server_config and http_server_config", !reflect.DeepEqual(previous.Web.serverSettings(), next.Web.serverSettings())},
+		{"remote_write", !reflect.DeepEqual(previous.RemoteWrite, next.RemoteWrite)},
+		{"otlp", !reflect.DeepEqual(previous.OTLP, next.OTLP)},
+		{"sinks", !reflect.DeepEqual(previous.Sinks, next.Sinks)},
+		{"state", !reflect.DeepEqual(previous.State, next.State)},
+		{"notifications", !reflect.DeepEqual(previous.Notifications, next.Notifications) ||
+			(next.Notifications.enabled() && !reflect.DeepEqual(previous.Rules, next.Rules))},
+	} {
+		if setting.changed {
+			logger.Warnf("Changes to %s require a restart to take effect", setting.name)
+		}
+	}
+}
+
+// Stop stops the refresh loops of the current collectors and closes the
+// state store.
+func (r *Reloader) Stop() {
+	if state := r.current.Load(); state != nil {
+		state.stop()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.store != nil {
+		if err := r.store.Close(); err != nil {
+			logger.WithError(err).Error("Failed to close state store")
+		}
+		r.store = nil
+	}
+}
+
+// Store returns the state store shared by every account. It is only set
+// after the first load.
+func (r *Reloader) Store() statepkg.Store {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.store
+}
+
+// Config returns the active configuration.
+func (r *Reloader) Config() *Config {
+	return r.current.Load().cfg
+}
+
+// Refresh immediately refreshes the named collector of the named account. An
+// empty name matches every account or collector.
+func (r *Reloader) Refresh(ctx context.Context, account, collector string) error {
+	var errs []error
+	foundAccount, foundCollector := false, false
+	for _, state := range r.current.Load().accounts {
+		if account != "" && state.name != account {
+			continue
+		}
+		foundAccount = true
+		err := state.exporter.Refresh(ctx, collector)
+		if errors.Is(err, exporter.ErrUnknownCollector) {
+			continue
+		}
+		foundCollector = true
+		if err != nil {
+			errs = append(errs, fmt.Errorf("account %s: %w", state.name, err))
+		}
+	}
+	switch {
+	case !foundAccount:
+		return fmt.Errorf("%w: %s", ErrUnknownAccount, account)
+	case !foundCollector:
+		return fmt.Errorf("%w: %s", exporter.ErrUnknownCollector, collector)
+	}
+	return errors.Join(errs...)
+}
+
+// Describe sends no descriptors as the collectors change on every reload.
+func (r *Reloader) Describe(_ chan<- *prometheus.Desc) {}
+
+func (r *Reloader) Collect(ch chan<- prometheus.Metric) {
+	ch <- r.lastReloadSuccessful
+	ch <- r.lastReloadSuccessTimestamp
+	state := r.current.Load()
+	if state == nil {
+		return
+	}
+	for _, account := range state.accounts {
+		account.collector.Collect(ch)
+	}
+}
+
+// RefreshHandler forces a refresh of the collector given in the collector
+// query parameter of the account given in the account query parameter. Either
+// can be omitted to refresh every collector or account.
+func (r *Reloader) RefreshHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := req.URL.Query()
+	err := r.Refresh(req.Context(), query.Get("account"), query.Get("collector"))
+	switch {
+	case errors.Is(err, ErrUnknownAccount), errors.Is(err, exporter.ErrUnknownCollector):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, handleErr := w.Write([]byte("OK"))
+	if handleErr != nil {
+		logger.Errorf("Failed to write response: %v", handleErr)
+	}
+}
+
+// ReloadHandler reloads the configuration on POST.
+func (r *Reloader) ReloadHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.Reload(); err != nil {
+		logger.WithError(err).Error("Failed to reload configuration")
+		http.Error(w, fmt.Sprintf("Failed to reload configuration: %v", err), http.StatusInternalServerError)
+		return
+	}
+	_, handleErr := w.Write([]byte("OK"))
+	if handleErr != nil {
+		logger.Errorf("Failed to write response: %v", handleErr)
+	}
+}
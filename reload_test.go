@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/atlas"
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/exporter"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/urfave/cli/v3"
+)
+
+func TestReloaderKeepsConfigOnFailure(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(server.Close)
+	path := filepath.Join(t.TempDir(), "config.yml")
+	writeConfig := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to write config file: %v", err)
+		}
+	}
+	writeConfig("api_token: first-token\ncollectors:\n  ntp:\n    measurements: [1001]\n")
+
+	app := buildApp()
+	app.Action = func(ctx context.Context, c *cli.Command) error {
+		reloader := NewReloader(ctx, c)
+		if err := reloader.Reload(); err != nil {
+			t.Fatalf("Initial load failed: %v", err)
+		}
+		defer reloader.Stop()
+
+		writeConfig("api_token: ''\ncollectors:\n  ntp:\n    measurements: [-1]\n")
+		request := httptest.NewRequest(http.MethodPost, "/-/reload", nil)
+		recorder := httptest.NewRecorder()
+		reloader.ReloadHandler(recorder, request)
+		if recorder.Code != http.StatusInternalServerError {
+			t.Errorf("expected status 500 for an invalid config, got %d", recorder.Code)
+		}
+		if got := reloader.Config().Collectors.NTP.Measurements; len(got) != 1 || got[0] != 1001 {
+			t.Errorf("expected the previous configuration to stay active, got ntp measurements %v", got)
+		}
+		if got := testutil.ToFloat64(reloader.lastReloadSuccessful); got != 0 {
+			t.Errorf("expected last reload to be reported as failed, got %v", got)
+		}
+
+		writeConfig("api_token: second-token\ncollectors:\n  ntp:\n    measurements: [1002]\n")
+		previousClient := reloader.current.Load().accounts[0].client
+		if err := reloader.Reload(); err != nil {
+			t.Fatalf("Reload failed: %v", err)
+		}
+		if got := reloader.Config().Collectors.NTP.Measurements; len(got) != 1 || got[0] != 1002 {
+			t.Errorf("expected the new configuration to be active, got ntp measurements %v", got)
+		}
+		if reloader.current.Load().accounts[0].client == previousClient {
+			t.Error("expected the API client to be rebuilt")
+		}
+		if got := testutil.ToFloat64(reloader.lastReloadSuccessful); got != 1 {
+			t.Errorf("expected last reload to be reported as successful, got %v", got)
+		}
+		if got := testutil.ToFloat64(reloader.lastReloadSuccessTimestamp); got == 0 {
+			t.Error("expected the last successful reload timestamp to be set")
+		}
+		return nil
+	}
+	args := []string{"atlas_exporter", "--config.file", path, "--base_url", server.URL}
+	if err := app.Run(t.Context(), args); err != nil {
+		t.Fatalf("Failed to run app: %v", err)
+	}
+}
+
+func TestReloadHandlerRequiresPost(t *testing.T) {
+	reloader := NewReloader(t.Context(), buildApp())
+	recorder := httptest.NewRecorder()
+	reloader.ReloadHandler(recorder, httptest.NewRequest(http.MethodGet, "/-/reload", nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405, got %d", recorder.Code)
+	}
+	if !strings.Contains(recorder.Header().Get("Allow"), http.MethodPost) {
+		t.Errorf("expected Allow header to contain POST, got %q", recorder.Header().Get("Allow"))
+	}
+}
+
+func TestReloaderRefreshHandler(t *testing.T) {
+	var mu sync.Mutex
+	calls := map[string]int{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/credits", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls[r.Header.Get("Authorization")]++
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"current_balance": 1}`))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	account := func(name string) *accountState {
+		client, err := atlas.New(atlas.WithAPIToken(name), atlas.WithBaseURL(server.URL))
+		if err != nil {
+			t.Fatalf("Failed to create API client: %v", err)
+		}
+		accountExporter, err := exporter.New(client, logger, exporter.WithCollectors("credits"))
+		if err != nil {
+			t.Fatalf("Failed to create exporter: %v", err)
+		}
+		return &accountState{name: name, client: client, exporter: accountExporter}
+	}
+	reloader := NewReloader(t.Context(), buildApp())
+	reloader.current.Store(&exporterState{accounts: []*accountState{account("team-a"), account("team-b")}})
+
+	tests := []struct {
+		method string
+		target string
+		status int
+	}{
+		{http.MethodGet, "/-/refresh", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/-/refresh", http.StatusOK},
+		{http.MethodPost, "/-/refresh?collector=credits", http.StatusOK},
+		{http.MethodPost, "/-/refresh?account=team-a&collector=credits", http.StatusOK},
+		{http.MethodPost, "/-/refresh?collector=ntp", http.StatusNotFound},
+		{http.MethodPost, "/-/refresh?collector=missing", http.StatusNotFound},
+		{http.MethodPost, "/-/refresh?account=missing", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		recorder := httptest.NewRecorder()
+		reloader.RefreshHandler(recorder, httptest.NewRequest(tt.method, tt.target, nil))
+		if recorder.Code != tt.status {
+			t.Errorf("%s %s: expected status %d, got %d", tt.method, tt.target, tt.status, recorder.Code)
+		}
+	}
+	if calls["Key team-a"] != 3 || calls["Key team-b"] != 2 {
+		t.Errorf("expected 3 and 2 fetches, got %d and %d", calls["Key team-a"], calls["Key team-b"])
+	}
+}
+
+func TestReloaderAccountIsolation(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/credits", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Key good-token" {
+			http.Error(w, `{"error":{"title":"Forbidden","detail":"Invalid key"}}`, http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"current_balance": 500}`))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte(`
+accounts:
+  - name: team-a
+    api_token: good-token
+  - name: team-b
+    api_token: revoked-token
+`), 0o600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	app := buildApp()
+	app.Action = func(ctx context.Context, c *cli.Command) error {
+		reloader := NewReloader(ctx, c)
+		if err := reloader.Reload(); err != nil {
+			t.Fatalf("Initial load failed: %v", err)
+		}
+		defer reloader.Stop()
+
+		err := reloader.Refresh(ctx, "", "credits")
+		if err == nil || !strings.Contains(err.Error(), "account team-b") || strings.Contains(err.Error(), "account team-a") {
+			t.Errorf("expected only team-b to fail, got %v", err)
+		}
+		if err = reloader.Refresh(ctx, "team-a", "credits"); err != nil {
+			t.Errorf("expected team-a refresh to succeed, got %v", err)
+		}
+		if err = testutil.CollectAndCompare(reloader, strings.NewReader(`
+# HELP atlas_exporter_credits Current number of credits available in the Atlas account
+# TYPE atlas_exporter_credits gauge
+atlas_exporter_credits{account="team-a"} 500
+`), "atlas_exporter_credits"); err != nil {
+			t.Errorf("unexpected credits metrics: %v", err)
+		}
+		return nil
+	}
+	args := []string{"atlas_exporter", "--config.file", path, "--base_url", server.URL}
+	if err := app.Run(t.Context(), args); err != nil {
+		t.Fatalf("Failed to run app: %v", err)
+	}
+}
+
+func TestReloaderKeepsSnapshots(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/credits", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"current_balance": 500}`))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	path := filepath.Join(t.TempDir(), "config.yml")
+	writeConfig := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to write config file: %v", err)
+		}
+	}
+	writeConfig("api_token: first-token\ncollectors:\n  ntp:\n    enabled: true\n    measurements: [1001]\n")
+
+	app := buildApp()
+	app.Action = func(ctx context.Context, c *cli.Command) error {
+		reloader := NewReloader(ctx, c)
+		if err := reloader.Load(); err != nil {
+			t.Fatalf("Initial load failed: %v", err)
+		}
+		defer reloader.Stop()
+		_ = reloader.Refresh(ctx, "", "")
+
+		writeConfig("api_token: first-token\nlog_level: debug\ncollectors:\n  ntp:\n    enabled: true\n    measurements: [1002]\n")
+		if err := reloader.Load(); err != nil {
+			t.Fatalf("Reload failed: %v", err)
+		}
+		if err := testutil.CollectAndCompare(reloader, strings.NewReader(`
+# HELP atlas_exporter_credits Current number of credits available in the Atlas account
+# TYPE atlas_exporter_credits gauge
+atlas_exporter_credits{account="default"} 500
+`), "atlas_exporter_credits"); err != nil {
+			t.Errorf("expected the credits to survive the reload: %v", err)
+		}
+		for _, status := range reloader.current.Load().accounts[0].exporter.Status() {
+			switch status.Name {
+			case "credits":
+				if status.LastSuccess.IsZero() {
+					t.Errorf("expected credits to keep its last success, got %+v", status)
+				}
+			case "ntp":
+				if !status.LastSuccess.IsZero() || status.Err != nil {
+					t.Errorf("expected ntp to restart as its measurements changed, got %+v", status)
+				}
+			}
+		}
+
+		writeConfig("api_token: second-token\n")
+		if err := reloader.Load(); err != nil {
+			t.Fatalf("Reload failed: %v", err)
+		}
+		if got := testutil.CollectAndCount(reloader, "atlas_exporter_credits"); got != 0 {
+			t.Errorf("expected the credits to reset when the token changes, got %d series", got)
+		}
+
+		tokenPath := filepath.Join(t.TempDir(), "token")
+		if err := os.WriteFile(tokenPath, []byte("file-token\n"), 0o600); err != nil {
+			t.Fatalf("Failed to write token file: %v", err)
+		}
+		writeConfig("api_token_file: " + tokenPath + "\n")
+		if err := reloader.Load(); err != nil {
+			t.Fatalf("Reload failed: %v", err)
+		}
+		_ = reloader.Refresh(ctx, "", "")
+		if err := os.WriteFile(tokenPath, []byte("rotated-token\n"), 0o600); err != nil {
+			t.Fatalf("Failed to write token file: %v", err)
+		}
+		if err := reloader.Load(); err != nil {
+			t.Fatalf("Reload failed: %v", err)
+		}
+		if got := testutil.CollectAndCount(reloader, "atlas_exporter_credits"); got != 1 {
+			t.Errorf("expected the credits to survive a rotation of the token file, got %d series", got)
+		}
+		return nil
+	}
+	args := []string{"atlas_exporter", "--config.file", path, "--base_url", server.URL}
+	if err := app.Run(t.Context(), args); err != nil {
+		t.Fatalf("Failed to run app: %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/remotewrite"
+)
+
+// RemoteWriteConfig sends the Atlas metrics to a Prometheus remote write
+// endpoint every Interval. It is disabled when URL is empty. A zero Timeout or
+// QueueCapacity, or a nil MaxRetries, uses the default of the remote write
+// client. A MaxRetries of 0 disables retries.
+type RemoteWriteConfig struct {
+	URL            string                `yaml:"url"`
+	Interval       time.Duration         `yaml:"interval"`
+	Timeout        time.Duration         `yaml:"timeout"`
+	Headers        map[string]string     `yaml:"headers"`
+	BasicAuth      *RemoteWriteBasicAuth `yaml:"basic_auth"`
+	BearerToken    string                `yaml:"bearer_token"`
+	ExternalLabels map[string]string     `yaml:"external_labels"`
+	QueueCapacity  int                   `yaml:"queue_capacity"`
+	MaxRetries     *int                  `yaml:"max_retries"`
+}
+
+type RemoteWriteBasicAuth struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// enabled reports whether metrics are sent to a remote write endpoint.
+func (c *RemoteWriteConfig) enabled() bool {
+	return c.URL != ""
+}
+
+// validate checks the remote write settings. Errors are prefixed with the key
+// path of the remote_write block.
+func (c *RemoteWriteConfig) validate(prefix string) []error {
+	if !c.enabled() {
+		return nil
+	}
+	var errs []error
+	if parsed, err := url.Parse(c.URL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		errs = append(errs, fmt.Errorf("%s.url: must be an http or https URL, got %q", prefix, c.URL))
+	}
+	if c.Interval <= 0 {
+		errs = append(errs, fmt.Errorf("%s.interval: must be greater than 0, got %s", prefix, c.Interval))
+	}
+	if c.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("%s.timeout: must not be negative, got %s", prefix, c.Timeout))
+	}
+	if c.QueueCapacity < 0 {
+		errs = append(errs, fmt.Errorf("%s.queue_capacity: must not be negative, got %d", prefix, c.QueueCapacity))
+	}
+	if c.MaxRetries != nil && *c.MaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("%s.max_retries: must not be negative, got %d", prefix, *c.MaxRetries))
+	}
+	if c.BasicAuth != nil {
+		if c.BasicAuth.Username == "" {
+			errs = append(errs, fmt.Errorf("%s.basic_auth.username: must not be empty", prefix))
+		}
+		if c.BearerToken != "" {
+			errs = append(errs, fmt.Errorf("%s.basic_auth, %s.bearer_token: only one can be set", prefix, prefix))
+		}
+	}
+	return errs
+}
+
+// client creates the remote write client of the configuration.
+func (c *RemoteWriteConfig) client() (*remotewrite.Client, error) {
+	headers := http.Header{}
+	for name, value := range c.Headers {
+		headers.Set(name, value)
+	}
+	opts := []remotewrite.Option{
+		remotewrite.WithHeaders(headers),
+		remotewrite.WithExternalLabels(c.ExternalLabels),
+		remotewrite.WithLogger(logger.WithField("component", "remote_write")),
+	}
+	if c.Timeout > 0 {
+		opts = append(opts, remotewrite.WithTimeout(c.Timeout))
+	}
+	if c.QueueCapacity > 0 {
+		opts = append(opts, remotewrite.WithQueueCapacity(c.QueueCapacity))
+	}
+	if c.MaxRetries != nil {
+		opts = append(opts, remotewrite.WithRetries(*c.MaxRetries, time.Second, time.Minute))
+	}
+	switch {
+	case c.BasicAuth != nil:
+		opts = append(opts, remotewrite.WithBasicAuth(c.BasicAuth.Username, c.BasicAuth.Password))
+	case c.BearerToken != "":
+		opts = append(opts, remotewrite.WithBearerToken(c.BearerToken))
+	}
+	client, err := remotewrite.New(c.URL, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create remote write client: %w", err)
+	}
+	return client, nil
+}
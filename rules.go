@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/urfave/cli/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// Defaults of the zero thresholds of RulesConfig.
+const (
+	defaultProbeDisconnectedFor = 30 * time.Minute
+	defaultCreditsBelow         = 100000
+	defaultCreditsRunwayDays    = 7
+	defaultCollectorFailingFor  = 15 * time.Minute
+	defaultKeyExpiresWithin     = 14 * 24 * time.Hour
+)
+
+// RulesConfig holds the thresholds of the alerts generated by the rules
+// command. Zero thresholds use the defaults. Labels are added to every alert.
+type RulesConfig struct {
+	ProbeDisconnectedFor time.Duration     `yaml:"probe_disconnected_for"`
+	CreditsBelow         int               `yaml:"credits_below"`
+	CreditsRunwayDays    float64           `yaml:"credits_runway_days"`
+	CollectorFailingFor  time.Duration     `yaml:"collector_failing_for"`
+	KeyExpiresWithin     time.Duration     `yaml:"key_expires_within"`
+	Labels               map[string]string `yaml:"labels"`
+}
+
+// validate checks the rule thresholds. Errors are prefixed with the key path
+// of the rules block.
+func (c *RulesConfig) validate(prefix string) []error {
+	var errs []error
+	for _, setting := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"probe_disconnected_for", c.ProbeDisconnectedFor},
+		{"collector_failing_for", c.CollectorFailingFor},
+		{"key_expires_within", c.KeyExpiresWithin},
+	} {
+		if setting.value < 0 {
+			errs = append(errs, fmt.Errorf("%s.%s: must not be negative, got %s", prefix, setting.name, setting.value))
+		}
+	}
+	if c.CreditsBelow < 0 {
+		errs = append(errs, fmt.Errorf("%s.credits_below: must not be negative, got %d", prefix, c.CreditsBelow))
+	}
+	if c.CreditsRunwayDays < 0 {
+		errs = append(errs, fmt.Errorf("%s.credits_runway_days: must not be negative, got %v", prefix, c.CreditsRunwayDays))
+	}
+	for name := range c.Labels {
+		if !model.LabelName(name).IsValidLegacy() {
+			errs = append(errs, fmt.Errorf("%s.labels: invalid label name %q", prefix, name))
+		}
+	}
+	return errs
+}
+
+// withDefaults returns the thresholds with the defaults applied.
+func (c RulesConfig) withDefaults() RulesConfig {
+	if c.ProbeDisconnectedFor == 0 {
+		c.ProbeDisconnectedFor = defaultProbeDisconnectedFor
+	}
+	if c.CreditsBelow == 0 {
+		c.CreditsBelow = defaultCreditsBelow
+	}
+	if c.CreditsRunwayDays == 0 {
+		c.CreditsRunwayDays = defaultCreditsRunwayDays
+	}
+	if c.CollectorFailingFor == 0 {
+		c.CollectorFailingFor = defaultCollectorFailingFor
+	}
+	if c.KeyExpiresWithin == 0 {
+		c.KeyExpiresWithin = defaultKeyExpiresWithin
+	}
+	return c
+}
+
+// ruleFile is a Prometheus rule file.
+type ruleFile struct {
+	Groups []ruleGroup `yaml:"groups"`
+}
+
+type ruleGroup struct {
+	Name  string `yaml:"name"`
+	Rules []rule `yaml:"rules"`
+}
+
+// rule is a recording rule when Record is set and an alerting rule otherwise.
+type rule struct {
+	Record      string            `yaml:"record,omitempty"`
+	Alert       string            `yaml:"alert,omitempty"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// Names of the recording rules.
+const (
+	probeDisconnectedRecord = "atlas_exporter:probe_disconnected_seconds"
+	creditsBurnPerDayRecord = "atlas_exporter:credits_burn_per_day"
+)
+
+// seconds formats d as a number of seconds for PromQL.
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
+
+// buildRules returns the recording and alerting rules for the thresholds of cfg.
+func buildRules(cfg RulesConfig) ruleFile {
+	cfg = cfg.withDefaults()
+	labels := func(severity string) map[string]string {
+		l := map[string]string{"severity": severity}
+		maps.Copy(l, cfg.Labels)
+		return l
+	}
+	return ruleFile{Groups: []ruleGroup{
+		{
+			Name: "atlas_exporter.rules",
+			Rules: []rule{
+				{
+					Record: probeDisconnectedRecord,
+					Expr:   "time() - atlas_exporter_probe_last_connected",
+				},
+				{
+					Record: creditsBurnPerDayRecord,
+					Expr:   `atlas_exporter_credits_burn_rate_per_hour{window="24h"} * 24`,
+				},
+			},
+		},
+		{
+			Name: "atlas_exporter.alerts",
+			Rules: []rule{
+				{
+					Alert:  "AtlasProbeDisconnected",
+					Expr:   fmt.Sprintf("%s > %s", probeDisconnectedRecord, seconds(cfg.ProbeDisconnectedFor)),
+					Labels: labels("warning"),
+					Annotations: map[string]string{
+						"summary":     "RIPE Atlas probe {{ $labels.probe_id }} is disconnected",
+						"description": "Probe {{ $labels.probe_id }} ({{ $labels.description }}) of account {{ $labels.account }} has not connected for {{ $value | humanizeDuration }}.",
+					},
+				},
+				{
+					Alert:  "AtlasProbeAbandoned",
+					Expr:   `atlas_exporter_probe_status{status="Abandoned"} == 1`,
+					Labels: labels("warning"),
+					Annotations: map[string]string{
+						"summary":     "RIPE Atlas probe {{ $labels.probe_id }} was abandoned",
+						"description": "Probe {{ $labels.probe_id }} of account {{ $labels.account }} has been disconnected for so long that RIPE Atlas marked it as abandoned.",
+					},
+				},
+				{
+					Alert:  "AtlasCreditsLow",
+					Expr:   fmt.Sprintf("atlas_exporter_credits < %d", cfg.CreditsBelow),
+					Labels: labels("warning"),
+					Annotations: map[string]string{
+						"summary":     "RIPE Atlas credits of account {{ $labels.account }} are low",
+						"description": "Account {{ $labels.account }} has {{ $value }} credits left.",
+					},
+				},
+				{
+					Alert:  "AtlasCreditsRunningOut",
+					Expr:   fmt.Sprintf("min without (method) (atlas_exporter_credits_runway_days) < %s", strconv.FormatFloat(cfg.CreditsRunwayDays, 'f', -1, 64)),
+					For:    model.Duration(time.Hour).String(),
+					Labels: labels("warning"),
+					Annotations: map[string]string{
+						"summary":     "RIPE Atlas credits of account {{ $labels.account }} are projected to run out",
+						"description": "At the current burn rate, account {{ $labels.account }} runs out of credits in {{ $value | humanize }} days.",
+					},
+				},
+				{
+					Alert:  "AtlasCollectorFailing",
+					Expr:   "atlas_exporter_collector_success == 0",
+					For:    model.Duration(cfg.CollectorFailingFor).String(),
+					Labels: labels("warning"),
+					Annotations: map[string]string{
+						"summary":     "Atlas exporter collector {{ $labels.collector }} is failing",
+						"description": "The {{ $labels.collector }} collector of account {{ $labels.account }} cannot refresh its data from the Atlas API.",
+					},
+				},
+				{
+					Alert:  "AtlasAPIKeyExpiring",
+					Expr:   fmt.Sprintf("atlas_exporter_api_key_expiry_timestamp_seconds - time() < %s", seconds(cfg.KeyExpiresWithin)),
+					Labels: labels("warning"),
+					Annotations: map[string]string{
+						"summary":     "RIPE Atlas API key of account {{ $labels.account }} is expiring",
+						"description": "The API key of account {{ $labels.account }} expires in {{ $value | humanizeDuration }}.",
+					},
+				},
+				{
+					Alert:  "AtlasAPIKeyInactive",
+					Expr:   "atlas_exporter_api_key_active == 0",
+					Labels: labels("critical"),
+					Annotations: map[string]string{
+						"summary":     "RIPE Atlas API key of account {{ $labels.account }} is inactive",
+						"description": "The API key of account {{ $labels.account }} is disabled or expired.",
+					},
+				},
+			},
+		},
+	}}
+}
+
+func rulesCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "rules",
+		Usage: "Print Prometheus recording and alerting rules for the exporter metrics, then exit",
+		Description: "The thresholds of the alerts are read from the rules block of the configuration file. " +
+			"The API token is not needed.",
+		Action: Rules,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "output",
+				Aliases: []string{"o"},
+				Usage:   "Path to write the rule file to instead of the standard output",
+			},
+		},
+	}
+}
+
+// Rules writes the rule file for the thresholds of the configuration.
+func Rules(_ context.Context, c *cli.Command) error {
+	cfg, err := readConfig(c)
+	if err != nil {
+		return err
+	}
+	if err = errors.Join(cfg.Rules.validate("rules")...); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	var content bytes.Buffer
+	encoder := yaml.NewEncoder(&content)
+	encoder.SetIndent(2)
+	if err = encoder.Encode(buildRules(cfg.Rules)); err != nil {
+		return fmt.Errorf("failed to encode rules: %w", err)
+	}
+	if path := c.String("output"); path != "" {
+		if err = os.WriteFile(path, content.Bytes(), 0o644); err != nil { // #nosec G306 -- rule files are read by Prometheus
+			return fmt.Errorf("failed to write rules: %w", err)
+		}
+		return nil
+	}
+	_, err = c.Root().Writer.Write(content.Bytes())
+	return err
+}
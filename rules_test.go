@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/exporter"
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	descPattern         = regexp.MustCompile(`fqName: "([^"]+)".*variableLabels: \{([^}]*)\}`)
+	metricNamePattern   = regexp.MustCompile(`atlas_exporter[a-zA-Z0-9_:]*`)
+	labelMatcherPattern = regexp.MustCompile(`([a-zA-Z_][a-zA-Z0-9_]*)\s*(=|!=|=~|!~)\s*"`)
+	groupingPattern     = regexp.MustCompile(`(?:by|without)\s*\(([^)]*)\)`)
+	labelRefPattern     = regexp.MustCompile(`\$labels\.([a-zA-Z_][a-zA-Z0-9_]*)`)
+)
+
+// describedMetrics returns the labels of every metric described by the
+// collectors, by metric name. The account label is added by the Reloader.
+func describedMetrics(t *testing.T) map[string][]string {
+	t.Helper()
+	e, err := exporter.New(nil, logger, exporter.WithCollectors(exporter.CollectorNames()...))
+	if err != nil {
+		t.Fatalf("Failed to create exporter: %v", err)
+	}
+	ch := make(chan *prometheus.Desc)
+	go func() {
+		e.Describe(ch)
+		close(ch)
+	}()
+	metrics := map[string][]string{}
+	for desc := range ch {
+		match := descPattern.FindStringSubmatch(desc.String())
+		if match == nil {
+			t.Fatalf("Failed to parse %s", desc)
+		}
+		labels := []string{"account"}
+		if match[2] != "" {
+			labels = append(labels, strings.Split(match[2], ",")...)
+		}
+		metrics[match[1]] = labels
+	}
+	return metrics
+}
+
+// runRules runs the rules command with the configuration file content.
+func runRules(t *testing.T, content string) (string, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	var out bytes.Buffer
+	app := buildApp()
+	app.Writer = &out
+	err := app.Run(context.Background(), []string{"atlas_exporter", "--config.file", path, "rules"})
+	return out.String(), err
+}
+
+func TestRulesMatchDescribedMetrics(t *testing.T) {
+	output, err := runRules(t, "rules:\n  labels:\n    team: netops\n")
+	if err != nil {
+		t.Fatalf("rules failed: %v", err)
+	}
+	var rules ruleFile
+	if err = yaml.Unmarshal([]byte(output), &rules); err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+	metrics := describedMetrics(t)
+	alerts := 0
+	for _, group := range rules.Groups {
+		for _, r := range group.Rules {
+			name := r.Record + r.Alert
+			available := map[string]bool{}
+			for _, metric := range metricNamePattern.FindAllString(r.Expr, -1) {
+				labels, ok := metrics[metric]
+				if !ok {
+					t.Errorf("%s: %s is not described by any collector", name, metric)
+				}
+				for _, label := range labels {
+					available[label] = true
+				}
+			}
+			var used []string
+			for _, match := range labelMatcherPattern.FindAllStringSubmatch(r.Expr, -1) {
+				used = append(used, match[1])
+			}
+			for _, match := range groupingPattern.FindAllStringSubmatch(r.Expr, -1) {
+				for _, label := range strings.Split(match[1], ",") {
+					used = append(used, strings.TrimSpace(label))
+				}
+			}
+			for _, annotation := range r.Annotations {
+				for _, match := range labelRefPattern.FindAllStringSubmatch(annotation, -1) {
+					used = append(used, match[1])
+				}
+			}
+			for _, label := range used {
+				if !available[label] {
+					t.Errorf("%s: label %s is not on the metrics of %q", name, label, r.Expr)
+				}
+			}
+			if r.Record != "" {
+				// Later rules can use the recorded series.
+				metrics[r.Record] = append([]string(nil), keys(available)...)
+				continue
+			}
+			alerts++
+			if r.Labels["severity"] == "" || r.Labels["team"] != "netops" {
+				t.Errorf("%s: expected the severity and configured labels, got %v", name, r.Labels)
+			}
+			if r.Annotations["summary"] == "" || r.Annotations["description"] == "" {
+				t.Errorf("%s: expected a summary and description", name)
+			}
+		}
+	}
+	if alerts == 0 {
+		t.Error("expected alerting rules")
+	}
+}
+
+func keys(m map[string]bool) []string {
+	result := make([]string, 0, len(m))
+	for key := range m {
+		result = append(result, key)
+	}
+	return result
+}
+
+func TestRulesThresholds(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected []string
+	}{
+		{
+			"defaults",
+			"",
+			[]string{
+				"expr: atlas_exporter:probe_disconnected_seconds > 1800",
+				"expr: atlas_exporter_credits < 100000",
+				"expr: min without (method) (atlas_exporter_credits_runway_days) < 7",
+				"for: 15m",
+				"expr: atlas_exporter_api_key_expiry_timestamp_seconds - time() < 1209600",
+			},
+		},
+		{
+			"configured",
+			"rules:\n  probe_disconnected_for: 2h\n  credits_below: 5000\n  credits_runway_days: 2.5\n  collector_failing_for: 1h30m\n  key_expires_within: 72h\n",
+			[]string{
+				"expr: atlas_exporter:probe_disconnected_seconds > 7200",
+				"expr: atlas_exporter_credits < 5000",
+				"expr: min without (method) (atlas_exporter_credits_runway_days) < 2.5",
+				"for: 1h30m",
+				"expr: atlas_exporter_api_key_expiry_timestamp_seconds - time() < 259200",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := runRules(t, tt.content)
+			if err != nil {
+				t.Fatalf("rules failed: %v", err)
+			}
+			for _, expected := range tt.expected {
+				if !strings.Contains(output, expected) {
+					t.Errorf("expected rules to contain %q, got:\n%s", expected, output)
+				}
+			}
+		})
+	}
+}
+
+func TestRulesInvalidConfig(t *testing.T) {
+	_, err := runRules(t, "rules:\n  credits_below: -1\n  labels:\n    bad-label: x\n")
+	if err == nil || !strings.Contains(err.Error(), "rules.credits_below: must not be negative") || !strings.Contains(err.Error(), `rules.labels: invalid label name "bad-label"`) {
+		t.Errorf("expected validation errors, got %v", err)
+	}
+}
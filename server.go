@@ -0,0 +1,30 @@
+package main
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+)
+
+// serve serves HTTP requests on listener until the server is shut down. When
+// certificates is set, TLS is enabled with its certificate and the
+// tls_server_config of the web configuration file.
+func serve(listener net.Listener, server *http.Server, cfg *Config, certificates *CertificateReloader) error {
+	if certificates == nil {
+		return server.Serve(listener)
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.Web != nil && cfg.Web.TLSConfig != nil {
+		var err error
+		if tlsConfig, err = cfg.Web.TLSConfig.tlsConfig(); err != nil {
+			return err
+		}
+		if !cfg.Web.HTTPConfig.HTTP2 {
+			// A non-nil empty map disables HTTP/2.
+			server.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+		}
+	}
+	tlsConfig.GetCertificate = certificates.GetCertificate
+	server.TLSConfig = tlsConfig
+	return server.ServeTLS(listener, "", "")
+}
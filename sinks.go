@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/sink"
+)
+
+// defaultSinkInterval is the interval of sinks without one.
+const defaultSinkInterval = time.Minute
+
+// Sink types of SinkConfig.
+const (
+	sinkTypeInfluxDB = "influxdb"
+	sinkTypeGraphite = "graphite"
+)
+
+// SinkConfig sends the Atlas metrics to InfluxDB or Graphite every Interval.
+// URL, Token, Username and Password only apply to InfluxDB and Address and
+// GraphiteTags only to Graphite. A zero Interval sends every minute and a zero
+// Timeout uses the default of the sink.
+type SinkConfig struct {
+	Type         string            `yaml:"type"`
+	Interval     time.Duration     `yaml:"interval"`
+	Timeout      time.Duration     `yaml:"timeout"`
+	Prefix       string            `yaml:"prefix"`
+	Tags         map[string]string `yaml:"tags"`
+	TagMapping   map[string]string `yaml:"tag_mapping"`
+	URL          string            `yaml:"url"`
+	Token        string            `yaml:"token"`
+	Username     string            `yaml:"username"`
+	Password     string            `yaml:"password"`
+	Address      string            `yaml:"address"`
+	GraphiteTags bool              `yaml:"graphite_tags"`
+}
+
+// validate checks the sink settings. Errors are prefixed with the key path
+// of the sink.
+func (c *SinkConfig) validate(prefix string) []error {
+	var errs []error
+	switch c.Type {
+	case sinkTypeInfluxDB:
+		if parsed, err := url.Parse(c.URL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			errs = append(errs, fmt.Errorf("%s.url: must be an http or https URL, got %q", prefix, c.URL))
+		}
+		if c.Token != "" && c.Username != "" {
+			errs = append(errs, fmt.Errorf("%s.token, %s.username: only one can be set", prefix, prefix))
+		}
+	case sinkTypeGraphite:
+		if _, _, err := net.SplitHostPort(c.Address); err != nil {
+			errs = append(errs, fmt.Errorf("%s.address: must be host:port, got %q", prefix, c.Address))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("%s.type: must be '%s' or '%s', got %q", prefix, sinkTypeInfluxDB, sinkTypeGraphite, c.Type))
+	}
+	if c.Interval < 0 {
+		errs = append(errs, fmt.Errorf("%s.interval: must not be negative, got %s", prefix, c.Interval))
+	}
+	if c.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("%s.timeout: must not be negative, got %s", prefix, c.Timeout))
+	}
+	return errs
+}
+
+// interval returns the interval between sends.
+func (c *SinkConfig) interval() time.Duration {
+	if c.Interval == 0 {
+		return defaultSinkInterval
+	}
+	return c.Interval
+}
+
+// sink creates the sink of the configuration.
+func (c *SinkConfig) sink() (sink.Sink, error) {
+	opts := []sink.Option{
+		sink.WithPrefix(c.Prefix),
+		sink.WithTags(c.Tags),
+		sink.WithTagMapping(c.TagMapping),
+	}
+	if c.Timeout > 0 {
+		opts = append(opts, sink.WithTimeout(c.Timeout))
+	}
+	var s sink.Sink
+	var err error
+	switch c.Type {
+	case sinkTypeInfluxDB:
+		switch {
+		case c.Token != "":
+			opts = append(opts, sink.WithToken(c.Token))
+		case c.Username != "":
+			opts = append(opts, sink.WithBasicAuth(c.Username, c.Password))
+		}
+		s, err = sink.NewInfluxDB(c.URL, opts...)
+	case sinkTypeGraphite:
+		s, err = sink.NewGraphite(c.Address, append(opts, sink.WithGraphiteTags(c.GraphiteTags))...)
+	default:
+		err = fmt.Errorf("unknown sink type %q", c.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s sink: %w", c.Type, err)
+	}
+	return s, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/state"
+)
+
+// defaultCompactionInterval is the interval between maintenances of the state
+// store when compaction_interval is not set.
+const defaultCompactionInterval = 24 * time.Hour
+
+// StateConfig is the store where the exporter keeps data across restarts,
+// such as the credit history. The state is kept in memory when Path is empty.
+// Records older than Retention, or the retention of their namespace in
+// NamespaceRetention, are pruned every CompactionInterval, after which the
+// file is compacted if records were pruned. A zero retention keeps records
+// forever.
+type StateConfig struct {
+	Path               string                   `yaml:"path"`
+	Retention          time.Duration            `yaml:"retention"`
+	NamespaceRetention map[string]time.Duration `yaml:"namespace_retention"`
+	CompactionInterval time.Duration            `yaml:"compaction_interval"`
+}
+
+// validate checks the state settings. Errors are prefixed with the key path
+// of the state block.
+func (c *StateConfig) validate(prefix string) []error {
+	var errs []error
+	if c.Retention < 0 {
+		errs = append(errs, fmt.Errorf("%s.retention: must not be negative, got %s", prefix, c.Retention))
+	}
+	for namespace, retention := range c.NamespaceRetention {
+		if retention < 0 {
+			errs = append(errs, fmt.Errorf("%s.namespace_retention.%s: must not be negative, got %s", prefix, namespace, retention))
+		}
+	}
+	if c.CompactionInterval < 0 {
+		errs = append(errs, fmt.Errorf("%s.compaction_interval: must not be negative, got %s", prefix, c.CompactionInterval))
+	}
+	return errs
+}
+
+// compactionInterval returns the interval between maintenances of the store.
+func (c *StateConfig) compactionInterval() time.Duration {
+	if c.CompactionInterval == 0 {
+		return defaultCompactionInterval
+	}
+	return c.CompactionInterval
+}
+
+// open opens the state file, or creates a memory store when no path is set.
+func (c *StateConfig) open() (state.Store, error) {
+	retention := state.Retention{Default: c.Retention, Namespaces: c.NamespaceRetention}
+	if c.Path == "" {
+		return state.NewMemory(retention), nil
+	}
+	store, err := state.OpenBolt(c.Path, retention)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state store: %w", err)
+	}
+	return store, nil
+}
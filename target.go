@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/exporter"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// module returns the named module. Every measurement kind is also available as
+// a module exporting all of its fields unless the configuration overrides it.
+func (c *Config) module(name string) (ModuleConfig, bool) {
+	if module, ok := c.Modules[name]; ok {
+		return module, true
+	}
+	if slices.Contains(exporter.MeasurementKinds(), name) {
+		return ModuleConfig{Type: name}, true
+	}
+	return ModuleConfig{}, false
+}
+
+// TargetHandler exports the results of the measurement given in the
+// measurement query parameter using the module given in the module query
+// parameter, in the style of the blackbox_exporter.
+func (r *Reloader) TargetHandler(w http.ResponseWriter, req *http.Request) {
+	state := r.current.Load()
+	query := req.URL.Query()
+	measurementID, err := strconv.Atoi(query.Get("measurement"))
+	if err != nil || measurementID <= 0 {
+		http.Error(w, fmt.Sprintf("Invalid measurement parameter %q", query.Get("measurement")), http.StatusBadRequest)
+		return
+	}
+	moduleName := query.Get("module")
+	module, ok := state.cfg.module(moduleName)
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown module %q", moduleName), http.StatusBadRequest)
+		return
+	}
+	account := state.account(module.Account)
+	if account == nil {
+		http.Error(w, fmt.Sprintf("Unknown account %q", module.Account), http.StatusBadRequest)
+		return
+	}
+	collector, err := account.exporter.TargetCollector(req.Context(), module.Type, measurementID, module.Fields)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, req)
+}
+
+// account returns the named account, or the first account when name is empty.
+func (s *exporterState) account(name string) *accountState {
+	for _, account := range s.accounts {
+		if name == "" || account.name == name {
+			return account
+		}
+	}
+	return nil
+}
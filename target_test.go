@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/exporter"
+)
+
+func TestTargetHandler(t *testing.T) {
+	mux, client := setupTestAPIClient(t)
+	serveJSON(t, mux, "/measurements/1003/latest/", []map[string]any{
+		{
+			"msm_id": 1003, "prb_id": 1, "dst_name": "ntp.example.com", "stratum": 2, "poll": 64, "ref-id": "192.0.2.1",
+			"result": []map[string]any{{"offset": 0.002, "rtt": 0.02}},
+		},
+	})
+	serveJSON(t, mux, "/measurements/1003/", map[string]any{"id": 1003, "type": "ntp"})
+	serveJSON(t, mux, "/measurements/1004/", map[string]any{"id": 1004, "type": "http"})
+	accountExporter, err := exporter.New(client, logger, exporter.WithTimeout(10*time.Second))
+	if err != nil {
+		t.Fatalf("Failed to create exporter: %v", err)
+	}
+	reloader := NewReloader(t.Context(), buildApp())
+	reloader.current.Store(&exporterState{
+		cfg: &Config{
+			Timeout: 10,
+			Modules: map[string]ModuleConfig{
+				"ntp_offset": {Type: "ntp", Fields: []string{"offset_seconds"}},
+			},
+		},
+		accounts: []*accountState{{name: defaultAccount, client: client, exporter: accountExporter}},
+	})
+
+	tests := []struct {
+		name        string
+		target      string
+		status      int
+		contains    []string
+		notContains []string
+	}{
+		{
+			name:        "module fields",
+			target:      "/atlas?measurement=1003&module=ntp_offset",
+			status:      http.StatusOK,
+			contains:    []string{`atlas_exporter_ntp_offset_seconds{measurement_id="1003",probe_id="1",target="ntp.example.com"} 0.002`, "atlas_exporter_target_success 1"},
+			notContains: []string{"atlas_exporter_ntp_stratum"},
+		},
+		{
+			name:     "built-in module",
+			target:   "/atlas?measurement=1003&module=ntp",
+			status:   http.StatusOK,
+			contains: []string{"atlas_exporter_ntp_offset_seconds", "atlas_exporter_ntp_stratum", "atlas_exporter_target_success 1"},
+		},
+		{
+			name:     "failed fetch",
+			target:   "/atlas?measurement=404&module=ntp",
+			status:   http.StatusOK,
+			contains: []string{"atlas_exporter_target_success 0"},
+		},
+		{
+			name:        "measurement of another type",
+			target:      "/atlas?measurement=1004&module=ntp",
+			status:      http.StatusOK,
+			contains:    []string{"atlas_exporter_target_success 0"},
+			notContains: []string{"atlas_exporter_ntp_offset_seconds"},
+		},
+		{name: "unknown module", target: "/atlas?measurement=1003&module=bogus", status: http.StatusBadRequest},
+		{name: "invalid measurement", target: "/atlas?measurement=abc&module=ntp", status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			reloader.TargetHandler(recorder, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if recorder.Code != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, recorder.Code, recorder.Body.String())
+			}
+			body := recorder.Body.String()
+			for _, expected := range tt.contains {
+				if !strings.Contains(body, expected) {
+					t.Errorf("expected response to contain %q, got:\n%s", expected, body)
+				}
+			}
+			for _, unexpected := range tt.notContains {
+				if strings.Contains(body, unexpected) {
+					t.Errorf("expected response not to contain %q, got:\n%s", unexpected, body)
+				}
+			}
+		})
+	}
+}
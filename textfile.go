@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Cyb3r-Jak3/atlas-stats-exporter/pkg/exporter"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/urfave/cli/v3"
+)
+
+func textfileCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "textfile",
+		Usage: "Periodically write the metrics to a file for the node_exporter textfile collector instead of listening for scrapes",
+		Description: "The file is replaced atomically, so node_exporter never reads a partial file. " +
+			"With --once, every enabled collector runs once and the command exits with status 2 if at least one collector failed.",
+		Action: Textfile,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "textfile_path",
+				Usage:    "Path of the file to write, in the node_exporter textfile directory and ending in .prom",
+				Required: true,
+				Sources:  cli.EnvVars("ATLAS_EXPORTER_TEXTFILE_PATH"),
+			},
+			&cli.DurationFlag{
+				Name:    "textfile_interval",
+				Usage:   "Interval between writes of the file",
+				Value:   time.Minute,
+				Sources: cli.EnvVars("ATLAS_EXPORTER_TEXTFILE_INTERVAL"),
+			},
+			&cli.BoolFlag{
+				Name:    "once",
+				Usage:   "Run every enabled collector once, write the file and exit",
+				Sources: cli.EnvVars("ATLAS_EXPORTER_TEXTFILE_ONCE"),
+			},
+		},
+	}
+}
+
+// textfileWriter writes the Atlas metrics and the time of the write to a file.
+type textfileWriter struct {
+	path      string
+	registry  *prometheus.Registry
+	lastWrite prometheus.Gauge
+}
+
+func newTextfileWriter(path string, reloader *Reloader) *textfileWriter {
+	w := &textfileWriter{
+		path:     path,
+		registry: prometheus.NewRegistry(),
+		lastWrite: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "atlas_exporter_textfile_last_write_timestamp_seconds",
+			Help: "Time the metrics file was written in seconds since epoch",
+		}),
+	}
+	w.registry.MustRegister(atlasCollectors(reloader)...)
+	w.registry.MustRegister(w.lastWrite)
+	return w
+}
+
+// write replaces the file with the current metrics. The file is written to a
+// temporary file in the same directory first and renamed over the previous
+// one, so readers see either the old or the new metrics.
+func (w *textfileWriter) write(now time.Time) error {
+	w.lastWrite.Set(float64(now.Unix()))
+	if err := prometheus.WriteToTextfile(w.path, w.registry); err != nil {
+		return fmt.Errorf("failed to write metrics to %s: %w", w.path, err)
+	}
+	return nil
+}
+
+// Textfile writes the metrics of every account to the file in --textfile_path
+// every --textfile_interval until interrupted, or once with --once. Collectors
+// refresh in the background like when serving the metrics endpoint, and the
+// file is only written once the collectors of the configuration, initial or
+// reloaded, have been refreshed.
+func Textfile(ctx context.Context, c *cli.Command) error {
+	reloader := NewReloader(ctx, c)
+	if c.Bool("once") {
+		return textfileOnce(ctx, c, reloader)
+	}
+	if err := reloader.ReloadAndRefresh(); err != nil {
+		return err
+	}
+	defer reloader.Stop()
+	writer := newTextfileWriter(c.String("textfile_path"), reloader)
+	interval := c.Duration("textfile_interval")
+	logger.Infof("Writing metrics to %s every %s", writer.path, interval)
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(quit)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := writer.write(time.Now()); err != nil {
+			logger.WithError(err).Error("Failed to write metrics file")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-quit:
+			logger.Info("Shutting down...")
+			return nil
+		case <-hup:
+			if err := reloader.ReloadAndRefresh(); err != nil {
+				logger.WithError(err).Error("Failed to reload configuration")
+			}
+		case <-ticker.C:
+		}
+	}
+}
+
+// textfileOnce runs every enabled collector once and writes the file.
+func textfileOnce(ctx context.Context, c *cli.Command, reloader *Reloader) error {
+	if err := reloader.Load(); err != nil {
+		return err
+	}
+	defer reloader.Stop()
+	refreshErr := reloader.Refresh(ctx, "", "")
+	if errors.Is(refreshErr, exporter.ErrUnknownCollector) {
+		// Every collector is disabled.
+		refreshErr = nil
+	}
+	writer := newTextfileWriter(c.String("textfile_path"), reloader)
+	if err := writer.write(time.Now()); err != nil {
+		return cli.Exit(err.Error(), exitOutputFailed)
+	}
+	if refreshErr != nil {
+		return cli.Exit(fmt.Sprintf("wrote metrics, but collectors failed: %v", refreshErr), exitCollectorsFailed)
+	}
+	logger.Infof("Wrote metrics to %s", writer.path)
+	return nil
+}
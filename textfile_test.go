@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/urfave/cli/v3"
+)
+
+// startCreditsServer serves the credits endpoint of the Atlas API with status.
+func startCreditsServer(t *testing.T, status int) string {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/credits", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"current_balance": 500}`))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+// textfileArgs returns the arguments running the textfile command with only
+// the credits collector enabled, plus extra.
+func textfileArgs(baseURL, path string, extra ...string) []string {
+	args := []string{"atlas_exporter", "--api_token", "test-token", "--base_url", baseURL}
+	for _, name := range []string{"probe_last_connected", "probe_measurements", "measurement_metadata"} {
+		args = append(args, "--no-collector."+name)
+	}
+	args = append(args, "textfile", "--textfile_path", path)
+	return append(args, extra...)
+}
+
+func TestTextfileOnce(t *testing.T) {
+	tests := []struct {
+		name          string
+		creditsStatus int
+		exitCode      int
+	}{
+		{"success", http.StatusOK, 0},
+		{"collector failure", http.StatusInternalServerError, exitCollectorsFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "atlas.prom")
+			app := buildApp()
+			app.ExitErrHandler = func(context.Context, *cli.Command, error) {}
+			err := app.Run(t.Context(), textfileArgs(startCreditsServer(t, tt.creditsStatus), path, "--once"))
+			exitCode := 0
+			var exitErr cli.ExitCoder
+			if errors.As(err, &exitErr) {
+				exitCode = exitErr.ExitCode()
+			} else if err != nil {
+				t.Fatalf("Failed to run app: %v", err)
+			}
+			if exitCode != tt.exitCode {
+				t.Errorf("expected exit code %d, got %d (%v)", tt.exitCode, exitCode, err)
+			}
+
+			content, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Failed to read metrics file: %v", err)
+			}
+			for _, name := range []string{"atlas_exporter_build_info", "atlas_exporter_collector_success", "atlas_exporter_textfile_last_write_timestamp_seconds"} {
+				if !strings.Contains(string(content), name) {
+					t.Errorf("expected the metrics file to contain %s", name)
+				}
+			}
+			if tt.creditsStatus == http.StatusOK && !strings.Contains(string(content), "atlas_exporter_credits{") {
+				t.Error("expected the metrics file to contain atlas_exporter_credits")
+			}
+			if strings.Contains(string(content), `atlas_exporter_collector_success{account="default",collector="probe_measurements"}`) {
+				t.Error("expected disabled collectors to be left out of the metrics file")
+			}
+			if !strings.Contains(string(content), `atlas_exporter_collector_enabled{account="default",collector="probe_measurements"} 0`) {
+				t.Error("expected disabled collectors to be reported as disabled")
+			}
+			if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+				t.Errorf("expected only the metrics file in the directory, got %d entries", len(entries))
+			}
+		})
+	}
+}
+
+func TestTextfileInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "atlas.prom")
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() {
+		done <- buildApp().Run(ctx, textfileArgs(startCreditsServer(t, http.StatusOK), path, "--textfile_interval", "10ms"))
+	}()
+
+	// Wait for a write after the first one, so the timestamp metric changes.
+	var first string
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		content, err := os.ReadFile(path)
+		if err == nil && strings.Contains(string(content), "atlas_exporter_credits{") {
+			if first == "" {
+				first = string(content)
+			} else if string(content) != first {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the metrics file to be rewritten")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("expected the command to stop cleanly, got %v", err)
+	}
+}
+
+func TestTextfileWaitsForFirstRefresh(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "atlas.prom")
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() {
+		done <- buildApp().Run(ctx, textfileArgs(startCreditsServer(t, http.StatusOK), path, "--textfile_interval", "1h"))
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		content, err := os.ReadFile(path)
+		if err == nil {
+			if !strings.Contains(string(content), "atlas_exporter_credits{") {
+				t.Errorf("expected the first write to contain the credits, got:\n%s", content)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the metrics file to be written")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("expected the command to stop cleanly, got %v", err)
+	}
+}
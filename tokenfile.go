@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// tokenFileReloadInterval is how often API token files are checked for changes.
+const tokenFileReloadInterval = time.Minute
+
+// readTokenFile returns the API token in the file at path, without
+// surrounding whitespace.
+func readTokenFile(path string) (string, error) {
+	content, err := os.ReadFile(path) // #nosec G304 -- path is set by the operator
+	if err != nil {
+		return "", fmt.Errorf("failed to read API token file: %w", err)
+	}
+	token := strings.TrimSpace(string(content))
+	if token == "" {
+		return "", fmt.Errorf("API token file %s is empty", path)
+	}
+	return token, nil
+}
+
+// watchTokenFile reads the API token file of account every interval until ctx
+// is cancelled and swaps the token of its client when the file changes. If
+// the file cannot be read, the previous token stays in use.
+func watchTokenFile(ctx context.Context, account *accountState, interval time.Duration) {
+	path := account.tokenFile
+	log := logger.WithFields(logrus.Fields{"account": account.name, "file": path})
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		token, err := readTokenFile(path)
+		if err != nil {
+			log.WithError(err).Error("Failed to reload API token")
+			continue
+		}
+		if token == account.client.Token() {
+			continue
+		}
+		if err = account.client.SetAPIToken(token); err != nil {
+			log.WithError(err).Error("Failed to reload API token")
+			continue
+		}
+		log.Info("API token rotated")
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTokenFile(t *testing.T, path, token string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(token), 0o600); err != nil {
+		t.Fatalf("Failed to write token file: %v", err)
+	}
+}
+
+func TestLoadConfigTokenFile(t *testing.T) {
+	dir := t.TempDir()
+	topLevel := filepath.Join(dir, "token")
+	writeTokenFile(t, topLevel, "  file-token\n")
+	cfg, err := loadTestConfig(t, "", "--api_token_file", topLevel)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.APIToken != "file-token" {
+		t.Errorf("expected the token from the file, got %q", cfg.APIToken)
+	}
+	if accounts := cfg.accounts(); accounts[0].APITokenFile != topLevel {
+		t.Errorf("expected the default account to watch %s, got %q", topLevel, accounts[0].APITokenFile)
+	}
+
+	teamA := filepath.Join(dir, "team-a")
+	writeTokenFile(t, teamA, "team-a-token")
+	cfg, err = loadTestConfig(t, "accounts:\n  - name: team-a\n    api_token_file: "+teamA+"\n")
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.Accounts[0].APIToken != "team-a-token" {
+		t.Errorf("expected the account token from the file, got %q", cfg.Accounts[0].APIToken)
+	}
+
+	empty := filepath.Join(dir, "empty")
+	writeTokenFile(t, empty, "\n")
+	tests := []struct {
+		name     string
+		content  string
+		args     []string
+		expected string
+	}{
+		{"token and file", "", []string{"--api_token", "x", "--api_token_file", topLevel}, "api_token, api_token_file: only one can be set"},
+		{"missing file", "", []string{"--api_token_file", filepath.Join(dir, "missing")}, "api_token_file: failed to read API token file"},
+		{"empty file", "", []string{"--api_token_file", empty}, "api_token_file: API token file " + empty + " is empty"},
+		{"account token and file", "accounts:\n  - name: a\n    api_token: x\n    api_token_file: " + teamA + "\n", nil, "accounts[0].api_token, accounts[0].api_token_file: only one can be set"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadTestConfig(t, tt.content, tt.args...)
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("expected error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestWatchTokenFile(t *testing.T) {
+	_, client := setupTestAPIClient(t)
+	path := filepath.Join(t.TempDir(), "token")
+	writeTokenFile(t, path, "test-token")
+	account := &accountState{name: "default", client: client, tokenFile: path}
+	go watchTokenFile(t.Context(), account, 10*time.Millisecond)
+
+	waitForToken := func(expected string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for client.Token() != expected {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected token %q, got %q", expected, client.Token())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	writeTokenFile(t, path, "rotated-token\n")
+	waitForToken("rotated-token")
+
+	writeTokenFile(t, path, "")
+	time.Sleep(50 * time.Millisecond)
+	if client.Token() != "rotated-token" {
+		t.Errorf("expected the previous token to stay in use with an empty file, got %q", client.Token())
+	}
+	writeTokenFile(t, path, "second-rotation")
+	waitForToken("second-rotation")
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+// WebConfig configures TLS and authentication of the HTTP server. It is read
+// from web_config_file, which uses the web configuration format of the
+// Prometheus exporter-toolkit.
+type WebConfig struct {
+	TLSConfig  *WebTLSConfig     `yaml:"tls_server_config"`
+	HTTPConfig WebHTTPConfig     `yaml:"http_server_config"`
+	Users      map[string]string `yaml:"basic_auth_users"`
+}
+
+// WebTLSConfig holds the TLS settings of the HTTP server. Relative paths are
+// relative to the directory of the web configuration file.
+type WebTLSConfig struct {
+	CertFile       string      `yaml:"cert_file"`
+	KeyFile        string      `yaml:"key_file"`
+	ClientAuthType string      `yaml:"client_auth_type"`
+	ClientCAFile   string      `yaml:"client_ca_file"`
+	MinVersion     TLSVersion  `yaml:"min_version"`
+	MaxVersion     TLSVersion  `yaml:"max_version"`
+	CipherSuites   []TLSCipher `yaml:"cipher_suites"`
+}
+
+type WebHTTPConfig struct {
+	HTTP2 bool `yaml:"http2"`
+}
+
+// clientAuthTypes maps the client_auth_type values to the TLS client
+// authentication policies.
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"":                           tls.NoClientCert,
+	"NoClientCert":               tls.NoClientCert,
+	"RequestClientCert":          tls.RequestClientCert,
+	"RequireAnyClientCert":       tls.RequireAnyClientCert,
+	"VerifyClientCertIfGiven":    tls.VerifyClientCertIfGiven,
+	"RequireAndVerifyClientCert": tls.RequireAndVerifyClientCert,
+}
+
+// loadWebConfig reads the web configuration file at path.
+func loadWebConfig(path string) (*WebConfig, error) {
+	content, err := os.ReadFile(path) // #nosec G304 -- path is set by the operator
+	if err != nil {
+		return nil, fmt.Errorf("failed to read web config file: %w", err)
+	}
+	c := &WebConfig{HTTPConfig: WebHTTPConfig{HTTP2: true}}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err = decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse web config file %s: %w", path, err)
+	}
+	if c.TLSConfig != nil {
+		dir := filepath.Dir(path)
+		for _, file := range []*string{&c.TLSConfig.CertFile, &c.TLSConfig.KeyFile, &c.TLSConfig.ClientCAFile} {
+			if *file != "" && !filepath.IsAbs(*file) {
+				*file = filepath.Join(dir, *file)
+			}
+		}
+	}
+	return c, nil
+}
+
+// validate checks the web configuration, including that the certificates can
+// be loaded, and reports every problem found.
+func (c *WebConfig) validate() []error {
+	var errs []error
+	users := make([]string, 0, len(c.Users))
+	for user := range c.Users {
+		users = append(users, user)
+	}
+	sort.Strings(users)
+	for _, user := range users {
+		if _, err := bcrypt.Cost([]byte(c.Users[user])); err != nil {
+			errs = append(errs, fmt.Errorf("web_config_file: basic_auth_users.%s: invalid bcrypt hash: %w", user, err))
+		}
+	}
+	if c.TLSConfig != nil {
+		if c.TLSConfig.CertFile == "" || c.TLSConfig.KeyFile == "" {
+			errs = append(errs, errors.New("web_config_file: tls_server_config: cert_file and key_file must both be set"))
+		} else if _, err := tls.LoadX509KeyPair(c.TLSConfig.CertFile, c.TLSConfig.KeyFile); err != nil {
+			errs = append(errs, fmt.Errorf("web_config_file: tls_server_config: failed to load certificate: %w", err))
+		}
+		if _, err := c.TLSConfig.tlsConfig(); err != nil {
+			errs = append(errs, fmt.Errorf("web_config_file: tls_server_config: %w", err))
+		}
+	}
+	return errs
+}
+
+// serverSettings returns the settings applied when the HTTP server starts, as
+// opposed to the users that are checked against the current configuration on
+// every request.
+func (c *WebConfig) serverSettings() WebConfig {
+	if c == nil {
+		return WebConfig{HTTPConfig: WebHTTPConfig{HTTP2: true}}
+	}
+	return WebConfig{TLSConfig: c.TLSConfig, HTTPConfig: c.HTTPConfig}
+}
+
+// tlsConfig builds the TLS configuration of the HTTP server, without the
+// certificate which is served by a CertificateReloader. The minimum TLS version
+// defaults to TLS 1.2.
+func (c *WebTLSConfig) tlsConfig() (*tls.Config, error) {
+	clientAuth, ok := clientAuthTypes[c.ClientAuthType]
+	if !ok {
+		return nil, fmt.Errorf("invalid client_auth_type %q", c.ClientAuthType)
+	}
+	cfg := &tls.Config{
+		ClientAuth: clientAuth,
+		MinVersion: uint16(c.MinVersion),
+		MaxVersion: uint16(c.MaxVersion),
+	}
+	if cfg.MinVersion == 0 {
+		cfg.MinVersion = tls.VersionTLS12
+	}
+	if cfg.MaxVersion != 0 && cfg.MaxVersion < cfg.MinVersion {
+		return nil, errors.New("max_version must not be lower than min_version")
+	}
+	for _, cipher := range c.CipherSuites {
+		cfg.CipherSuites = append(cfg.CipherSuites, uint16(cipher))
+	}
+	switch {
+	case c.ClientCAFile != "":
+		if clientAuth == tls.NoClientCert {
+			return nil, errors.New("client_ca_file requires client_auth_type to be set")
+		}
+		pem, readErr := os.ReadFile(c.ClientCAFile)
+		if readErr != nil {
+			return nil, fmt.Errorf("failed to read client_ca_file: %w", readErr)
+		}
+		cfg.ClientCAs = x509.NewCertPool()
+		if !cfg.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in client_ca_file %s", c.ClientCAFile)
+		}
+	case clientAuth == tls.VerifyClientCertIfGiven || clientAuth == tls.RequireAndVerifyClientCert:
+		return nil, fmt.Errorf("client_auth_type %s requires client_ca_file to be set", c.ClientAuthType)
+	}
+	return cfg, nil
+}
+
+// TLSVersion is a TLS protocol version written as TLS10 to TLS13.
+type TLSVersion uint16
+
+var tlsVersions = map[string]TLSVersion{
+	"TLS13": tls.VersionTLS13,
+	"TLS12": tls.VersionTLS12,
+	"TLS11": tls.VersionTLS11,
+	"TLS10": tls.VersionTLS10,
+}
+
+func (v *TLSVersion) UnmarshalYAML(node *yaml.Node) error {
+	version, ok := tlsVersions[node.Value]
+	if !ok {
+		return fmt.Errorf("line %d: unknown TLS version %q", node.Line, node.Value)
+	}
+	*v = version
+	return nil
+}
+
+// TLSCipher is a TLS 1.0-1.2 cipher suite written by its name, e.g.
+// TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256.
+type TLSCipher uint16
+
+func (c *TLSCipher) UnmarshalYAML(node *yaml.Node) error {
+	for _, suite := range slices.Concat(tls.CipherSuites(), tls.InsecureCipherSuites()) {
+		if suite.Name == node.Value {
+			*c = TLSCipher(suite.ID)
+			return nil
+		}
+	}
+	return fmt.Errorf("line %d: unknown cipher suite %q", node.Line, node.Value)
+}
+
+// authCacheSize bounds the number of cached credential checks.
+const authCacheSize = 100
+
+// authCache remembers the result of recent bcrypt comparisons so that
+// authenticated scrapes do not pay for the hashing on every request.
+type authCache struct {
+	mu      sync.Mutex
+	results map[[sha256.Size]byte]bool
+}
+
+// authenticate reports whether password is the password of user.
+func (a *authCache) authenticate(users map[string]string, user, password string) bool {
+	hash, known := users[user]
+	if !known {
+		// Compare against a fixed hash so unknown users take as long as known ones.
+		hash = "$2y$10$QOauhQNbBCuQDKes6eFzPeMqBSjb7Mr5DUmpZ/VcEd00UAV/LDeSi"
+	}
+	key := sha256.Sum256([]byte(user + "\x00" + hash + "\x00" + password))
+	a.mu.Lock()
+	valid, cached := a.results[key]
+	a.mu.Unlock()
+	if !cached {
+		valid = bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+		a.mu.Lock()
+		if len(a.results) >= authCacheSize {
+			clear(a.results)
+		}
+		a.results[key] = valid
+		a.mu.Unlock()
+	}
+	return valid && known
+}
+
+// BasicAuth requires the credentials of one of the basic_auth_users of the
+// current web configuration on every request when any are configured.
+func (r *Reloader) BasicAuth(next http.Handler) http.Handler {
+	cache := &authCache{results: map[[sha256.Size]byte]bool{}}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		web := r.Config().Web
+		if web == nil || len(web.Users) == 0 {
+			next.ServeHTTP(w, req)
+			return
+		}
+		user, password, ok := req.BasicAuth()
+		if !ok || !cache.authenticate(web.Users, user, password) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="atlas_exporter"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// writeTestCertificate writes a self-signed certificate for 127.0.0.1 and its
+// key to dir as name.pem and name-key.pem.
+func writeTestCertificate(t *testing.T, dir, name string, notAfter time.Time) (string, string, tls.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              notAfter,
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	certPath := filepath.Join(dir, name+".pem")
+	keyPath := filepath.Join(dir, name+"-key.pem")
+	for path, content := range map[string][]byte{certPath: certPEM, keyPath: keyPEM} {
+		if err = os.WriteFile(path, content, 0o600); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+	certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("Failed to load certificate: %v", err)
+	}
+	return certPath, keyPath, certificate
+}
+
+// writeWebConfig writes a web configuration file to dir and returns its path.
+func writeWebConfig(t *testing.T, dir, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, "web.yml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write web config file: %v", err)
+	}
+	return path
+}
+
+// startTestServer serves handler with cfg on a random local port and returns
+// its address and the CertificateReloader when TLS is enabled.
+func startTestServer(t *testing.T, cfg *Config, handler http.Handler) (string, *CertificateReloader) {
+	t.Helper()
+	var certificates *CertificateReloader
+	if certPath, keyPath, ok := cfg.certificatePaths(); ok {
+		var err error
+		if certificates, err = NewCertificateReloader(certPath, keyPath); err != nil {
+			t.Fatalf("Failed to load certificate: %v", err)
+		}
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := &http.Server{Handler: handler, ReadHeaderTimeout: time.Second}
+	go func() { _ = serve(listener, server, cfg, certificates) }()
+	t.Cleanup(func() { _ = server.Close() })
+	return listener.Addr().String(), certificates
+}
+
+func TestWebConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeTestCertificate(t, dir, "server", time.Now().Add(time.Hour))
+	tests := []struct {
+		name     string
+		content  string
+		args     []string
+		expected string
+	}{
+		{"unknown field", "basic_auth_users: {}\nrate_limit: {}\n", nil, "field rate_limit not found"},
+		{"invalid hash", "basic_auth_users:\n  admin: secret\n", nil, "basic_auth_users.admin: invalid bcrypt hash"},
+		{"unknown version", "tls_server_config:\n  cert_file: server.pem\n  key_file: server-key.pem\n  min_version: TLS14\n", nil, `unknown TLS version "TLS14"`},
+		{"unknown cipher", "tls_server_config:\n  cert_file: server.pem\n  key_file: server-key.pem\n  cipher_suites: [TLS_BOGUS]\n", nil, `unknown cipher suite "TLS_BOGUS"`},
+		{"missing certificate", "tls_server_config:\n  cert_file: missing.pem\n  key_file: server-key.pem\n", nil, "failed to load certificate"},
+		{"missing client ca", "tls_server_config:\n  cert_file: server.pem\n  key_file: server-key.pem\n  client_auth_type: RequireAndVerifyClientCert\n", nil, "requires client_ca_file"},
+		{"invalid versions", "tls_server_config:\n  cert_file: server.pem\n  key_file: server-key.pem\n  min_version: TLS13\n  max_version: TLS12\n", nil, "max_version must not be lower than min_version"},
+		{"tls enabled", "tls_server_config:\n  cert_file: server.pem\n  key_file: server-key.pem\n", []string{"--tls_enabled"}, "tls_enabled: must be false"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeWebConfig(t, dir, tt.content)
+			_, err := loadTestConfig(t, "", append([]string{"--api_token", "x", "--web_config_file", path}, tt.args...)...)
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("expected error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	reloader := NewReloader(t.Context(), buildApp())
+	reloader.current.Store(&exporterState{cfg: &Config{Web: &WebConfig{Users: map[string]string{"admin": string(hash)}}}})
+	handler := reloader.BasicAuth(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("OK"))
+	}))
+	tests := []struct {
+		name     string
+		user     string
+		password string
+		expected int
+	}{
+		{"no credentials", "", "", http.StatusUnauthorized},
+		{"wrong password", "admin", "wrong", http.StatusUnauthorized},
+		{"unknown user", "guest", "secret", http.StatusUnauthorized},
+		{"valid", "admin", "secret", http.StatusOK},
+		{"valid cached", "admin", "secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.user != "" {
+				request.SetBasicAuth(tt.user, tt.password)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+			if recorder.Code != tt.expected {
+				t.Errorf("expected status %d, got %d", tt.expected, recorder.Code)
+			}
+			if tt.expected == http.StatusUnauthorized && recorder.Header().Get("WWW-Authenticate") == "" {
+				t.Error("expected a WWW-Authenticate header")
+			}
+		})
+	}
+
+	reloader.current.Store(&exporterState{cfg: &Config{}})
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("expected no authentication without users, got status %d", recorder.Code)
+	}
+}
+
+func TestServeWebConfigTLS(t *testing.T) {
+	dir := t.TempDir()
+	_, _, serverCertificate := writeTestCertificate(t, dir, "server", time.Now().Add(time.Hour))
+	_, _, clientCertificate := writeTestCertificate(t, dir, "client", time.Now().Add(time.Hour))
+	web, err := loadWebConfig(writeWebConfig(t, dir, `
+tls_server_config:
+  cert_file: server.pem
+  key_file: server-key.pem
+  client_auth_type: RequireAndVerifyClientCert
+  client_ca_file: client.pem
+  min_version: TLS13
+http_server_config:
+  http2: false
+`))
+	if err != nil {
+		t.Fatalf("Failed to load web config: %v", err)
+	}
+	address, _ := startTestServer(t, &Config{Web: web}, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("OK"))
+	}))
+
+	roots := x509.NewCertPool()
+	roots.AddCert(serverCertificate.Leaf)
+	get := func(tlsConfig *tls.Config) (*http.Response, error) {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig, ForceAttemptHTTP2: true}}
+		return client.Get("https://" + address + "/metrics")
+	}
+
+	response, err := get(&tls.Config{RootCAs: roots, Certificates: []tls.Certificate{clientCertificate}})
+	if err != nil {
+		t.Fatalf("Request with a client certificate failed: %v", err)
+	}
+	_ = response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200, got %d", response.StatusCode)
+	}
+	if response.ProtoMajor != 1 {
+		t.Errorf("expected HTTP/2 to be disabled, got %s", response.Proto)
+	}
+	if response, err = get(&tls.Config{RootCAs: roots}); err == nil {
+		_ = response.Body.Close()
+		t.Error("expected a request without a client certificate to fail")
+	}
+	if response, err = get(&tls.Config{RootCAs: roots, Certificates: []tls.Certificate{clientCertificate}, MaxVersion: tls.VersionTLS12}); err == nil {
+		_ = response.Body.Close()
+		t.Error("expected a TLS 1.2 request to fail with min_version TLS13")
+	}
+}